@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"time"
 
 	// Internal packages
 	appConfig "github.com/JoaoOliveira889/s3-api/internal/config"
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+	"github.com/JoaoOliveira889/s3-api/internal/jobs"
 	"github.com/JoaoOliveira889/s3-api/internal/middleware"
+	"github.com/JoaoOliveira889/s3-api/internal/scan"
+	"github.com/JoaoOliveira889/s3-api/internal/search"
 	"github.com/JoaoOliveira889/s3-api/internal/upload"
 	"github.com/gin-gonic/gin"
 
@@ -28,23 +35,152 @@ func main() {
 
 	r := gin.New()
 
-	r.Use(middleware.RequestTimeoutMiddleware(cfg.UploadTimeout))
 	r.Use(middleware.LoggingMiddleware())
 	r.Use(gin.Recovery())
 
+	// Routes that carry file content get a far longer budget than the rest,
+	// so multi-gigabyte transfers are not cut off.
+	requestTimeout := middleware.RequestTimeoutMiddleware(cfg.UploadTimeout)
+	transferTimeout := middleware.RequestTimeoutMiddleware(cfg.TransferTimeout)
+
 	ctx := context.Background()
-	awsCfg, err := configAWS.LoadDefaultConfig(ctx, configAWS.WithRegion(cfg.AWSRegion))
+	repo, files, err := newRepository(ctx, cfg)
 	if err != nil {
-		slog.Error("failed to load AWS SDK config", "error", err)
+		slog.Error("failed to initialize storage backend", "backend", cfg.StorageBackend, "error", err)
 		os.Exit(1)
 	}
 
-	s3Client := s3.NewFromConfig(awsCfg)
-	repo := upload.NewS3Repository(s3Client, cfg.AWSRegion)
-	service := upload.NewService(repo)
+	if files != nil {
+		serveFiles := gin.WrapH(http.StripPrefix(upload.LocalFilesPath, files))
+		localFiles := r.Group(upload.LocalFilesPath, transferTimeout)
+		localFiles.GET("/*path", serveFiles)
+		localFiles.HEAD("/*path", serveFiles)
+		localFiles.PUT("/*path", serveFiles)
+		localFiles.POST("/*path", serveFiles)
+	}
+
+	searchIndex, err := newSearchIndex(cfg)
+	if err != nil {
+		slog.Error("failed to initialize search index", "index", cfg.SearchIndex, "error", err)
+		os.Exit(1)
+	}
+
+	keyTemplate, err := upload.ParseKeyTemplate(cfg.ObjectKeyTemplate)
+	if err != nil {
+		slog.Error("invalid object key template", "template", cfg.ObjectKeyTemplate, "error", err)
+		os.Exit(1)
+	}
+
+	policies := upload.DefaultPolicies()
+	if cfg.UploadPolicyFile != "" {
+		policies, err = upload.LoadPolicies(cfg.UploadPolicyFile)
+		if err != nil {
+			slog.Error("failed to load upload policies", "file", cfg.UploadPolicyFile, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var scanner scan.Scanner
+	if cfg.ClamdAddress != "" {
+		clamd, err := scan.NewClamd(cfg.ClamdAddress, cfg.ClamdTimeout)
+		if err != nil {
+			slog.Error("invalid clamd address", "address", cfg.ClamdAddress, "error", err)
+			os.Exit(1)
+		}
+		scanner = clamd
+	}
+
+	service := upload.NewService(repo,
+		upload.WithMaxUploadSize(cfg.MaxUploadSize),
+		upload.WithUploadTimeout(cfg.TransferTimeout),
+		upload.WithMaxPresignExpiry(cfg.PresignMaxTTL),
+		upload.WithMetadataLimits(upload.MetadataLimits{
+			MaxMetadataKeys:   cfg.MetadataMaxKeys,
+			MaxMetadataBytes:  cfg.MetadataMaxBytes,
+			MaxTags:           cfg.TagMaxCount,
+			MaxTagKeyLength:   cfg.TagMaxKeyLength,
+			MaxTagValueLength: cfg.TagMaxValueLength,
+		}),
+		upload.WithStatsCacheTTL(cfg.StatsCacheTTL),
+		upload.WithSearchIndex(searchIndex),
+		upload.WithKeyTemplate(keyTemplate),
+		upload.WithPolicies(policies),
+		upload.WithInspectors(inspect.DefaultChain(inspect.Options{
+			MaxImagePixels:  cfg.ImageMaxPixels,
+			StripSVGScripts: cfg.StripSVGScripts,
+			Archive: inspect.ArchiveLimits{
+				MaxEntries:      cfg.ArchiveMaxEntries,
+				MaxUncompressed: cfg.ArchiveMaxUncompressed,
+				MaxRatio:        cfg.ArchiveMaxRatio,
+			},
+		})),
+		upload.WithScanner(scanner),
+		upload.WithScanFailOpen(cfg.ScanFailOpen),
+		upload.WithScanMaxSize(cfg.ScanMaxSize),
+	)
+	service.StartStatsRefresher(ctx, cfg.StatsRefreshInterval)
 	handler := upload.NewHandler(service)
+	tusStore, err := newTusStore(cfg)
+	if err != nil {
+		slog.Error("failed to initialize tus store", "store", cfg.TusStore, "error", err)
+		os.Exit(1)
+	}
+	tus := upload.NewTusHandler(service, upload.TusConfig{
+		MaxSize:    tusMaxSize(cfg),
+		PartSize:   cfg.MultipartPartSize,
+		TempDir:    tusTempDir(cfg),
+		Expiration: cfg.TusExpiration,
+		Store:      tusStore,
+	})
+	if restored, err := tus.Restore(ctx); err != nil {
+		slog.Error("failed to restore tus uploads", "error", err)
+	} else if restored > 0 {
+		slog.Info("restored tus uploads", "count", restored)
+	}
+	tus.StartSweeper(ctx, cfg.TusSweepInterval)
 
-	api := r.Group("/api/v1")
+	jobStore, err := newJobStore(cfg)
+	if err != nil {
+		slog.Error("failed to initialize job store", "store", cfg.JobStore, "error", err)
+		os.Exit(1)
+	}
+	jobManager := jobs.NewManager(jobStore)
+	upload.RegisterJobs(jobManager, service)
+	if resumed, err := jobManager.Resume(ctx); err != nil {
+		slog.Error("failed to resume jobs", "error", err)
+	} else if resumed > 0 {
+		slog.Info("resumed unfinished jobs", "count", resumed)
+	}
+	jobManager.StartPruner(ctx, cfg.JobRetention, cfg.JobPruneInterval)
+	jobHandler := jobs.NewHandler(jobManager)
+
+	transfers := r.Group("/api/v1", transferTimeout)
+	{
+		transfers.POST("/upload", handler.UploadFile)
+		transfers.POST("/upload-multiple", handler.UploadMultiple)
+		transfers.GET("/download", handler.DownloadFile)
+
+		uploads := transfers.Group("/uploads")
+		{
+			uploads.POST("", handler.InitiateUpload)
+			uploads.GET("/:upload_id/parts", handler.ListUploadParts)
+			uploads.GET("/:upload_id/parts/:part_number", handler.GetUploadPartURL)
+			uploads.POST("/:upload_id/complete", handler.CompleteUpload)
+			uploads.DELETE("/:upload_id", handler.AbortUpload)
+		}
+
+		tusGroup := transfers.Group("/tus")
+		{
+			tusGroup.OPTIONS("", tus.Options)
+			tusGroup.POST("", tus.Create)
+			tusGroup.OPTIONS("/:id", tus.Options)
+			tusGroup.HEAD("/:id", tus.Head)
+			tusGroup.PATCH("/:id", tus.Patch)
+			tusGroup.DELETE("/:id", tus.Terminate)
+		}
+	}
+
+	api := r.Group("/api/v1", requestTimeout)
 	{
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{
@@ -55,11 +191,28 @@ func main() {
 		})
 
 		api.GET("/list", handler.ListFiles)
-		api.POST("/upload", handler.UploadFile)
-		api.POST("/upload-multiple", handler.UploadMultiple)
-		api.GET("/download", handler.DownloadFile)
+		api.GET("/search", handler.SearchFiles)
 		api.GET("/presign", handler.GetPresignedURL)
+		api.POST("/presign-upload", handler.PresignUpload)
 		api.DELETE("/delete", handler.DeleteFile)
+		api.POST("/delete-batch", handler.DeleteFiles)
+		api.GET("/files/metadata", handler.GetFileMetadata)
+		api.HEAD("/files/metadata", handler.GetFileMetadata)
+		api.GET("/files/tags", handler.GetFileTags)
+		api.PUT("/files/tags", handler.ReplaceFileTags)
+		api.DELETE("/files/tags", handler.DeleteFileTags)
+		api.GET("/files/versions", handler.ListFileVersions)
+		api.POST("/files/restore", handler.RestoreFileVersion)
+		api.POST("/files/copy", handler.CopyFile)
+		api.POST("/files/move", handler.MoveFile)
+
+		jobsGroup := api.Group("/jobs")
+		{
+			jobsGroup.POST("", jobHandler.CreateJob)
+			jobsGroup.GET("", jobHandler.ListJobs)
+			jobsGroup.GET("/:id", jobHandler.GetJob)
+			jobsGroup.DELETE("/:id", jobHandler.CancelJob)
+		}
 
 		buckets := api.Group("/buckets")
 		{
@@ -68,6 +221,8 @@ func main() {
 			buckets.GET("/stats", handler.GetBucketStats)
 			buckets.GET("/list", handler.ListBuckets)
 			buckets.DELETE("/empty", handler.EmptyBucket)
+			buckets.GET("/versioning", handler.GetBucketVersioning)
+			buckets.PUT("/versioning", handler.SetBucketVersioning)
 		}
 	}
 
@@ -75,6 +230,7 @@ func main() {
 		"port", cfg.Port,
 		"env", cfg.Env,
 		"region", cfg.AWSRegion,
+		"storage_backend", cfg.StorageBackend,
 	)
 
 	if err := r.Run(":" + cfg.Port); err != nil {
@@ -82,3 +238,102 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func newRepository(ctx context.Context, cfg *appConfig.Config) (upload.Repository, http.Handler, error) {
+	switch cfg.StorageBackend {
+	case "s3":
+		awsCfg, err := configAWS.LoadDefaultConfig(ctx, configAWS.WithRegion(cfg.AWSRegion))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+		}
+		multipart := upload.MultipartConfig{
+			Threshold:   cfg.MultipartThreshold,
+			PartSize:    cfg.MultipartPartSize,
+			Concurrency: cfg.MultipartConcurrency,
+			MaxRetries:  cfg.MultipartMaxRetries,
+		}
+		return upload.NewS3Repository(s3.NewFromConfig(awsCfg), cfg.AWSRegion, multipart), nil, nil
+
+	case "fs":
+		repo, err := upload.NewFilesystemRepository(cfg.StorageDir, cfg.PublicBaseURL, signingKey(cfg))
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo, nil
+
+	case "memory":
+		repo := upload.NewMemoryRepository(cfg.PublicBaseURL, signingKey(cfg))
+		return repo, repo, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+func newJobStore(cfg *appConfig.Config) (jobs.Store, error) {
+	switch cfg.JobStore {
+	case "memory":
+		return jobs.NewMemoryStore(), nil
+	case "file":
+		return jobs.NewFileStore(cfg.JobStoreDir)
+	default:
+		return nil, fmt.Errorf("unknown job store %q", cfg.JobStore)
+	}
+}
+
+// newTusStore returns nil for the memory store, which keeps sessions in the
+// handler only.
+func newTusStore(cfg *appConfig.Config) (upload.TusStore, error) {
+	switch cfg.TusStore {
+	case "memory":
+		return nil, nil
+	case "file":
+		return upload.NewFileTusStore(cfg.TusStoreDir)
+	default:
+		return nil, fmt.Errorf("unknown tus store %q", cfg.TusStore)
+	}
+}
+
+// newSearchIndex returns nil when search is turned off.
+func newSearchIndex(cfg *appConfig.Config) (*search.Index, error) {
+	switch cfg.SearchIndex {
+	case "memory":
+		return search.NewIndex(), nil
+	case "file":
+		return search.Open(cfg.SearchIndexPath)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown search index %q", cfg.SearchIndex)
+	}
+}
+
+func signingKey(cfg *appConfig.Config) []byte {
+	if cfg.SigningKey != "" {
+		return []byte(cfg.SigningKey)
+	}
+
+	slog.Warn("STORAGE_SIGNING_KEY not set, signed URLs will not survive restarts")
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}
+
+// tusTempDir keeps buffers next to persisted sessions unless told otherwise,
+// since the OS temp dir is often cleared on reboot.
+func tusTempDir(cfg *appConfig.Config) string {
+	if cfg.TusTempDir == "" && cfg.TusStore == "file" {
+		return cfg.TusStoreDir
+	}
+	return cfg.TusTempDir
+}
+
+// tusMaxSize is the Tus-Max-Size announced to clients. It never exceeds
+// MAX_UPLOAD_SIZE_MB, so oversized uploads are refused at creation rather
+// than after the last byte.
+func tusMaxSize(cfg *appConfig.Config) int64 {
+	if cfg.MaxUploadSize > 0 && (cfg.TusMaxSize <= 0 || cfg.TusMaxSize > cfg.MaxUploadSize) {
+		return cfg.MaxUploadSize
+	}
+	return cfg.TusMaxSize
+}
@@ -7,18 +7,126 @@ import (
 )
 
 type Config struct {
-	Port          string
-	AWSRegion     string
-	UploadTimeout time.Duration
-	Env           string
+	Port           string
+	AWSRegion      string
+	UploadTimeout  time.Duration
+	Env            string
+	StorageBackend string
+	StorageDir     string
+	PublicBaseURL  string
+	SigningKey     string
+	MaxUploadSize  int64
+	PresignMaxTTL  time.Duration
+
+	TransferTimeout time.Duration
+
+	MultipartThreshold   int64
+	MultipartPartSize    int64
+	MultipartConcurrency int
+	MultipartMaxRetries  int
+
+	TusMaxSize int64
+	TusTempDir string
+
+	TusStore         string
+	TusStoreDir      string
+	TusExpiration    time.Duration
+	TusSweepInterval time.Duration
+
+	MetadataMaxKeys   int
+	MetadataMaxBytes  int
+	TagMaxCount       int
+	TagMaxKeyLength   int
+	TagMaxValueLength int
+
+	JobStore    string
+	JobStoreDir string
+
+	JobRetention     time.Duration
+	JobPruneInterval time.Duration
+
+	StatsCacheTTL        time.Duration
+	StatsRefreshInterval time.Duration
+
+	SearchIndex     string
+	SearchIndexPath string
+
+	ObjectKeyTemplate string
+	UploadPolicyFile  string
+
+	StripSVGScripts        bool
+	ImageMaxPixels         int64
+	ArchiveMaxEntries      int
+	ArchiveMaxUncompressed int64
+	ArchiveMaxRatio        int64
+
+	ClamdAddress string
+	ClamdTimeout time.Duration
+	ScanFailOpen bool
+	ScanMaxSize  int64
 }
 
 func Load() *Config {
+	port := getEnv("PORT", "8080")
+
 	return &Config{
-		Port:          getEnv("PORT", "8080"),
-		AWSRegion:     getEnv("AWS_REGION", "us-east-1"),
-		UploadTimeout: time.Duration(getEnvAsInt("UPLOAD_TIMEOUT_SECONDS", 30)) * time.Second,
-		Env:           getEnv("APP_ENV", "development"),
+		Port:           port,
+		AWSRegion:      getEnv("AWS_REGION", "us-east-1"),
+		UploadTimeout:  time.Duration(getEnvAsInt("UPLOAD_TIMEOUT_SECONDS", 30)) * time.Second,
+		Env:            getEnv("APP_ENV", "development"),
+		StorageBackend: getEnv("STORAGE_BACKEND", "s3"),
+		StorageDir:     getEnv("STORAGE_DIR", "./data"),
+		PublicBaseURL:  getEnv("PUBLIC_BASE_URL", "http://localhost:"+port),
+		SigningKey:     getEnv("STORAGE_SIGNING_KEY", ""),
+		MaxUploadSize:  int64(getEnvAsInt("MAX_UPLOAD_SIZE_MB", 0)) * 1024 * 1024,
+		PresignMaxTTL:  time.Duration(getEnvAsInt("PRESIGN_MAX_EXPIRY_SECONDS", 604800)) * time.Second,
+
+		TransferTimeout: time.Duration(getEnvAsInt("TRANSFER_TIMEOUT_SECONDS", 21600)) * time.Second,
+
+		MultipartThreshold:   int64(getEnvAsInt("MULTIPART_THRESHOLD_MB", 100)) * 1024 * 1024,
+		MultipartPartSize:    int64(getEnvAsInt("MULTIPART_PART_SIZE_MB", 16)) * 1024 * 1024,
+		MultipartConcurrency: getEnvAsInt("MULTIPART_CONCURRENCY", 4),
+		MultipartMaxRetries:  getEnvAsInt("MULTIPART_MAX_RETRIES", 3),
+
+		TusMaxSize: int64(getEnvAsInt("TUS_MAX_SIZE_MB", 10240)) * 1024 * 1024,
+		TusTempDir: getEnv("TUS_TEMP_DIR", ""),
+
+		TusStore:         getEnv("TUS_STORE", "memory"),
+		TusStoreDir:      getEnv("TUS_STORE_DIR", "./data/.tus"),
+		TusExpiration:    time.Duration(getEnvAsInt("TUS_EXPIRATION_HOURS", 24)) * time.Hour,
+		TusSweepInterval: time.Duration(getEnvAsInt("TUS_SWEEP_INTERVAL_SECONDS", 600)) * time.Second,
+
+		MetadataMaxKeys:   getEnvAsInt("METADATA_MAX_KEYS", 16),
+		MetadataMaxBytes:  getEnvAsInt("METADATA_MAX_BYTES", 2048),
+		TagMaxCount:       getEnvAsInt("TAG_MAX_COUNT", 10),
+		TagMaxKeyLength:   getEnvAsInt("TAG_MAX_KEY_LENGTH", 128),
+		TagMaxValueLength: getEnvAsInt("TAG_MAX_VALUE_LENGTH", 256),
+
+		JobStore:    getEnv("JOB_STORE", "memory"),
+		JobStoreDir: getEnv("JOB_STORE_DIR", "./data/.jobs"),
+
+		JobRetention:     time.Duration(getEnvAsInt("JOB_RETENTION_HOURS", 168)) * time.Hour,
+		JobPruneInterval: time.Duration(getEnvAsInt("JOB_PRUNE_INTERVAL_SECONDS", 3600)) * time.Second,
+
+		StatsCacheTTL:        time.Duration(getEnvAsInt("STATS_CACHE_TTL_SECONDS", 600)) * time.Second,
+		StatsRefreshInterval: time.Duration(getEnvAsInt("STATS_REFRESH_INTERVAL_SECONDS", 300)) * time.Second,
+
+		SearchIndex:     getEnv("SEARCH_INDEX", "memory"),
+		SearchIndexPath: getEnv("SEARCH_INDEX_PATH", "./data/.search/index.jsonl"),
+
+		ObjectKeyTemplate: getEnv("OBJECT_KEY_TEMPLATE", "{uuid}{ext}"),
+		UploadPolicyFile:  getEnv("UPLOAD_POLICY_FILE", ""),
+
+		StripSVGScripts:        getEnv("SVG_SCRIPTS", "reject") == "strip",
+		ImageMaxPixels:         int64(getEnvAsInt("IMAGE_MAX_PIXELS", 100_000_000)),
+		ArchiveMaxEntries:      getEnvAsInt("ARCHIVE_MAX_ENTRIES", 10000),
+		ArchiveMaxUncompressed: int64(getEnvAsInt("ARCHIVE_MAX_UNCOMPRESSED_MB", 1024)) * 1024 * 1024,
+		ArchiveMaxRatio:        int64(getEnvAsInt("ARCHIVE_MAX_RATIO", 100)),
+
+		ClamdAddress: getEnv("CLAMD_ADDRESS", ""),
+		ClamdTimeout: time.Duration(getEnvAsInt("CLAMD_TIMEOUT_SECONDS", 30)) * time.Second,
+		ScanFailOpen: getEnv("SCAN_FAILURE_MODE", "closed") == "open",
+		ScanMaxSize:  int64(getEnvAsInt("CLAMD_MAX_SCAN_MB", 25)) * 1024 * 1024,
 	}
 }
 
@@ -0,0 +1,232 @@
+package inspect
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"io"
+	"slices"
+)
+
+// nestedInspectLimit is the largest archive inside an archive that is read
+// into memory to be inspected in turn.
+const nestedInspectLimit = 64 << 20
+
+// smallExpansion is never flagged by the ratio check; text compresses well
+// and a few megabytes cannot hurt.
+const smallExpansion = 1 << 20
+
+// ArchiveLimits bound what an archive may expand to. Sizes are counted while
+// actually decompressing, so headers that under-report do not help.
+type ArchiveLimits struct {
+	MaxEntries      int
+	MaxUncompressed int64
+	MaxRatio        int64
+	MaxDepth        int
+}
+
+func (l ArchiveLimits) normalized() ArchiveLimits {
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = 10_000
+	}
+	if l.MaxUncompressed <= 0 {
+		l.MaxUncompressed = 1 << 30
+	}
+	if l.MaxRatio <= 0 {
+		l.MaxRatio = 100
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = 2
+	}
+	return l
+}
+
+// ArchiveInspector decompresses zip based, gzip and bzip2 files with a
+// budget and rejects archive bombs: too many entries, too much output, an
+// extreme compression ratio, overlapping zip entries or archives nested too
+// deeply.
+type ArchiveInspector struct {
+	Limits ArchiveLimits
+}
+
+func (ArchiveInspector) Name() string {
+	return "archive"
+}
+
+func (a ArchiveInspector) Inspect(ctx context.Context, in *Input) error {
+	l := a.Limits.normalized()
+	w := &archiveWalk{ctx: ctx, name: a.Name(), limits: l, budget: l.MaxUncompressed}
+
+	var err error
+	switch {
+	case isZipType(in.Type):
+		err = w.zip(in, 1)
+	case in.Type == "application/gzip":
+		err = w.stream(in, func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		})
+	case in.Type == "application/x-bzip2":
+		err = w.stream(in, func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		})
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if expanded := l.MaxUncompressed - w.budget; expanded > smallExpansion && expanded/max(in.Size, 1) > l.MaxRatio {
+		return in.fail(a.Name(), ErrArchiveBomb, "archive expands %d times, more than %d", expanded/max(in.Size, 1), l.MaxRatio)
+	}
+	return nil
+}
+
+type archiveWalk struct {
+	ctx    context.Context
+	name   string
+	limits ArchiveLimits
+	budget int64
+}
+
+func (w *archiveWalk) stream(in *Input, open func(io.Reader) (io.Reader, error)) error {
+	r, err := open(in.Reader())
+	if err != nil {
+		return in.fail(w.name, ErrMalformed, "compressed stream does not open: %v", err)
+	}
+	if _, err := w.drain(in, r, w.budget); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *archiveWalk) zip(in *Input, depth int) error {
+	zr, err := zip.NewReader(in.Content, in.Size)
+	if err != nil {
+		return in.fail(w.name, ErrMalformed, "zip archive does not open: %v", err)
+	}
+	if len(zr.File) > w.limits.MaxEntries {
+		return in.fail(w.name, ErrArchiveBomb, "archive has %d entries, more than %d", len(zr.File), w.limits.MaxEntries)
+	}
+	if err := w.checkOverlap(in, zr.File); err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if int64(f.UncompressedSize64) > w.budget || f.UncompressedSize64 > uint64(w.limits.MaxUncompressed) {
+			return in.fail(w.name, ErrArchiveBomb, "entry %q declares %d bytes, over the %d byte budget", f.Name, f.UncompressedSize64, w.limits.MaxUncompressed)
+		}
+
+		if f.Flags&0x1 != 0 {
+			return in.fail(w.name, ErrMalformed, "entry %q is encrypted and cannot be inspected", f.Name)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return in.fail(w.name, ErrMalformed, "entry %q does not open: %v", f.Name, err)
+		}
+		br := bufio.NewReader(rc)
+		magic, _ := br.Peek(4)
+		isZip := bytes.Equal(magic, []byte("PK\x03\x04"))
+
+		var r io.Reader = br
+		nested := &bytes.Buffer{}
+		if isZip {
+			r = io.TeeReader(br, &limitedBuffer{buf: nested, limit: nestedInspectLimit})
+		}
+		n, err := w.drain(in, r, int64(f.UncompressedSize64))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if uint64(n) != f.UncompressedSize64 {
+			return in.fail(w.name, ErrArchiveBomb, "entry %q expands beyond its declared size", f.Name)
+		}
+
+		// Archives too large to buffer were still counted against the budget.
+		if !isZip || int64(nested.Len()) != n {
+			continue
+		}
+		if depth >= w.limits.MaxDepth {
+			return in.fail(w.name, ErrArchiveBomb, "archives are nested more than %d levels deep", w.limits.MaxDepth)
+		}
+		inner := &Input{Name: f.Name, Size: n, Content: bytes.NewReader(nested.Bytes()), Type: "application/zip"}
+		if err := w.zip(inner, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drain decompresses r while charging the shared budget, and fails once an
+// entry goes past declared bytes or the budget runs out.
+func (w *archiveWalk) drain(in *Input, r io.Reader, declared int64) (int64, error) {
+	limit := min(declared, w.budget) + 1
+	n, err := io.Copy(io.Discard, &contextReader{ctx: w.ctx, r: io.LimitReader(r, limit)})
+	w.budget -= n
+	if err != nil {
+		if ctxErr := w.ctx.Err(); ctxErr != nil {
+			return n, ctxErr
+		}
+		return n, in.fail(w.name, ErrMalformed, "archive data is corrupt: %v", err)
+	}
+	if w.budget < 0 {
+		return n, in.fail(w.name, ErrArchiveBomb, "archive expands to more than %d bytes", w.limits.MaxUncompressed)
+	}
+	return n, nil
+}
+
+// checkOverlap rejects zip entries whose data ranges overlap, the trick that
+// lets a small archive reference the same compressed bytes many times.
+func (w *archiveWalk) checkOverlap(in *Input, files []*zip.File) error {
+	type span struct{ start, end int64 }
+	spans := make([]span, 0, len(files))
+	for _, f := range files {
+		start, err := f.DataOffset()
+		if err != nil {
+			return in.fail(w.name, ErrMalformed, "entry %q has no local header", f.Name)
+		}
+		spans = append(spans, span{start, start + int64(f.CompressedSize64)})
+	}
+	slices.SortFunc(spans, func(a, b span) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start < spans[i-1].end {
+			return in.fail(w.name, ErrArchiveBomb, "archive entries overlap")
+		}
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written and drops the rest.
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
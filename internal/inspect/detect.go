@@ -0,0 +1,331 @@
+package inspect
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLength covers every signature in the table, the tar header at 257 and
+// enough text to recognise SVG documents that start with a long prolog.
+const sniffLength = 4096
+
+type signature struct {
+	offset int
+	magic  []byte
+	typ    string
+}
+
+// signatures are checked in order, so longer and more specific magic numbers
+// come before the generic ones they share a prefix with.
+var signatures = []signature{
+	{0, []byte("%PDF-"), "application/pdf"},
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{0, []byte("\xff\xd8\xff"), "image/jpeg"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{0, []byte("\x00\x00\x01\x00"), "image/vnd.microsoft.icon"},
+	{0, []byte("8BPS"), "image/vnd.adobe.photoshop"},
+	{0, []byte("\xff\x0a"), "image/jxl"},
+	{0, []byte("\x00\x00\x00\x0cJXL \r\n\x87\n"), "image/jxl"},
+	{0, []byte("wOFF"), "font/woff"},
+	{0, []byte("wOF2"), "font/woff2"},
+	{0, []byte("\x00\x01\x00\x00\x00"), "font/ttf"},
+	{0, []byte("OTTO"), "font/otf"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("OggS"), "audio/ogg"},
+	{0, []byte("#!AMR"), "audio/amr"},
+	{0, []byte("MThd"), "audio/midi"},
+	{0, []byte("\x1aE\xdf\xa3"), "video/x-matroska"},
+	{0, []byte("\x00\x00\x01\xba"), "video/mpeg"},
+	{0, []byte("\x00\x00\x01\xb3"), "video/mpeg"},
+	{0, []byte("FLV\x01"), "video/x-flv"},
+	{0, []byte("PK\x03\x04"), "application/zip"},
+	{0, []byte("PK\x05\x06"), "application/zip"},
+	{0, []byte("\x1f\x8b\x08"), "application/gzip"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{0, []byte("(\xb5/\xfd"), "application/zstd"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{0, []byte("Rar!\x1a\x07"), "application/vnd.rar"},
+	{257, []byte("ustar"), "application/x-tar"},
+	{0, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "application/x-ole-storage"},
+	{0, []byte("{\\rtf"), "application/rtf"},
+	{0, []byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+	{0, []byte("\x00asm"), "application/wasm"},
+	{0, []byte("\x7fELF"), "application/x-elf"},
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{0, []byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("\xca\xfe\xba\xbe"), "application/java-vm"},
+}
+
+// ftypBrands maps ISO base media brands to their media types.
+var ftypBrands = map[string]string{
+	"avif": "image/avif",
+	"avis": "image/avif",
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+	"qt  ": "video/quicktime",
+	"M4A ": "audio/mp4",
+	"M4B ": "audio/mp4",
+	"M4V ": "video/x-m4v",
+	"3gp4": "video/3gpp",
+	"3gp5": "video/3gpp",
+	"3g2a": "video/3gpp2",
+	"crx ": "image/x-canon-cr3",
+}
+
+// zipContainers are recognised by a marker entry inside the archive.
+var zipContainers = []struct {
+	entry string
+	typ   string
+}{
+	{"word/document.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{"xl/workbook.xml", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{"ppt/presentation.xml", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+	{"AndroidManifest.xml", "application/vnd.android.package-archive"},
+	{"META-INF/MANIFEST.MF", "application/java-archive"},
+}
+
+// extensionTypes maps extensions to the types Detect reports for them, where
+// the platform MIME table may not know the extension or disagree.
+var extensionTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".jfif": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".ico":  "image/vnd.microsoft.icon",
+	".avif": "image/avif",
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".svg":  "image/svg+xml",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tgz":  "application/gzip",
+	".bz2":  "application/x-bzip2",
+	".xz":   "application/x-xz",
+	".zst":  "application/zstd",
+	".7z":   "application/x-7z-compressed",
+	".rar":  "application/vnd.rar",
+	".tar":  "application/x-tar",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".epub": "application/epub+zip",
+	".jar":  "application/java-archive",
+	".apk":  "application/vnd.android.package-archive",
+	".mp3":  "audio/mpeg",
+	".flac": "audio/flac",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".m4a":  "audio/mp4",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".txt":  "text/plain",
+	".csv":  "text/plain",
+	".json": "text/plain",
+}
+
+// TypeByExtension returns the type Detect reports for files with extension
+// ext, falling back to the platform MIME table.
+func TypeByExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if t, ok := extensionTypes[ext]; ok {
+		return t
+	}
+	t, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	return t
+}
+
+// Detect returns the media type of the content from its magic number. It
+// knows far more formats than http.DetectContentType, tells apart the zip
+// based office, OpenDocument, EPUB and Java formats, and recognises SVG.
+// Unknown content falls back to http.DetectContentType.
+func Detect(in *Input) (string, error) {
+	buf, err := head(in, sniffLength)
+	if err != nil {
+		return "", err
+	}
+
+	typ := detectHead(buf)
+	switch typ {
+	case "application/zip":
+		return detectZip(in)
+	case "video/x-matroska":
+		// Matroska names its profile in the EBML DocType element.
+		if bytes.Contains(buf[:min(len(buf), 64)], []byte("webm")) {
+			return "video/webm", nil
+		}
+	}
+	return typ, nil
+}
+
+func detectHead(buf []byte) string {
+	for _, s := range signatures {
+		if len(buf) >= s.offset+len(s.magic) && bytes.Equal(buf[s.offset:s.offset+len(s.magic)], s.magic) {
+			return s.typ
+		}
+	}
+
+	if len(buf) >= 12 {
+		switch {
+		case bytes.Equal(buf[0:4], []byte("RIFF")):
+			switch string(buf[8:12]) {
+			case "WEBP":
+				return "image/webp"
+			case "WAVE":
+				return "audio/wav"
+			case "AVI ":
+				return "video/x-msvideo"
+			}
+		case bytes.Equal(buf[4:8], []byte("ftyp")):
+			brand := string(buf[8:12])
+			if t, ok := ftypBrands[brand]; ok {
+				return t
+			}
+			return "video/mp4"
+		}
+	}
+	if isBMP(buf) {
+		return "image/bmp"
+	}
+	if isMP3Frame(buf) {
+		return "audio/mpeg"
+	}
+	if isSVG(buf) {
+		return "image/svg+xml"
+	}
+
+	typ, _, _ := mime.ParseMediaType(http.DetectContentType(buf))
+	if typ == "image/bmp" {
+		// DetectContentType trusts "BM" alone, which isBMP has ruled out.
+		return textOrBinary(buf)
+	}
+	return typ
+}
+
+func textOrBinary(buf []byte) string {
+	if !utf8.Valid(buf) {
+		return "application/octet-stream"
+	}
+	for _, b := range buf {
+		if b < ' ' && b != '\t' && b != '\n' && b != '\r' && b != '\f' {
+			return "application/octet-stream"
+		}
+	}
+	return "text/plain"
+}
+
+// detectZip looks inside a zip archive for the entries that identify the
+// formats built on top of it.
+func detectZip(in *Input) (string, error) {
+	zr, err := zip.NewReader(in.Content, in.Size)
+	if err != nil {
+		// A truncated or damaged archive is still reported as zip so the
+		// archive inspector can reject it with a precise reason.
+		return "application/zip", nil
+	}
+
+	if len(zr.File) > 0 && zr.File[0].Name == "mimetype" && zr.File[0].Method == zip.Store {
+		f := zr.File[0]
+		if f.UncompressedSize64 > 0 && f.UncompressedSize64 < 128 {
+			rc, err := f.Open()
+			if err == nil {
+				data, _ := io.ReadAll(rc)
+				rc.Close()
+				if typ := strings.TrimSpace(string(data)); strings.HasPrefix(typ, "application/") {
+					return typ, nil
+				}
+			}
+		}
+	}
+
+	for _, c := range zipContainers {
+		for _, f := range zr.File {
+			if f.Name == c.entry {
+				return c.typ, nil
+			}
+		}
+	}
+	return "application/zip", nil
+}
+
+// isBMP checks the DIB header size as well, since "BM" alone is too common
+// at the start of text files.
+func isBMP(buf []byte) bool {
+	if len(buf) < 18 || !bytes.HasPrefix(buf, []byte("BM")) {
+		return false
+	}
+	switch binary.LittleEndian.Uint32(buf[14:18]) {
+	case 12, 40, 52, 56, 64, 108, 124:
+		return true
+	}
+	return false
+}
+
+// isMP3Frame matches an MPEG audio frame header without an ID3 tag.
+func isMP3Frame(buf []byte) bool {
+	return len(buf) >= 3 && buf[0] == 0xff && buf[1]&0xe0 == 0xe0 && buf[1]&0x06 != 0 && buf[2]&0xf0 != 0xf0
+}
+
+// isSVG accepts XML or bare markup whose first element is <svg>, skipping the
+// prolog, comments and a doctype.
+func isSVG(buf []byte) bool {
+	s := bytes.TrimLeft(buf, "\xef\xbb\xbf \t\r\n")
+	for len(s) > 0 {
+		switch {
+		case bytes.HasPrefix(s, []byte("<?")):
+			s = skipPast(s, "?>")
+		case bytes.HasPrefix(s, []byte("<!--")):
+			s = skipPast(s, "-->")
+		case bytes.HasPrefix(s, []byte("<!")):
+			if gt, sub := bytes.IndexByte(s, '>'), bytes.IndexByte(s, '['); sub >= 0 && sub < gt {
+				s = skipPast(s, "]")
+			}
+			s = skipPast(s, ">")
+		case bytes.HasPrefix(s, []byte("<")):
+			name := s[1:]
+			if i := bytes.IndexAny(name, " \t\r\n/>"); i >= 0 {
+				name = name[:i]
+			}
+			if j := bytes.IndexByte(name, ':'); j >= 0 {
+				name = name[j+1:]
+			}
+			return string(name) == "svg"
+		default:
+			return false
+		}
+		s = bytes.TrimLeft(s, " \t\r\n")
+	}
+	return false
+}
+
+func skipPast(s []byte, end string) []byte {
+	if i := bytes.Index(s, []byte(end)); i >= 0 {
+		return s[i+len(end):]
+	}
+	return nil
+}
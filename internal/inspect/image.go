@@ -0,0 +1,111 @@
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+var decodableImages = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+	"image/bmp":  "bmp",
+	"image/tiff": "tiff",
+}
+
+// ImageInspector decodes the full header of raster images and rejects files
+// that do not parse, claim a format they are not, or would decompress to more
+// than MaxPixels. PNG files additionally have every chunk and checksum
+// verified.
+type ImageInspector struct {
+	MaxPixels int64
+}
+
+func (ImageInspector) Name() string {
+	return "image"
+}
+
+func (i ImageInspector) Inspect(ctx context.Context, in *Input) error {
+	want, ok := decodableImages[in.Type]
+	if !ok {
+		return nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bufio.NewReader(in.Reader()))
+	if err != nil {
+		return in.fail(i.Name(), ErrMalformed, "image header does not decode: %v", err)
+	}
+	if format != want {
+		return in.fail(i.Name(), ErrMalformed, "image decodes as %s", format)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return in.fail(i.Name(), ErrMalformed, "image has no pixels")
+	}
+	if i.MaxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > i.MaxPixels {
+		return in.fail(i.Name(), ErrMalformed, "image is %dx%d, more than %d pixels", cfg.Width, cfg.Height, i.MaxPixels)
+	}
+
+	switch in.Type {
+	case "image/png":
+		return i.checkPNGChunks(in)
+	case "image/gif":
+		if end, err := tail(in, 1); err != nil || len(end) != 1 || end[0] != 0x3b {
+			return in.fail(i.Name(), ErrMalformed, "GIF trailer is missing")
+		}
+	}
+	return nil
+}
+
+// checkPNGChunks walks the chunk list up to IEND, checking lengths and CRCs.
+// Bytes after IEND are left to PolyglotInspector.
+func (i ImageInspector) checkPNGChunks(in *Input) error {
+	r := bufio.NewReader(in.Reader())
+	if _, err := r.Discard(8); err != nil {
+		return in.fail(i.Name(), ErrMalformed, "PNG signature is truncated")
+	}
+
+	var header [8]byte
+	for first := true; ; first = false {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return in.fail(i.Name(), ErrMalformed, "PNG ends before the IEND chunk")
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		typ := header[4:8]
+		if first && !bytes.Equal(typ, []byte("IHDR")) {
+			return in.fail(i.Name(), ErrMalformed, "PNG does not start with IHDR")
+		}
+		if int64(length) > in.Size {
+			return in.fail(i.Name(), ErrMalformed, "PNG chunk %q is longer than the file", typ)
+		}
+
+		crc := crc32.NewIEEE()
+		crc.Write(typ)
+		if _, err := io.CopyN(crc, r, int64(length)); err != nil {
+			return in.fail(i.Name(), ErrMalformed, "PNG chunk %q is truncated", typ)
+		}
+		var sum [4]byte
+		if _, err := io.ReadFull(r, sum[:]); err != nil {
+			return in.fail(i.Name(), ErrMalformed, "PNG chunk %q is truncated", typ)
+		}
+		if binary.BigEndian.Uint32(sum[:]) != crc.Sum32() {
+			return in.fail(i.Name(), ErrMalformed, "PNG chunk %q fails its checksum", typ)
+		}
+
+		if bytes.Equal(typ, []byte("IEND")) {
+			return nil
+		}
+	}
+}
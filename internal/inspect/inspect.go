@@ -0,0 +1,142 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sentinels wrapped by Error, one per kind of rejection.
+var (
+	ErrMalformed     = errors.New("malformed file content")
+	ErrPolyglot      = errors.New("file content is valid as more than one type")
+	ErrActiveContent = errors.New("file contains active content")
+	ErrArchiveBomb   = errors.New("archive expands beyond the allowed limits")
+)
+
+// Error reports which inspector rejected a file and why. It wraps one of the
+// sentinels above.
+type Error struct {
+	Inspector string
+	Type      string
+	Reason    string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s, %s)", e.Err, e.Reason, e.Type, e.Inspector)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Input is the file under inspection. Inspectors read Content at random
+// offsets, so large archives and PDFs are never loaded whole.
+type Input struct {
+	Name    string
+	Size    int64
+	Content io.ReaderAt
+	// Type is the media type found by Detect.
+	Type string
+
+	modified bool
+}
+
+// Replace swaps the content for a sanitized copy. Callers must store the new
+// content instead of the original when Modified reports true.
+func (in *Input) Replace(data []byte) {
+	in.Content = bytes.NewReader(data)
+	in.Size = int64(len(data))
+	in.modified = true
+}
+
+func (in *Input) Modified() bool {
+	return in.modified
+}
+
+// Reader returns the current content from the start.
+func (in *Input) Reader() *io.SectionReader {
+	return io.NewSectionReader(in.Content, 0, in.Size)
+}
+
+func (in *Input) fail(inspector string, err error, format string, args ...any) error {
+	return &Error{Inspector: inspector, Type: in.Type, Reason: fmt.Sprintf(format, args...), Err: err}
+}
+
+// Inspector validates one aspect of a file. Inspectors skip types they do not
+// understand and return an *Error when the content must be rejected.
+type Inspector interface {
+	Name() string
+	Inspect(ctx context.Context, in *Input) error
+}
+
+// Chain runs inspectors in order and stops at the first rejection.
+type Chain []Inspector
+
+func (c Chain) Inspect(ctx context.Context, in *Input) error {
+	for _, i := range c {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := i.Inspect(ctx, in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Options tunes the inspectors of DefaultChain. Zero values take the
+// defaults below.
+type Options struct {
+	// MaxImagePixels rejects images whose declared dimensions would
+	// decompress to more pixels than this.
+	MaxImagePixels int64
+	// StripSVGScripts removes scripts and event handlers from SVG files
+	// instead of rejecting them.
+	StripSVGScripts bool
+	Archive         ArchiveLimits
+}
+
+const defaultMaxImagePixels = 100_000_000
+
+// DefaultChain checks structure first, then hidden payloads, then active
+// content and archive expansion.
+func DefaultChain(opts Options) Chain {
+	if opts.MaxImagePixels <= 0 {
+		opts.MaxImagePixels = defaultMaxImagePixels
+	}
+	return Chain{
+		ImageInspector{MaxPixels: opts.MaxImagePixels},
+		PDFInspector{},
+		PolyglotInspector{},
+		SVGInspector{Strip: opts.StripSVGScripts},
+		ArchiveInspector{Limits: opts.Archive.normalized()},
+	}
+}
+
+// readAt reads exactly len(buf) bytes at off, or fewer at the end of the
+// content.
+func readAt(in *Input, off int64, n int) ([]byte, error) {
+	if off < 0 || off >= in.Size {
+		return nil, nil
+	}
+	n = int(min(int64(n), in.Size-off))
+	buf := make([]byte, n)
+	read, err := in.Content.ReadAt(buf, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+func head(in *Input, n int) ([]byte, error) {
+	return readAt(in, 0, n)
+}
+
+func tail(in *Input, n int) ([]byte, error) {
+	off := max(in.Size-int64(n), 0)
+	return readAt(in, off, int(in.Size-off))
+}
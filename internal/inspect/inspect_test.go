@@ -0,0 +1,190 @@
+package inspect
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPNG = "\x89PNG\r\n\x1a\n" +
+	"\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x00\x00\x00\x00\x3a\x7e\x9b\x55" +
+	"\x00\x00\x00\x0aIDAT\x78\x9c\x63\x60\x00\x00\x00\x02\x00\x01\x48\xaf\xa4\x71" +
+	"\x00\x00\x00\x00IEND\xae\x42\x60\x82"
+
+const testPDF = "%PDF-1.4\n1 0 obj<<>>endobj\nxref\n0 2\ntrailer<<>>\nstartxref\n27\n%%EOF\n"
+
+func input(name string, data []byte) *Input {
+	return &Input{Name: name, Size: int64(len(data)), Content: bytes.NewReader(data)}
+}
+
+func zipOf(t *testing.T, files map[string][]byte, method uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// run detects the type of data and runs the default chain over it.
+func run(t *testing.T, name string, data []byte, opts Options) (*Input, error) {
+	t.Helper()
+	in := input(name, data)
+	typ, err := Detect(in)
+	require.NoError(t, err)
+	in.Type = typ
+	return in, DefaultChain(opts).Inspect(context.Background(), in)
+}
+
+func TestDetect(t *testing.T) {
+	docx := zipOf(t, map[string][]byte{"[Content_Types].xml": nil, "word/document.xml": []byte("<w/>")}, zip.Deflate)
+	var epub bytes.Buffer
+	zw := zip.NewWriter(&epub)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	_, _ = w.Write([]byte("application/epub+zip"))
+	require.NoError(t, zw.Close())
+
+	for want, data := range map[string][]byte{
+		"image/png":       []byte(testPNG),
+		"application/pdf": []byte(testPDF),
+		"image/webp":      []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+		"video/mp4":       []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"),
+		"image/heic":      []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"),
+		"video/webm":      []byte("\x1aE\xdf\xa3\x9fB\x86\x81\x01B\xf7\x81\x01B\x82\x84webm"),
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": docx,
+		"application/epub+zip": epub.Bytes(),
+		"application/zip":      zipOf(t, map[string][]byte{"a.txt": []byte("a")}, zip.Deflate),
+		"image/svg+xml":        []byte("<?xml version=\"1.0\"?>\n<!-- logo -->\n<!DOCTYPE svg [<!ENTITY a \"b\">]>\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>"),
+		"text/plain":           []byte("BM is not a bitmap"),
+	} {
+		got, err := Detect(input("file", data))
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	assert.Equal(t, "image/jpeg", TypeByExtension(".JPG"))
+	assert.Equal(t, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", TypeByExtension(".docx"))
+}
+
+func TestImageAndPDFStructure(t *testing.T) {
+	_, err := run(t, "a.png", []byte(testPNG+"trailing bytes"), Options{})
+	assert.NoError(t, err)
+
+	corrupt := []byte(testPNG)
+	corrupt[len(corrupt)-13] ^= 0xff
+	_, err = run(t, "a.png", corrupt, Options{})
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = run(t, "a.png", []byte(testPNG[:20]), Options{})
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	huge := []byte(testPNG)
+	copy(huge[16:24], "\x00\x01\x00\x00\x00\x01\x00\x00")
+	binary.BigEndian.PutUint32(huge[29:33], crc32.ChecksumIEEE(huge[12:29]))
+	_, err = run(t, "a.png", huge, Options{MaxImagePixels: 1000})
+	require.ErrorIs(t, err, ErrMalformed)
+	assert.Contains(t, err.Error(), "65536x65536")
+
+	_, err = run(t, "a.pdf", []byte(testPDF), Options{})
+	assert.NoError(t, err)
+	_, err = run(t, "a.pdf", []byte("%PDF-1.4\nno trailer"), Options{})
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = run(t, "a.pdf", []byte(strings.Replace(testPDF, "startxref\n27", "startxref\n3", 1)), Options{})
+	assert.ErrorIs(t, err, ErrMalformed, "startxref must point at the xref table")
+}
+
+func TestPolyglotInspector(t *testing.T) {
+	archive := zipOf(t, map[string][]byte{"Payload.class": []byte("cafe")}, zip.Store)
+	_, err := run(t, "a.png", append([]byte(testPNG), archive...), Options{})
+	assert.ErrorIs(t, err, ErrPolyglot)
+
+	var rejected *Error
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "polyglot", rejected.Inspector)
+	assert.Equal(t, "image/png", rejected.Type)
+
+	_, err = run(t, "a.pdf", []byte(testPDF[:9]+"<script>alert(1)</script>\n"+testPDF[9:]), Options{})
+	assert.Error(t, err)
+}
+
+func TestSVGInspector(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">` +
+		`<script>alert(1)</script>` +
+		`<rect width="10" onclick="alert(2)" fill="red"/>` +
+		`<a xlink:href=" javascript:alert(3)"><text>hi &amp; bye</text></a>` +
+		`<foreignObject><div xmlns="http://www.w3.org/1999/xhtml"><script/></div></foreignObject>` +
+		`</svg>`
+
+	_, err := run(t, "a.svg", []byte(svg), Options{})
+	require.ErrorIs(t, err, ErrActiveContent)
+	assert.Contains(t, err.Error(), "event handler attributes")
+
+	in, err := run(t, "a.svg", []byte(svg), Options{StripSVGScripts: true})
+	require.NoError(t, err)
+	require.True(t, in.Modified())
+	buf := make([]byte, in.Size)
+	_, err = in.Content.ReadAt(buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`+
+		`<rect width="10" fill="red"/>`+
+		`<a><text>hi &amp; bye</text></a>`+
+		`</svg>`, string(buf))
+
+	in, err = run(t, "b.svg", []byte(`<svg><circle r="1"/></svg>`), Options{})
+	require.NoError(t, err)
+	assert.False(t, in.Modified())
+
+	_, err = run(t, "c.svg", []byte(`<svg><g></svg>`), Options{})
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestArchiveInspector(t *testing.T) {
+	_, err := run(t, "ok.zip", zipOf(t, map[string][]byte{"a.txt": []byte("hello"), "b.txt": []byte("world")}, zip.Deflate), Options{})
+	assert.NoError(t, err)
+
+	bomb := zipOf(t, map[string][]byte{"zeros.bin": make([]byte, 8<<20)}, zip.Deflate)
+	_, err = run(t, "bomb.zip", bomb, Options{})
+	assert.ErrorIs(t, err, ErrArchiveBomb, "8 MiB of zeros compresses far beyond the ratio")
+
+	_, err = run(t, "bomb.zip", bomb, Options{Archive: ArchiveLimits{MaxUncompressed: 1 << 20, MaxRatio: 1 << 20}})
+	assert.ErrorIs(t, err, ErrArchiveBomb, "over the budget")
+
+	many := map[string][]byte{}
+	for i := range 20 {
+		many[strings.Repeat("x", i+1)] = []byte("a")
+	}
+	_, err = run(t, "many.zip", zipOf(t, many, zip.Store), Options{Archive: ArchiveLimits{MaxEntries: 10}})
+	assert.ErrorIs(t, err, ErrArchiveBomb)
+
+	inner := zipOf(t, map[string][]byte{"a.txt": []byte("a")}, zip.Store)
+	middle := zipOf(t, map[string][]byte{"inner.zip": inner}, zip.Store)
+	outer := zipOf(t, map[string][]byte{"middle.zip": middle}, zip.Store)
+	_, err = run(t, "nested.zip", middle, Options{})
+	assert.NoError(t, err)
+	_, err = run(t, "nested.zip", outer, Options{})
+	assert.ErrorIs(t, err, ErrArchiveBomb)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write(make([]byte, 8<<20))
+	require.NoError(t, gw.Close())
+	_, err = run(t, "zeros.gz", gz.Bytes(), Options{})
+	assert.ErrorIs(t, err, ErrArchiveBomb)
+
+	truncated := zipOf(t, map[string][]byte{"a.txt": []byte("hello")}, zip.Deflate)
+	_, err = run(t, "cut.zip", truncated[:len(truncated)-10], Options{})
+	assert.ErrorIs(t, err, ErrMalformed)
+}
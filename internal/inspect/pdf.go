@@ -0,0 +1,65 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+)
+
+// pdfTrailerWindow is how far from the end the %%EOF marker and startxref
+// may sit; the specification asks readers to look within the last 1024 bytes.
+const pdfTrailerWindow = 1024
+
+var (
+	pdfHeader    = regexp.MustCompile(`^%PDF-[12]\.[0-9]`)
+	pdfStartXref = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF`)
+	pdfObject    = regexp.MustCompile(`^\s*\d+\s+\d+\s+obj\b`)
+)
+
+// PDFInspector checks the skeleton every PDF reader relies on: a version
+// header, a trailer ending in %%EOF and a startxref offset that points at a
+// cross-reference table or stream inside the file.
+type PDFInspector struct{}
+
+func (PDFInspector) Name() string {
+	return "pdf"
+}
+
+func (p PDFInspector) Inspect(ctx context.Context, in *Input) error {
+	if in.Type != "application/pdf" {
+		return nil
+	}
+
+	start, err := head(in, 16)
+	if err != nil {
+		return err
+	}
+	if !pdfHeader.Match(start) {
+		return in.fail(p.Name(), ErrMalformed, "PDF version header is invalid")
+	}
+
+	end, err := tail(in, pdfTrailerWindow)
+	if err != nil {
+		return err
+	}
+	matches := pdfStartXref.FindAllSubmatch(end, -1)
+	if len(matches) == 0 {
+		return in.fail(p.Name(), ErrMalformed, "PDF trailer with startxref and %%%%EOF is missing")
+	}
+
+	// Incremental updates append trailers, and the last one wins.
+	offset, err := strconv.ParseInt(string(matches[len(matches)-1][1]), 10, 64)
+	if err != nil || offset <= 0 || offset >= in.Size {
+		return in.fail(p.Name(), ErrMalformed, "PDF startxref points outside the file")
+	}
+
+	xref, err := readAt(in, offset, 64)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(xref, " \t\r\n"), []byte("xref")) && !pdfObject.Match(xref) {
+		return in.fail(p.Name(), ErrMalformed, "PDF startxref does not point at a cross-reference section")
+	}
+	return nil
+}
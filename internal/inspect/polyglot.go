@@ -0,0 +1,83 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	// eocdSearchWindow covers a zip end-of-central-directory record with the
+	// longest possible comment.
+	eocdSearchWindow = 22 + 65535
+	markupScanLength = 1024
+)
+
+var markupMarkers = [][]byte{
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<body"),
+	[]byte("<iframe"),
+	[]byte("<?php"),
+}
+
+// PolyglotInspector rejects files that are also valid as a second format:
+// a zip archive appended to an image or document, which Java and many
+// extractors open from the end, or HTML and PHP markup near the start of a
+// binary file, which browsers and misconfigured servers may execute.
+type PolyglotInspector struct{}
+
+func (PolyglotInspector) Name() string {
+	return "polyglot"
+}
+
+func (p PolyglotInspector) Inspect(ctx context.Context, in *Input) error {
+	if isZipType(in.Type) || in.Type == "image/svg+xml" || strings.HasPrefix(in.Type, "text/") {
+		return nil
+	}
+
+	end, err := tail(in, eocdSearchWindow)
+	if err != nil {
+		return err
+	}
+	if hasZipDirectory(end) {
+		return in.fail(p.Name(), ErrPolyglot, "a zip archive is appended to the file")
+	}
+
+	if !strings.HasPrefix(in.Type, "image/") && in.Type != "application/pdf" {
+		return nil
+	}
+	start, err := head(in, markupScanLength)
+	if err != nil {
+		return err
+	}
+	lower := bytes.ToLower(start)
+	for _, m := range markupMarkers {
+		if bytes.Contains(lower, m) {
+			return in.fail(p.Name(), ErrPolyglot, "the file header contains %s markup", m)
+		}
+	}
+	return nil
+}
+
+// hasZipDirectory reports an end-of-central-directory record whose comment
+// length reaches exactly to the end of the data, which random bytes matching
+// the signature almost never do.
+func hasZipDirectory(end []byte) bool {
+	for i := bytes.LastIndex(end, []byte("PK\x05\x06")); i >= 0; i = bytes.LastIndex(end[:i], []byte("PK\x05\x06")) {
+		if i+22 <= len(end) && int(binary.LittleEndian.Uint16(end[i+20:i+22])) == len(end)-i-22 {
+			return true
+		}
+	}
+	return false
+}
+
+func isZipType(typ string) bool {
+	switch typ {
+	case "application/zip", "application/java-archive", "application/epub+zip", "application/vnd.android.package-archive":
+		return true
+	}
+	return strings.HasPrefix(typ, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(typ, "application/vnd.oasis.opendocument.")
+}
@@ -0,0 +1,229 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxSVGSize bounds what is read into memory to parse an SVG file.
+const maxSVGSize = 16 << 20
+
+// activeElements run code or pull in foreign documents when an SVG is opened
+// in a browser.
+var activeElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+var activeValuePrefixes = []string{"javascript:", "vbscript:", "data:text/html"}
+
+// SVGInspector finds scripts, event handler attributes, javascript: URLs and
+// entity declarations in SVG files. It rejects such files, or with Strip set
+// removes the offending parts and keeps the rest of the document untouched.
+type SVGInspector struct {
+	Strip bool
+}
+
+func (SVGInspector) Name() string {
+	return "svg"
+}
+
+func (s SVGInspector) Inspect(ctx context.Context, in *Input) error {
+	if in.Type != "image/svg+xml" {
+		return nil
+	}
+	if in.Size > maxSVGSize {
+		return in.fail(s.Name(), ErrMalformed, "SVG is larger than %d bytes", maxSVGSize)
+	}
+
+	src, err := io.ReadAll(in.Reader())
+	if err != nil {
+		return err
+	}
+	clean, findings, err := sanitizeSVG(src)
+	if err != nil {
+		return in.fail(s.Name(), ErrMalformed, "SVG does not parse: %v", err)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	if !s.Strip {
+		return in.fail(s.Name(), ErrActiveContent, "SVG contains %s", strings.Join(findings, ", "))
+	}
+
+	in.Replace(clean)
+	return nil
+}
+
+// sanitizeSVG copies src token by token, dropping active elements, style
+// sheets that reference script, entity declarations and dangerous
+// attributes. Untouched tokens are copied byte for byte.
+func sanitizeSVG(src []byte) ([]byte, []string, error) {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	var (
+		out      bytes.Buffer
+		findings []string
+		skip     int
+		open     []xml.Name
+		inStyle  bool
+		rootSeen bool
+		prev     int64
+	)
+	found := func(f string) {
+		for _, seen := range findings {
+			if seen == f {
+				return
+			}
+		}
+		findings = append(findings, f)
+	}
+
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		end := d.InputOffset()
+		raw := src[prev:end]
+		prev = end
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+			name := strings.ToLower(t.Name.Local)
+			if !rootSeen {
+				if name != "svg" {
+					return nil, nil, fmt.Errorf("root element is <%s>", t.Name.Local)
+				}
+				rootSeen = true
+			}
+			// Self-closing elements are followed by a synthesized EndElement,
+			// so skip stays balanced either way.
+			if skip > 0 || activeElements[name] {
+				if skip == 0 {
+					found("<" + t.Name.Local + "> elements")
+				}
+				skip++
+				continue
+			}
+			inStyle = name == "style"
+
+			kept := t.Attr[:0:0]
+			for _, a := range t.Attr {
+				if f := activeAttr(a); f != "" {
+					found(f)
+					continue
+				}
+				kept = append(kept, a)
+			}
+			if len(kept) == len(t.Attr) {
+				out.Write(raw)
+				continue
+			}
+			writeStartElement(&out, t.Name, kept, bytes.HasSuffix(bytes.TrimSpace(raw), []byte("/>")))
+
+		case xml.EndElement:
+			// RawToken leaves nesting unchecked, and browsers recover from
+			// broken markup in ways a filter cannot predict.
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, nil, fmt.Errorf("unexpected </%s>", qualifiedName(t.Name))
+			}
+			open = open[:len(open)-1]
+			if skip > 0 {
+				skip--
+				continue
+			}
+			inStyle = false
+			out.Write(raw)
+
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if inStyle && activeCSS(t) {
+				found("script in style sheets")
+				continue
+			}
+			out.Write(raw)
+
+		case xml.Directive:
+			if bytes.Contains(t, []byte("ENTITY")) {
+				found("entity declarations")
+				continue
+			}
+			out.Write(raw)
+
+		default:
+			if skip == 0 {
+				out.Write(raw)
+			}
+		}
+	}
+
+	if !rootSeen {
+		return nil, nil, errors.New("no root element")
+	}
+	if len(open) > 0 {
+		return nil, nil, fmt.Errorf("<%s> is never closed", qualifiedName(open[len(open)-1]))
+	}
+	return out.Bytes(), findings, nil
+}
+
+func activeAttr(a xml.Attr) string {
+	if strings.HasPrefix(strings.ToLower(a.Name.Local), "on") {
+		return "event handler attributes"
+	}
+	value := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(a.Value))
+	for _, p := range activeValuePrefixes {
+		if strings.Contains(value, p) {
+			return p + " URLs"
+		}
+	}
+	return ""
+}
+
+func activeCSS(css []byte) bool {
+	lower := bytes.ToLower(css)
+	return bytes.Contains(lower, []byte("javascript:")) ||
+		bytes.Contains(lower, []byte("expression(")) ||
+		bytes.Contains(lower, []byte("@import"))
+}
+
+func writeStartElement(out *bytes.Buffer, name xml.Name, attrs []xml.Attr, selfClosing bool) {
+	out.WriteString("<" + qualifiedName(name))
+	for _, a := range attrs {
+		out.WriteString(" " + qualifiedName(a.Name) + `="`)
+		_ = xml.EscapeText(out, []byte(a.Value))
+		out.WriteString(`"`)
+	}
+	if selfClosing {
+		out.WriteString("/>")
+	} else {
+		out.WriteString(">")
+	}
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
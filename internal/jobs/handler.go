@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	manager *Manager
+}
+
+func NewHandler(m *Manager) *Handler {
+	return &Handler{manager: m}
+}
+
+func (h *Handler) CreateJob(c *gin.Context) {
+	var body struct {
+		Type   string          `json:"type" binding:"required"`
+		Params json.RawMessage `json:"params"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type is required"})
+		return
+	}
+	if len(body.Params) == 0 {
+		body.Params = json.RawMessage("{}")
+	}
+
+	job, err := h.manager.Submit(c.Request.Context(), body.Type, body.Params)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Header("Location", c.FullPath()+"/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *Handler) GetJob(c *gin.Context) {
+	job, err := h.manager.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *Handler) ListJobs(c *gin.Context) {
+	jobs, err := h.manager.List(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+func (h *Handler) CancelJob(c *gin.Context) {
+	job, err := h.manager.Cancel(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownJobType),
+		errors.Is(err, ErrInvalidParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an unexpected error occurred"})
+	}
+}
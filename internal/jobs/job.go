@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrUnknownJobType = errors.New("unknown job type")
+	ErrInvalidParams  = errors.New("invalid job parameters")
+	ErrJobFinished    = errors.New("job has already finished")
+)
+
+// Job is the persisted state of one background operation. Params, Progress
+// and Result are kept as raw JSON so a file-backed store can round-trip them
+// without knowing the concrete types of each job kind.
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     Status          `json:"status"`
+	Params     json.RawMessage `json:"params"`
+	Progress   json.RawMessage `json:"progress,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+func (j *Job) Finished() bool {
+	return j.Status != StatusRunning
+}
@@ -0,0 +1,281 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProgressFunc records a snapshot of a running job. The value is stored as
+// JSON and returned as-is by the status endpoint.
+type ProgressFunc func(progress any)
+
+// Runner executes one type of job. Validate is called before the job is
+// accepted so malformed requests fail synchronously. Run may be called again
+// with the same params when a job is resumed after a restart, so it must be
+// safe to repeat.
+type Runner interface {
+	Validate(params json.RawMessage) error
+	Run(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error)
+}
+
+type Manager struct {
+	store   Store
+	runners map[string]Runner
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func NewManager(store Store) *Manager {
+	return &Manager{
+		store:   store,
+		runners: make(map[string]Runner),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+func (m *Manager) Register(jobType string, runner Runner) {
+	m.runners[jobType] = runner
+}
+
+func (m *Manager) Submit(ctx context.Context, jobType string, params json.RawMessage) (*Job, error) {
+	runner, ok := m.runners[jobType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownJobType, jobType)
+	}
+	if err := runner.Validate(params); err != nil {
+		if !errors.Is(err, ErrInvalidParams) {
+			err = fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:        uuid.NewString(),
+		Type:      jobType,
+		Status:    StatusRunning,
+		Params:    params,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.store.Save(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+
+	m.start(*job, runner)
+	return job, nil
+}
+
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	return m.store.Get(ctx, id)
+}
+
+func (m *Manager) List(ctx context.Context) ([]*Job, error) {
+	return m.store.List(ctx)
+}
+
+// Cancel stops a running job. The job is marked canceled once its runner has
+// returned, so the returned snapshot may still report it as running.
+func (m *Manager) Cancel(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	cancel, running := m.cancels[id]
+	m.mu.Unlock()
+
+	if running {
+		cancel()
+		return m.store.Get(ctx, id)
+	}
+
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return nil, ErrJobFinished
+	}
+
+	// The job was left running by a previous process and has not been resumed.
+	m.finish(job, StatusCanceled, nil, context.Canceled)
+	return job, nil
+}
+
+// Resume restarts jobs that a previous process left running. It is a no-op
+// for stores that do not survive restarts.
+func (m *Manager) Resume(ctx context.Context) (int, error) {
+	jobs, err := m.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for _, job := range jobs {
+		if job.Finished() || m.isRunning(job.ID) {
+			continue
+		}
+
+		runner, ok := m.runners[job.Type]
+		if !ok {
+			m.finish(job, StatusFailed, nil, fmt.Errorf("%w: %q", ErrUnknownJobType, job.Type))
+			continue
+		}
+
+		slog.Info("resuming job", "job_id", job.ID, "type", job.Type)
+		m.start(*job, runner)
+		resumed++
+	}
+	return resumed, nil
+}
+
+// Prune deletes jobs that finished before cutoff and returns how many were
+// removed. Running jobs are always kept.
+func (m *Manager) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	jobs, err := m.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, job := range jobs {
+		if !job.Finished() || job.FinishedAt == nil || !job.FinishedAt.Before(cutoff) {
+			continue
+		}
+		if err := m.store.Delete(ctx, job.ID); err != nil {
+			return pruned, fmt.Errorf("failed to delete job %s: %w", job.ID, err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// StartPruner deletes finished jobs once they are older than retention, on
+// start and then every interval, until ctx is done. A zero retention keeps
+// every job.
+func (m *Manager) StartPruner(ctx context.Context, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		return
+	}
+
+	prune := func() {
+		pruned, err := m.Prune(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("failed to prune finished jobs", "error", err)
+		}
+		if pruned > 0 {
+			slog.Info("pruned finished jobs", "count", pruned)
+		}
+	}
+
+	go func() {
+		prune()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				prune()
+			}
+		}
+	}()
+}
+
+func (m *Manager) start(job Job, runner Runner) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	m.cancels[job.ID] = cancel
+	m.mu.Unlock()
+
+	go m.run(ctx, cancel, job, runner)
+}
+
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, job Job, runner Runner) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.cancels, job.ID)
+		m.mu.Unlock()
+		cancel()
+	}()
+
+	slog.Info("job started", "job_id", job.ID, "type", job.Type)
+
+	// mu keeps late progress updates from racing with the final save.
+	var mu sync.Mutex
+	progress := func(p any) {
+		data, err := json.Marshal(p)
+		if err != nil {
+			slog.Error("failed to encode job progress", "job_id", job.ID, "error", err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if job.Finished() {
+			return
+		}
+		job.Progress = data
+		job.UpdatedAt = time.Now().UTC()
+		m.save(&job)
+	}
+
+	result, err := runner.Run(ctx, job.Params, progress)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := StatusSucceeded
+	switch {
+	case ctx.Err() != nil:
+		status = StatusCanceled
+	case err != nil:
+		status = StatusFailed
+	}
+	m.finish(&job, status, result, err)
+}
+
+func (m *Manager) finish(job *Job, status Status, result any, err error) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+
+	if result != nil {
+		if data, mErr := json.Marshal(result); mErr == nil && string(data) != "null" {
+			job.Result = data
+		}
+	}
+	switch {
+	case status == StatusCanceled:
+		job.Error = "job was canceled"
+	case err != nil:
+		job.Error = err.Error()
+	}
+
+	m.save(job)
+	slog.Info("job finished", "job_id", job.ID, "type", job.Type, "status", job.Status, "error", job.Error)
+}
+
+func (m *Manager) save(job *Job) {
+	if err := m.store.Save(context.Background(), job); err != nil {
+		slog.Error("failed to save job", "job_id", job.ID, "error", err)
+	}
+}
+
+func (m *Manager) isRunning(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.cancels[id]
+	return ok
+}
@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type funcRunner func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error)
+
+func (f funcRunner) Validate(params json.RawMessage) error {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func (f funcRunner) Run(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+	return f(ctx, params, progress)
+}
+
+func waitForStatus(t *testing.T, m *Manager, id string, status Status) *Job {
+	t.Helper()
+
+	var job *Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = m.Get(context.Background(), id)
+		require.NoError(t, err)
+		return job.Status == status
+	}, 2*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestManager_RunsJobAndRecordsProgress(t *testing.T) {
+	m := NewManager(NewMemoryStore())
+	m.Register("greet", funcRunner(func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		progress(map[string]int{"done": 1})
+		return map[string]string{"greeting": "hello"}, nil
+	}))
+
+	job, err := m.Submit(context.Background(), "greet", json.RawMessage(`{"name":"world"}`))
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, job.Status)
+
+	job = waitForStatus(t, m, job.ID, StatusSucceeded)
+	assert.JSONEq(t, `{"done":1}`, string(job.Progress))
+	assert.JSONEq(t, `{"greeting":"hello"}`, string(job.Result))
+	assert.NotNil(t, job.FinishedAt)
+
+	_, err = m.Cancel(context.Background(), job.ID)
+	assert.ErrorIs(t, err, ErrJobFinished)
+}
+
+func TestManager_RejectsUnknownTypesAndInvalidParams(t *testing.T) {
+	m := NewManager(NewMemoryStore())
+	m.Register("greet", funcRunner(func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		return nil, nil
+	}))
+
+	_, err := m.Submit(context.Background(), "shout", json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, ErrUnknownJobType)
+
+	_, err = m.Submit(context.Background(), "greet", json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	jobs, err := m.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestManager_CancelAndFailure(t *testing.T) {
+	m := NewManager(NewMemoryStore())
+	m.Register("wait", funcRunner(func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	m.Register("fail", funcRunner(func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		return nil, errors.New("disk full")
+	}))
+
+	job, err := m.Submit(context.Background(), "wait", json.RawMessage(`{"name":"a"}`))
+	require.NoError(t, err)
+	_, err = m.Cancel(context.Background(), job.ID)
+	require.NoError(t, err)
+	job = waitForStatus(t, m, job.ID, StatusCanceled)
+	assert.NotEmpty(t, job.Error)
+
+	job, err = m.Submit(context.Background(), "fail", json.RawMessage(`{"name":"b"}`))
+	require.NoError(t, err)
+	job = waitForStatus(t, m, job.ID, StatusFailed)
+	assert.Equal(t, "disk full", job.Error)
+
+	_, err = m.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestManager_ResumesJobsFromFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	orphan := &Job{
+		ID:        "0b6a4f0e-5f38-4d3a-9f0e-3f1c2b7a9d11",
+		Type:      "greet",
+		Status:    StatusRunning,
+		Params:    json.RawMessage(`{"name":"again"}`),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	require.NoError(t, store.Save(context.Background(), orphan))
+
+	resumedWith := make(chan string, 1)
+	m := NewManager(store)
+	m.Register("greet", funcRunner(func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		resumedWith <- string(params)
+		return nil, nil
+	}))
+
+	resumed, err := m.Resume(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, resumed)
+	waitForStatus(t, m, orphan.ID, StatusSucceeded)
+	assert.JSONEq(t, `{"name":"again"}`, <-resumedWith)
+
+	reopened, err := NewFileStore(dir)
+	require.NoError(t, err)
+	job, err := reopened.Get(context.Background(), orphan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+
+	resumed, err = NewManager(reopened).Resume(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, resumed)
+}
+
+func TestManager_PrunesFinishedJobs(t *testing.T) {
+	for name, newStore := range map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+		"file": func(t *testing.T) Store {
+			store, err := NewFileStore(t.TempDir())
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			now := time.Now().UTC()
+			old := now.Add(-48 * time.Hour)
+			recent := now.Add(-time.Hour)
+
+			for _, job := range []*Job{
+				{ID: "0b6a4f0e-5f38-4d3a-9f0e-3f1c2b7a9d11", Status: StatusSucceeded, CreatedAt: old, FinishedAt: &old},
+				{ID: "1c7b5a1f-6a49-4e4b-8a1f-4a2d3c8b0e22", Status: StatusFailed, CreatedAt: recent, FinishedAt: &recent},
+				{ID: "2d8c6b2a-7b5a-4f5c-9b2a-5b3e4d9c1f33", Status: StatusRunning, CreatedAt: old},
+			} {
+				require.NoError(t, store.Save(ctx, job))
+			}
+
+			m := NewManager(store)
+			pruned, err := m.Prune(ctx, now.Add(-24*time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, pruned)
+
+			_, err = m.Get(ctx, "0b6a4f0e-5f38-4d3a-9f0e-3f1c2b7a9d11")
+			assert.ErrorIs(t, err, ErrJobNotFound)
+			jobs, err := m.List(ctx)
+			require.NoError(t, err)
+			assert.Len(t, jobs, 2, "recent and running jobs are kept")
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Store persists job state. Save is called on every state and progress change,
+// so implementations should be cheap to write to.
+type Store interface {
+	Save(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	List(ctx context.Context) ([]*Job, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, &job)
+	}
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, id)
+	return nil
+}
+
+// FileStore keeps one JSON document per job in dir. Writes go through a
+// temporary file and a rename so a crash never leaves a half-written job.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to prepare job store: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "job-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, job.ID+".json"))
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (*Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+	return s.read(filepath.Join(s.dir, id+".json"))
+}
+
+func (s *FileStore) List(ctx context.Context) ([]*Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var jobs []*Job
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		job, err := s.read(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrJobNotFound
+	}
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) read(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("corrupted job %s: %w", filepath.Base(path), err)
+	}
+	return &job, nil
+}
+
+func sortJobs(jobs []*Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID > jobs[j].ID
+	})
+}
@@ -0,0 +1,158 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultClamdTimeout = 30 * time.Second
+	// clamdChunkSize is the payload of each INSTREAM chunk. clamd accepts
+	// larger chunks, but this keeps memory flat per scan.
+	clamdChunkSize = 64 << 10
+)
+
+// Clamd scans streams with a ClamAV daemon using the INSTREAM command. It
+// opens one connection per scan, over TCP or a Unix socket.
+type Clamd struct {
+	Network string
+	Address string
+	// Timeout bounds the whole exchange, from dialing to the verdict.
+	Timeout time.Duration
+}
+
+// NewClamd parses addresses such as tcp://clamav:3310 and
+// unix:///run/clamav/clamd.ctl. A bare host:port is dialed over TCP and an
+// absolute path as a Unix socket.
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	c := &Clamd{Timeout: timeout}
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		c.Network, c.Address = "tcp", strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		c.Network, c.Address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "/"):
+		c.Network, c.Address = "unix", address
+	default:
+		c.Network, c.Address = "tcp", address
+	}
+
+	if c.Address == "" {
+		return nil, fmt.Errorf("clamd address %q has no host or path", address)
+	}
+	if c.Network == "tcp" {
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return nil, fmt.Errorf("clamd address %q: %w", address, err)
+		}
+	}
+	return c, nil
+}
+
+func (*Clamd) Name() string {
+	return "clamd"
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultClamdTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	writeErr := c.stream(conn, r)
+	var source *sourceError
+	if errors.As(writeErr, &source) {
+		return Result{}, fmt.Errorf("clamd: reading upload: %w", source.err)
+	}
+
+	// clamd replies and hangs up once a stream passes its StreamMaxLength,
+	// so the reply is read even when a write failed.
+	reply, readErr := bufio.NewReader(conn).ReadString(0)
+	if readErr != nil {
+		if ctx.Err() != nil {
+			return Result{}, fmt.Errorf("clamd: %w", ctx.Err())
+		}
+		if writeErr != nil {
+			return Result{}, fmt.Errorf("clamd: %w", writeErr)
+		}
+		return Result{}, fmt.Errorf("clamd: reading reply: %w", readErr)
+	}
+	return parseReply(reply)
+}
+
+// stream sends r as INSTREAM chunks, each prefixed with its length as a
+// 32-bit big-endian integer, and ends with an empty chunk.
+func (c *Clamd) stream(conn net.Conn, r io.Reader) error {
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := conn.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return &sourceError{err: err}
+		}
+	}
+
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseReply reads replies such as "stream: OK" and
+// "stream: Win.Test.EICAR_HDB-1 FOUND". Anything else is a clamd error;
+// "INSTREAM size limit exceeded" means the stream passed StreamMaxLength.
+func parseReply(reply string) (Result, error) {
+	msg := strings.TrimPrefix(strings.TrimRight(reply, "\x00\n"), "stream: ")
+	switch {
+	case msg == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(msg, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(msg, " FOUND")}, nil
+	case strings.Contains(msg, "size limit exceeded"):
+		return Result{}, fmt.Errorf("clamd: %s: %w", msg, ErrTooLarge)
+	default:
+		return Result{}, fmt.Errorf("clamd: %s", msg)
+	}
+}
+
+// sourceError marks a failure to read the upload itself, which leaves the
+// clamd session unfinished and without a reply.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,161 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd speaks enough of the clamd protocol to answer INSTREAM: it
+// reports EICAR as infected and rejects streams over maxStream bytes.
+type fakeClamd struct {
+	maxStream int
+	silent    bool
+	commands  chan string
+}
+
+func startFakeClamd(t *testing.T, network, address string, f *fakeClamd) net.Listener {
+	t.Helper()
+	l, err := net.Listen(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	f.commands = make(chan string, 16)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return l
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	f.commands <- strings.TrimSuffix(cmd, "\x00")
+
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if f.maxStream > 0 && data.Len()+int(size) > f.maxStream {
+			_, _ = io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			return
+		}
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			return
+		}
+	}
+
+	if f.silent {
+		_, _ = io.Copy(io.Discard, r)
+		return
+	}
+	if bytes.Contains(data.Bytes(), []byte(eicar)) {
+		_, _ = io.WriteString(conn, "stream: Win.Test.EICAR_HDB-1 FOUND\x00")
+		return
+	}
+	_, _ = io.WriteString(conn, "stream: OK\x00")
+}
+
+func TestClamd_Scan(t *testing.T) {
+	fake := &fakeClamd{}
+	l := startFakeClamd(t, "tcp", "127.0.0.1:0", fake)
+	c, err := NewClamd("tcp://"+l.Addr().String(), time.Second)
+	require.NoError(t, err)
+
+	res, err := c.Scan(context.Background(), strings.NewReader("hello world"))
+	require.NoError(t, err)
+	assert.False(t, res.Infected)
+	assert.Equal(t, "zINSTREAM", <-fake.commands)
+
+	// The signature lands across a chunk boundary.
+	payload := strings.Repeat("a", clamdChunkSize-10) + eicar
+	res, err = c.Scan(context.Background(), strings.NewReader(payload))
+	require.NoError(t, err)
+	assert.True(t, res.Infected)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", res.Signature)
+
+	res, err = c.Scan(context.Background(), strings.NewReader(""))
+	require.NoError(t, err)
+	assert.False(t, res.Infected)
+}
+
+func TestClamd_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clamd.sock")
+	startFakeClamd(t, "unix", path, &fakeClamd{})
+
+	for _, address := range []string{"unix://" + path, path} {
+		c, err := NewClamd(address, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "unix", c.Network)
+
+		res, err := c.Scan(context.Background(), strings.NewReader(eicar))
+		require.NoError(t, err)
+		assert.True(t, res.Infected)
+	}
+}
+
+func TestClamd_Failures(t *testing.T) {
+	l := startFakeClamd(t, "tcp", "127.0.0.1:0", &fakeClamd{maxStream: 1024})
+	c, err := NewClamd(l.Addr().String(), time.Second)
+	require.NoError(t, err)
+	_, err = c.Scan(context.Background(), bytes.NewReader(make([]byte, 4*clamdChunkSize)))
+	assert.ErrorIs(t, err, ErrTooLarge, "clamd hangs up once StreamMaxLength is passed")
+	assert.Contains(t, err.Error(), "INSTREAM size limit exceeded")
+
+	res, err := c.Scan(context.Background(), strings.NewReader("hello"))
+	require.NoError(t, err, "streams under the limit still get a verdict")
+	assert.False(t, res.Infected)
+
+	silent := startFakeClamd(t, "tcp", "127.0.0.1:0", &fakeClamd{silent: true})
+	c, err = NewClamd(silent.Addr().String(), 100*time.Millisecond)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = c.Scan(context.Background(), strings.NewReader("hello"))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Scan(ctx, strings.NewReader("hello"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed.Close()
+	c, err = NewClamd(closed.Addr().String(), time.Second)
+	require.NoError(t, err)
+	_, err = c.Scan(context.Background(), strings.NewReader("hello"))
+	assert.Error(t, err)
+
+	for _, address := range []string{"", "tcp://", "clamav"} {
+		_, err := NewClamd(address, time.Second)
+		assert.Error(t, err, address)
+	}
+}
@@ -0,0 +1,26 @@
+// Package scan checks uploads for malware before they are stored.
+package scan
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrTooLarge is returned when a stream is larger than the scanner accepts.
+// Retrying does not help, unlike the other scan errors.
+var ErrTooLarge = errors.New("stream exceeds the scanner's size limit")
+
+// Result is the verdict for one scanned stream. Signature names the match
+// reported by the engine when Infected is set.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner reads a stream to its end and reports whether it carries malware.
+// An error means no verdict was reached, not that the content is bad.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
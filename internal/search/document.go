@@ -0,0 +1,43 @@
+package search
+
+import (
+	"time"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Document is the searchable view of one stored object.
+type Document struct {
+	Bucket       string            `json:"bucket"`
+	Key          string            `json:"key"`
+	Name         string            `json:"name,omitempty"`
+	ContentType  string            `json:"content_type,omitempty"`
+	Size         int64             `json:"size_bytes"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	LastModified time.Time         `json:"last_modified"`
+}
+
+// Query selects documents. Text is split into terms that must all match a
+// word of the key, name, content type, tags or metadata; a term also matches
+// the words it is a prefix of. ContentType matches by prefix, so "image/"
+// selects every image. The remaining fields are exact filters.
+type Query struct {
+	Text           string
+	Bucket         string
+	ContentType    string
+	Tags           map[string]string
+	Metadata       map[string]string
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	Limit          int
+	Offset         int
+}
+
+type Results struct {
+	Total   int        `json:"total"`
+	Results []Document `json:"results"`
+}
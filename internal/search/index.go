@@ -0,0 +1,277 @@
+package search
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type docID struct {
+	bucket string
+	key    string
+}
+
+// Index is an in-memory inverted index over object documents. When opened
+// with Open every change is also appended to a journal file, so the index
+// survives restarts without rescanning storage.
+type Index struct {
+	mu      sync.RWMutex
+	docs    map[docID]Document
+	terms   map[string]map[docID]struct{}
+	journal *journal
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:  make(map[docID]Document),
+		terms: make(map[string]map[docID]struct{}),
+	}
+}
+
+// Put adds docs to the index, replacing any previous entry for the same key.
+func (ix *Index) Put(docs ...Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	for _, doc := range docs {
+		ix.put(doc)
+	}
+	return ix.record(putEntries(docs)...)
+}
+
+// Delete removes keys of bucket from the index. Unknown keys are ignored.
+func (ix *Index) Delete(bucket string, keys ...string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	for _, key := range keys {
+		ix.delete(docID{bucket, key})
+	}
+	return ix.record(deleteEntries(bucket, keys)...)
+}
+
+// ReplaceBucket swaps every document of bucket for docs. A nil docs drops
+// the bucket from the index.
+func (ix *Index) ReplaceBucket(bucket string, docs []Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.dropBucket(bucket)
+	for _, doc := range docs {
+		ix.put(doc)
+	}
+	return ix.record(append([]entry{{Op: opDrop, Bucket: bucket}}, putEntries(docs)...)...)
+}
+
+// Get returns the indexed document for key, if any.
+func (ix *Index) Get(bucket, key string) (Document, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	doc, ok := ix.docs[docID{bucket, key}]
+	return cloneDocument(doc), ok
+}
+
+// Buckets lists the buckets that have at least one indexed document.
+func (ix *Index) Buckets() []string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for id := range ix.docs {
+		seen[id.bucket] = true
+	}
+	return slices.Sorted(maps.Keys(seen))
+}
+
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docs)
+}
+
+func (ix *Index) Search(q Query) *Results {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	candidates, scores := ix.matchText(tokenize(q.Text))
+
+	var hits []Document
+	for id := range candidates {
+		doc := ix.docs[id]
+		if matchFilters(doc, q) {
+			hits = append(hits, doc)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if sa, sb := scores[docID{a.Bucket, a.Key}], scores[docID{b.Bucket, b.Key}]; sa != sb {
+			return sa > sb
+		}
+		if !a.LastModified.Equal(b.LastModified) {
+			return a.LastModified.After(b.LastModified)
+		}
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		return a.Key < b.Key
+	})
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+	offset := min(max(q.Offset, 0), len(hits))
+
+	res := &Results{Total: len(hits), Results: []Document{}}
+	for _, doc := range hits[offset:min(offset+limit, len(hits))] {
+		res.Results = append(res.Results, cloneDocument(doc))
+	}
+	return res
+}
+
+// matchText returns the documents containing every term along with a score
+// that ranks exact word matches above prefix matches. Without terms every
+// document is a candidate.
+func (ix *Index) matchText(terms []string) (map[docID]struct{}, map[docID]int) {
+	scores := make(map[docID]int)
+	if len(terms) == 0 {
+		all := make(map[docID]struct{}, len(ix.docs))
+		for id := range ix.docs {
+			all[id] = struct{}{}
+		}
+		return all, scores
+	}
+
+	var candidates map[docID]struct{}
+	for _, term := range terms {
+		matched := make(map[docID]int)
+		for word, ids := range ix.terms {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+			weight := 1
+			if word == term {
+				weight = 2
+			}
+			for id := range ids {
+				matched[id] = max(matched[id], weight)
+			}
+		}
+
+		next := make(map[docID]struct{}, len(matched))
+		for id, weight := range matched {
+			if candidates != nil {
+				if _, ok := candidates[id]; !ok {
+					continue
+				}
+			}
+			next[id] = struct{}{}
+			scores[id] += weight
+		}
+		candidates = next
+	}
+	return candidates, scores
+}
+
+func matchFilters(doc Document, q Query) bool {
+	switch {
+	case q.Bucket != "" && doc.Bucket != q.Bucket:
+		return false
+	case q.ContentType != "" && !strings.HasPrefix(strings.ToLower(doc.ContentType), strings.ToLower(q.ContentType)):
+		return false
+	case !q.ModifiedAfter.IsZero() && doc.LastModified.Before(q.ModifiedAfter):
+		return false
+	case !q.ModifiedBefore.IsZero() && !doc.LastModified.Before(q.ModifiedBefore):
+		return false
+	}
+	return containsAll(doc.Tags, q.Tags) && containsAll(doc.Metadata, q.Metadata)
+}
+
+func containsAll(have, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := have[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (ix *Index) put(doc Document) {
+	id := docID{doc.Bucket, doc.Key}
+	ix.delete(id)
+
+	ix.docs[id] = cloneDocument(doc)
+	for _, term := range documentTerms(doc) {
+		ids, ok := ix.terms[term]
+		if !ok {
+			ids = make(map[docID]struct{})
+			ix.terms[term] = ids
+		}
+		ids[id] = struct{}{}
+	}
+}
+
+func (ix *Index) delete(id docID) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	delete(ix.docs, id)
+	for _, term := range documentTerms(doc) {
+		delete(ix.terms[term], id)
+		if len(ix.terms[term]) == 0 {
+			delete(ix.terms, term)
+		}
+	}
+}
+
+func (ix *Index) dropBucket(bucket string) {
+	for id := range ix.docs {
+		if id.bucket == bucket {
+			ix.delete(id)
+		}
+	}
+}
+
+func documentTerms(doc Document) []string {
+	fields := []string{doc.Key, doc.Name, doc.ContentType}
+	for k, v := range doc.Tags {
+		fields = append(fields, k, v)
+	}
+	for k, v := range doc.Metadata {
+		fields = append(fields, k, v)
+	}
+	return tokenize(strings.Join(fields, " "))
+}
+
+// tokenize lowercases s and splits it into words of letters and digits, so
+// "reports/Q1-summary.pdf" yields "reports", "q1", "summary" and "pdf".
+func tokenize(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	terms := words[:0]
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+func cloneDocument(doc Document) Document {
+	doc.Tags = maps.Clone(doc.Tags)
+	doc.Metadata = maps.Clone(doc.Metadata)
+	return doc
+}
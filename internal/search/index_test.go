@@ -0,0 +1,120 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keys(res *Results) []string {
+	var out []string
+	for _, doc := range res.Results {
+		out = append(out, doc.Key)
+	}
+	return out
+}
+
+func TestIndex_Search(t *testing.T) {
+	now := time.Now().UTC()
+	ix := NewIndex()
+	require.NoError(t, ix.Put(
+		Document{Bucket: "docs", Key: "reports/q1-summary.pdf", ContentType: "application/pdf", LastModified: now.Add(-48 * time.Hour)},
+		Document{Bucket: "docs", Key: "a1.pdf", Name: "Summary Q2.pdf", ContentType: "application/pdf", Tags: map[string]string{"year": "2024"}, LastModified: now},
+		Document{Bucket: "media", Key: "b2.png", Name: "summer.png", ContentType: "image/png", Metadata: map[string]string{"camera": "x100"}, LastModified: now},
+	))
+
+	assert.Equal(t, []string{"a1.pdf", "reports/q1-summary.pdf"}, keys(ix.Search(Query{Text: "summary"})), "newest first on equal scores")
+	assert.Equal(t, []string{"a1.pdf", "b2.png", "reports/q1-summary.pdf"}, keys(ix.Search(Query{Text: "sum"})))
+	assert.Equal(t, []string{"reports/q1-summary.pdf"}, keys(ix.Search(Query{Text: "Q1 summary"})))
+	assert.Equal(t, []string{"a1.pdf"}, keys(ix.Search(Query{Tags: map[string]string{"year": "2024"}})))
+	assert.Equal(t, []string{"b2.png"}, keys(ix.Search(Query{Text: "x100", ContentType: "image/"})))
+	assert.Equal(t, []string{"reports/q1-summary.pdf"}, keys(ix.Search(Query{ModifiedBefore: now.Add(-time.Hour)})))
+	assert.Empty(t, ix.Search(Query{Text: "summary", Bucket: "media", Metadata: map[string]string{"camera": "x200"}}).Results)
+
+	page := ix.Search(Query{Limit: 1, Offset: 1})
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Results, 1)
+
+	require.NoError(t, ix.Delete("docs", "a1.pdf"))
+	assert.Equal(t, []string{"reports/q1-summary.pdf"}, keys(ix.Search(Query{Text: "summary"})))
+
+	require.NoError(t, ix.ReplaceBucket("docs", nil))
+	assert.Equal(t, []string{"media"}, ix.Buckets())
+}
+
+func TestIndex_JournalSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search", "index.jsonl")
+
+	ix, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, ix.Put(Document{Bucket: "docs", Key: "a.pdf"}, Document{Bucket: "docs", Key: "b.pdf"}))
+	require.NoError(t, ix.Delete("docs", "a.pdf"))
+	require.NoError(t, ix.ReplaceBucket("media", []Document{{Bucket: "media", Key: "c.png"}}))
+	require.NoError(t, ix.Close())
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"put","docs":[{"bucket":"docs"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	ix, err = Open(path)
+	require.NoError(t, err, "a torn last line is ignored")
+	defer ix.Close()
+
+	assert.Equal(t, 2, ix.Len())
+	_, ok := ix.Get("docs", "b.pdf")
+	assert.True(t, ok)
+	_, ok = ix.Get("docs", "a.pdf")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"c.png"}, keys(ix.Search(Query{Bucket: "media"})))
+}
+
+func TestIndex_JournalWritesReplaceInBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+
+	ix, err := Open(path)
+	require.NoError(t, err)
+	docs := make([]Document, 2*journalBatch+1)
+	for i := range docs {
+		docs[i] = Document{Bucket: "docs", Key: fmt.Sprintf("%05d.pdf", i)}
+	}
+	require.NoError(t, ix.Put(Document{Bucket: "docs", Key: "stale.pdf"}))
+	require.NoError(t, ix.ReplaceBucket("docs", docs))
+	require.NoError(t, ix.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 5, "one put, one drop and three batches")
+	assert.JSONEq(t, `{"op":"drop","bucket":"docs"}`, lines[1])
+
+	ix, err = Open(path)
+	require.NoError(t, err)
+	defer ix.Close()
+	assert.Equal(t, len(docs), ix.Len())
+	_, ok := ix.Get("docs", "stale.pdf")
+	assert.False(t, ok)
+}
+
+func TestIndex_OpenRefusesCorruptedJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+	journal := `{"op":"put","docs":[{"bucket":"docs","key":"a.pdf"}]}` + "\n" +
+		`{"op":"put","docs":[{"bucket":` + "\n" +
+		`{"op":"put","docs":[{"bucket":"docs","key":"b.pdf"}]}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(journal), 0o644))
+
+	_, err := Open(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, journal, string(data), "the journal is not compacted")
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opDrop   = "drop"
+
+	// journalBatch caps the documents or keys per line, so reindexing a large
+	// bucket does not write it as a single line.
+	journalBatch = 1000
+)
+
+// entry is one line of the journal.
+type entry struct {
+	Op     string     `json:"op"`
+	Bucket string     `json:"bucket,omitempty"`
+	Keys   []string   `json:"keys,omitempty"`
+	Docs   []Document `json:"docs,omitempty"`
+}
+
+// journal appends index changes as JSON lines. Writes are not synced: a lost
+// tail only means a few stale entries until the next reindex.
+type journal struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// Open loads the index stored at path, creating it if needed. The journal is
+// compacted to a single snapshot on every open so it does not grow without
+// bound across restarts.
+func Open(path string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to prepare search index: %w", err)
+	}
+
+	ix := NewIndex()
+	if err := ix.replay(path); err != nil {
+		return nil, err
+	}
+	if err := ix.compact(path); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open search index: %w", err)
+	}
+	ix.journal = &journal{f: f, w: bufio.NewWriter(f)}
+	return ix, nil
+}
+
+// Close flushes and closes the journal. It is a no-op for in-memory indexes.
+func (ix *Index) Close() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.journal == nil {
+		return nil
+	}
+	err := errors.Join(ix.journal.w.Flush(), ix.journal.f.Close())
+	ix.journal = nil
+	return err
+}
+
+func (ix *Index) record(entries ...entry) error {
+	if ix.journal == nil {
+		return nil
+	}
+
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := ix.journal.w.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write search index: %w", err)
+		}
+	}
+	return ix.journal.w.Flush()
+}
+
+func putEntries(docs []Document) []entry {
+	var entries []entry
+	for batch := range slices.Chunk(docs, journalBatch) {
+		entries = append(entries, entry{Op: opPut, Docs: batch})
+	}
+	return entries
+}
+
+func deleteEntries(bucket string, keys []string) []entry {
+	var entries []entry
+	for batch := range slices.Chunk(keys, journalBatch) {
+		entries = append(entries, entry{Op: opDelete, Bucket: bucket, Keys: batch})
+	}
+	return entries
+}
+
+func (ix *Index) replay(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open search index: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, readErr := r.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("failed to read search index: %w", readErr)
+		}
+		if len(data) == 0 {
+			return nil
+		}
+
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			// A crash can leave the last line half written; everything
+			// before it is still valid. Anything else is refused, since the
+			// compaction that follows would lose the lines after it.
+			if _, peekErr := r.Peek(1); errors.Is(peekErr, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("corrupted search index at line %d: %w", line, err)
+		}
+		switch e.Op {
+		case opPut:
+			for _, doc := range e.Docs {
+				ix.put(doc)
+			}
+		case opDelete:
+			for _, key := range e.Keys {
+				ix.delete(docID{e.Bucket, key})
+			}
+		case opDrop:
+			ix.dropBucket(e.Bucket)
+		}
+	}
+}
+
+// compact rewrites path as one put entry per document, going through a
+// temporary file and a rename so a crash keeps the previous journal.
+func (ix *Index) compact(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "index-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact search index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	ids := slices.SortedFunc(maps.Keys(ix.docs), func(a, b docID) int {
+		return cmp.Or(cmp.Compare(a.bucket, b.bucket), cmp.Compare(a.key, b.key))
+	})
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		if err := enc.Encode(entry{Op: opPut, Docs: []Document{ix.docs[id]}}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact search index: %w", err)
+		}
+	}
+	if err := errors.Join(w.Flush(), tmp.Close()); err != nil {
+		return fmt.Errorf("failed to compact search index: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -5,29 +5,65 @@ import (
 	"time"
 )
 
+const (
+	VersioningDisabled  = "Disabled"
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
 type File struct {
 	Name        string            `json:"name"`
 	URL         string            `json:"url"`
 	Content     io.ReadSeekCloser `json:"-"`
 	Size        int64             `json:"size"`
 	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
 }
 
 type FileSummary struct {
-	Key               string    `json:"key"`
-	URL               string    `json:"url"`
-	Size              int64     `json:"size_bytes"`
-	HumanReadableSize string    `json:"size_formatted"`
-	Extension         string    `json:"extension"`
-	StorageClass      string    `json:"storage_class"`
-	LastModified      time.Time `json:"last_modified"`
+	Key               string            `json:"key"`
+	URL               string            `json:"url"`
+	Size              int64             `json:"size_bytes"`
+	HumanReadableSize string            `json:"size_formatted"`
+	Extension         string            `json:"extension"`
+	StorageClass      string            `json:"storage_class"`
+	LastModified      time.Time         `json:"last_modified"`
+	ContentType       string            `json:"content_type,omitempty"`
+	OriginalName      string            `json:"original_name,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
 }
 
 type BucketStats struct {
-	BucketName         string `json:"bucket_name"`
-	TotalFiles         int    `json:"total_files"`
-	TotalSizeBytes     int64  `json:"total_size_bytes"`
-	TotalSizeFormatted string `json:"total_size_formatted"`
+	BucketName         string                    `json:"bucket_name"`
+	TotalFiles         int                       `json:"total_files"`
+	TotalSizeBytes     int64                     `json:"total_size_bytes"`
+	TotalSizeFormatted string                    `json:"total_size_formatted"`
+	Prefix             string                    `json:"prefix,omitempty"`
+	ComputedAt         time.Time                 `json:"computed_at"`
+	AverageSizeBytes   int64                     `json:"average_size_bytes"`
+	OldestObject       *StatsObject              `json:"oldest_object,omitempty"`
+	NewestObject       *StatsObject              `json:"newest_object,omitempty"`
+	ByStorageClass     map[string]StatsBreakdown `json:"by_storage_class"`
+	ByExtension        map[string]StatsBreakdown `json:"by_extension"`
+	ByPrefix           map[string]StatsBreakdown `json:"by_prefix"`
+}
+
+type StatsOptions struct {
+	Prefix  string
+	Refresh bool
+}
+
+type StatsBreakdown struct {
+	Files     int   `json:"files"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+type StatsObject struct {
+	Key          string    `json:"key"`
+	SizeBytes    int64     `json:"size_bytes"`
+	LastModified time.Time `json:"last_modified"`
 }
 
 type BucketSummary struct {
@@ -36,6 +72,207 @@ type BucketSummary struct {
 }
 
 type PaginatedFiles struct {
-	Files     []FileSummary `json:"files"`
-	NextToken string        `json:"next_token,omitempty"`
+	Files       []FileSummary `json:"files"`
+	Folders     []Folder      `json:"folders,omitempty"`
+	Prefix      string        `json:"prefix,omitempty"`
+	Breadcrumbs []Breadcrumb  `json:"breadcrumbs,omitempty"`
+	NextToken   string        `json:"next_token,omitempty"`
+}
+
+// Folder is a common prefix returned by a delimited listing.
+type Folder struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+}
+
+type Breadcrumb struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+}
+
+type ListOptions struct {
+	Prefix          string
+	Delimiter       string
+	Token           string
+	Limit           int32
+	IncludeMetadata bool
+}
+
+type ListQuery struct {
+	Prefix          string
+	Delimiter       string
+	Extension       string
+	Token           string
+	Limit           int
+	IncludeMetadata bool
+	Tags            map[string]string
+	MinSize         int64
+	MaxSize         int64
+	ModifiedAfter   time.Time
+	ModifiedBefore  time.Time
+	StorageClass    string
+	Glob            string
+	Pattern         string
+}
+
+type BatchDeleteRequest struct {
+	Keys   []string
+	Prefix string
+}
+
+type DeleteResult struct {
+	Key     string `json:"key"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BatchDeleteReport struct {
+	Bucket  string         `json:"bucket"`
+	Deleted int            `json:"deleted"`
+	Failed  int            `json:"failed"`
+	Results []DeleteResult `json:"results"`
+}
+
+// BatchProgress is the running total of a batch operation, reported after
+// each chunk.
+type BatchProgress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Failed    int `json:"failed"`
+}
+
+type BatchProgressFunc func(BatchProgress)
+
+type BulkCopyRequest struct {
+	SourceBucket      string
+	DestinationBucket string
+	Prefix            string
+}
+
+type CopyFailure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+type BulkCopyReport struct {
+	SourceBucket      string        `json:"source_bucket"`
+	DestinationBucket string        `json:"destination_bucket"`
+	Copied            int           `json:"copied"`
+	Failed            int           `json:"failed"`
+	Failures          []CopyFailure `json:"failures,omitempty"`
+}
+
+// EmptyBucketReport counts what an EmptyBucket run removed. ObjectsDeleted
+// includes noncurrent versions and delete markers.
+type EmptyBucketReport struct {
+	Bucket         string `json:"bucket"`
+	ObjectsDeleted int    `json:"objects_deleted"`
+	UploadsAborted int    `json:"uploads_aborted"`
+}
+
+// ReindexReport lists the buckets a Reindex run rebuilt and how many objects
+// are now searchable in them.
+type ReindexReport struct {
+	Buckets []string `json:"buckets"`
+	Indexed int      `json:"indexed"`
+}
+
+// EmptyProgressFunc receives the running totals after each deleted batch.
+type EmptyProgressFunc func(EmptyBucketReport)
+
+type VersionListOptions struct {
+	Prefix string
+	Token  string
+	Limit  int32
+}
+
+type ObjectVersion struct {
+	Key          string    `json:"key"`
+	VersionID    string    `json:"version_id"`
+	IsLatest     bool      `json:"is_latest"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"`
+	Size         int64     `json:"size_bytes"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type PaginatedVersions struct {
+	Versions  []ObjectVersion `json:"versions"`
+	NextToken string          `json:"next_token,omitempty"`
+}
+
+type UploadSession struct {
+	UploadID string `json:"upload_id"`
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key"`
+}
+
+type UploadedPart struct {
+	PartNumber   int32     `json:"part_number"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size,omitempty"`
+	LastModified time.Time `json:"last_modified,omitzero"`
+}
+
+type PresignedPart struct {
+	PartNumber int32     `json:"part_number"`
+	URL        string    `json:"url"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type DownloadOptions struct {
+	VersionID       string
+	Range           string
+	IfNoneMatch     string
+	IfModifiedSince time.Time
+}
+
+type ObjectInfo struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	ETag         string            `json:"etag"`
+	VersionID    string            `json:"version_id,omitempty"`
+	StorageClass string            `json:"storage_class,omitempty"`
+	OriginalName string            `json:"original_name,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	LastModified time.Time         `json:"last_modified"`
+}
+
+type Object struct {
+	Body          io.ReadCloser
+	Info          ObjectInfo
+	ContentLength int64
+	ContentRange  string
+}
+
+type PresignOptions struct {
+	VersionID          string
+	Expiration         time.Duration
+	ContentDisposition string
+	ContentType        string
+}
+
+type CopyOptions struct {
+	Metadata map[string]string
+	Tags     map[string]string
+}
+
+type UploadPresignOptions struct {
+	Method        string
+	ContentType   string
+	ContentLength int64
+	MaxSize       int64
+	Expiration    time.Duration
+}
+
+type PresignedUpload struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Bucket    string            `json:"bucket"`
+	Key       string            `json:"key"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
 }
@@ -3,9 +3,47 @@ package upload
 import "errors"
 
 var (
-	ErrBucketNameRequired  = errors.New("bucket name is required")
-	ErrFileNotFound        = errors.New("file not found in storage")
-	ErrInvalidFileType     = errors.New("file type not allowed or malicious content detected")
-	ErrBucketAlreadyExists = errors.New("bucket already exists")
-	ErrOperationTimeout    = errors.New("the operation timed out")
+	ErrBucketNameRequired   = errors.New("bucket name is required")
+	ErrBucketNotFound       = errors.New("bucket not found")
+	ErrFileNotFound         = errors.New("file not found in storage")
+	ErrInvalidFileType      = errors.New("file type not allowed or malicious content detected")
+	ErrInvalidObjectKey     = errors.New("invalid object key")
+	ErrBucketAlreadyExists  = errors.New("bucket already exists")
+	ErrOperationTimeout     = errors.New("the operation timed out")
+	ErrUploadNotFound       = errors.New("multipart upload not found")
+	ErrInvalidPartNumber    = errors.New("part number must be between 1 and 10000")
+	ErrInvalidPart          = errors.New("one or more parts could not be found or do not match")
+	ErrFileTooLarge         = errors.New("file exceeds the maximum allowed size")
+	ErrInvalidPresignMethod = errors.New("presign method must be PUT or POST")
+	ErrInvalidPresignExpiry = errors.New("presign expiry is out of range")
+	ErrUploadSizeRequired   = errors.New("size is required for presigned PUT uploads")
+	ErrInvalidDisposition   = errors.New("content disposition must be inline or attachment")
+	ErrNotModified          = errors.New("object has not been modified")
+	ErrInvalidRange         = errors.New("requested range is not satisfiable")
+	ErrInvalidMetadata      = errors.New("invalid object metadata or tags")
+	ErrVersionNotFound      = errors.New("object version not found")
+	ErrInvalidFilter        = errors.New("invalid list filter")
+	ErrSearchDisabled       = errors.New("search index is not enabled")
+	ErrInfectedFile         = errors.New("file is infected with malware")
+	ErrScanUnavailable      = errors.New("malware scanner is unavailable")
+	ErrTooLargeToScan       = errors.New("file is larger than the malware scanner accepts")
+	ErrSourceChanged        = errors.New("source object changed while it was being copied")
+	ErrSameBucket           = errors.New("source and destination bucket must differ")
 )
+
+// DownloadConditionError is returned by Download when the request's
+// conditions or range cannot be met. It wraps ErrNotModified or
+// ErrInvalidRange and carries the object's current state, which the 304 and
+// 416 responses describe.
+type DownloadConditionError struct {
+	Info ObjectInfo
+	err  error
+}
+
+func (e *DownloadConditionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *DownloadConditionError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,1189 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	fsMetaDir          = ".meta"
+	fsTempDir          = ".tmp"
+	fsUploadsDir       = ".uploads"
+	fsVersionsDir      = ".versions"
+	fsBucketConfigFile = "config.json"
+)
+
+type fsObjectMeta struct {
+	ContentType string            `json:"content_type"`
+	ETag        string            `json:"etag"`
+	VersionID   string            `json:"version_id,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+type fsVersionMeta struct {
+	fsObjectMeta
+	Key          string    `json:"key"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mod_time"`
+}
+
+type fsBucketConfig struct {
+	Versioning string `json:"versioning"`
+}
+
+type fsUploadSession struct {
+	Bucket      string            `json:"bucket"`
+	Key         string            `json:"key"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type fsObject struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+type FilesystemRepository struct {
+	root   string
+	signer urlSigner
+}
+
+func NewFilesystemRepository(root, baseURL string, secret []byte) (*FilesystemRepository, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage root: %w", err)
+	}
+
+	for _, dir := range []string{root, filepath.Join(root, fsMetaDir), filepath.Join(root, fsTempDir), filepath.Join(root, fsUploadsDir), filepath.Join(root, fsVersionsDir)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to prepare storage root: %w", err)
+		}
+	}
+
+	return &FilesystemRepository{
+		root:   root,
+		signer: newURLSigner(baseURL, secret),
+	}, nil
+}
+
+func (r *FilesystemRepository) Upload(ctx context.Context, bucket string, file *File) (string, error) {
+	meta := fsObjectMeta{ContentType: file.ContentType, Metadata: file.Metadata, Tags: file.Tags}
+	if _, err := r.writeObject(ctx, bucket, file.Name, file.Content, meta); err != nil {
+		return "", err
+	}
+	return r.signer.downloadURL(bucket, file.Name), nil
+}
+
+func (r *FilesystemRepository) GetPresignURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error) {
+	if _, _, err := r.objectPaths(bucket, key); err != nil {
+		return "", err
+	}
+	return presignLocalDownload(r.signer, bucket, key, opts), nil
+}
+
+func (r *FilesystemRepository) PresignUpload(ctx context.Context, bucket, key string, opts UploadPresignOptions) (*PresignedUpload, error) {
+	return presignLocalUpload(r.signer, bucket, key, opts)
+}
+
+func (r *FilesystemRepository) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	obj, release, err := r.openObject(bucket, key, "")
+	if err != nil {
+		return nil, err
+	}
+	return statLocalObject(obj, release, key)
+}
+
+func (r *FilesystemRepository) GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	obj, release, err := r.openObject(bucket, key, "")
+	if err != nil {
+		return nil, err
+	}
+	release()
+	return obj.tags, nil
+}
+
+func (r *FilesystemRepository) PutObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dataPath); err != nil || info.IsDir() {
+		return ErrFileNotFound
+	}
+
+	meta := r.readMeta(metaPath, key)
+	meta.Tags = tags
+	if err := r.writeJSON(metaPath, meta); err != nil {
+		return fmt.Errorf("failed to write object metadata: %w", err)
+	}
+	return nil
+}
+
+func (r *FilesystemRepository) DeleteObjectTags(ctx context.Context, bucket, key string) error {
+	return r.PutObjectTags(ctx, bucket, key, nil)
+}
+
+func (r *FilesystemRepository) Download(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error) {
+	obj, release, err := r.openObject(bucket, key, opts.VersionID)
+	if err != nil {
+		return nil, err
+	}
+	return openLocalDownload(obj, release, key, opts)
+}
+
+func (r *FilesystemRepository) List(ctx context.Context, bucket string, opts ListOptions) (*PaginatedFiles, error) {
+	objects, err := r.walk(ctx, bucket, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	keys := make([]string, len(objects))
+	byKey := make(map[string]fsObject, len(objects))
+	for i, obj := range objects {
+		keys[i] = obj.key
+		byKey[obj.key] = obj
+	}
+
+	listed, folders, next, err := groupListing(keys, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileSummary
+	for _, key := range listed {
+		obj := byKey[key]
+		summary := FileSummary{
+			Key:               obj.key,
+			Size:              obj.size,
+			HumanReadableSize: formatBytes(obj.size),
+			StorageClass:      localStorageClass,
+			LastModified:      obj.modTime,
+			Extension:         strings.ToLower(path.Ext(obj.key)),
+			URL:               r.signer.downloadURL(bucket, obj.key),
+		}
+		if opts.IncludeMetadata {
+			meta := r.readMeta(filepath.Join(r.metaDir(bucket), filepath.FromSlash(obj.key)), obj.key)
+			summary.ContentType = meta.ContentType
+			summary.Metadata = meta.Metadata
+			summary.Tags = meta.Tags
+		}
+		files = append(files, summary)
+	}
+
+	return &PaginatedFiles{Files: files, Folders: folders, NextToken: next}, nil
+}
+
+func (r *FilesystemRepository) Delete(ctx context.Context, bucket string, key string) error {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := r.requireBucket(bucket); err != nil {
+		return err
+	}
+
+	status := r.versioning(bucket)
+	if info, err := os.Stat(dataPath); err == nil && !info.IsDir() && status != VersioningDisabled {
+		if err := r.archiveCurrent(bucket, key); err != nil {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+		marker := fsVersionMeta{Key: key, DeleteMarker: true, ModTime: time.Now().UTC()}
+		marker.VersionID = newVersionID(status)
+		if err := r.addVersion(bucket, marker); err != nil {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+
+	if err := r.removeCurrent(bucket, dataPath, metaPath); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (r *FilesystemRepository) DeleteMany(ctx context.Context, bucket string, keys []string) ([]DeleteResult, error) {
+	if err := r.requireBucket(bucket); err != nil {
+		return nil, err
+	}
+	return deleteEach(ctx, r, bucket, keys)
+}
+
+func (r *FilesystemRepository) Copy(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	obj, release, err := r.openObject(srcBucket, srcKey, "")
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	meta := fsObjectMeta{ContentType: obj.contentType, Metadata: obj.metadata, Tags: obj.tags}
+	if opts.Metadata != nil {
+		meta.Metadata = opts.Metadata
+	}
+	if opts.Tags != nil {
+		meta.Tags = opts.Tags
+	}
+
+	if _, err := r.writeObject(ctx, dstBucket, dstKey, obj.content, meta); err != nil {
+		return "", err
+	}
+	return r.signer.downloadURL(dstBucket, dstKey), nil
+}
+
+func (r *FilesystemRepository) DeleteVersion(ctx context.Context, bucket, key, versionID string) error {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := r.requireBucket(bucket); err != nil {
+		return err
+	}
+	if !validVersionID(versionID) {
+		return ErrVersionNotFound
+	}
+
+	if info, err := os.Stat(dataPath); err == nil && !info.IsDir() && r.readMeta(metaPath, key).currentVersionID() == versionID {
+		if err := r.removeCurrent(bucket, dataPath, metaPath); err != nil {
+			return fmt.Errorf("failed to delete version: %w", err)
+		}
+	} else {
+		dir := r.keyVersionsDir(bucket, key)
+		err := os.Remove(filepath.Join(dir, versionID+".json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrVersionNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete version: %w", err)
+		}
+		_ = os.Remove(filepath.Join(dir, versionID+".data"))
+	}
+
+	if err := r.promote(bucket, key); err != nil {
+		return fmt.Errorf("failed to delete version: %w", err)
+	}
+	return nil
+}
+
+func (r *FilesystemRepository) ListVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error) {
+	objects, err := r.walk(ctx, bucket, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	var versions []ObjectVersion
+	for _, obj := range objects {
+		meta := r.readMeta(filepath.Join(r.metaDir(bucket), filepath.FromSlash(obj.key)), obj.key)
+		versions = append(versions, ObjectVersion{
+			Key:          obj.key,
+			VersionID:    meta.currentVersionID(),
+			Size:         obj.size,
+			ETag:         meta.ETag,
+			LastModified: obj.modTime,
+		})
+	}
+
+	entries, err := os.ReadDir(r.versionsDir(bucket))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		history, err := r.readVersions(filepath.Join(r.versionsDir(bucket), e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list versions: %w", err)
+		}
+		for _, v := range history {
+			if strings.HasPrefix(v.Key, opts.Prefix) {
+				versions = append(versions, ObjectVersion{
+					Key:          v.Key,
+					VersionID:    v.VersionID,
+					DeleteMarker: v.DeleteMarker,
+					Size:         v.Size,
+					ETag:         v.ETag,
+					LastModified: v.ModTime,
+				})
+			}
+		}
+	}
+
+	return paginateVersions(versions, opts.Token, opts.Limit)
+}
+
+func (r *FilesystemRepository) RestoreVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	obj, release, err := r.openObject(bucket, key, versionID)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	meta, err := r.writeObject(ctx, bucket, key, obj.content, fsObjectMeta{
+		ContentType: obj.contentType,
+		Metadata:    obj.metadata,
+		Tags:        obj.tags,
+	})
+	if err != nil {
+		return "", err
+	}
+	return meta.VersionID, nil
+}
+
+func (r *FilesystemRepository) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	if err := r.requireBucket(bucket); err != nil {
+		return "", err
+	}
+	return r.versioning(bucket), nil
+}
+
+func (r *FilesystemRepository) SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error {
+	if err := r.requireBucket(bucket); err != nil {
+		return err
+	}
+
+	cfg := fsBucketConfig{Versioning: VersioningSuspended}
+	if enabled {
+		cfg.Versioning = VersioningEnabled
+	}
+	if err := r.writeJSON(filepath.Join(r.versionsDir(bucket), fsBucketConfigFile), cfg); err != nil {
+		return fmt.Errorf("failed to update bucket versioning: %w", err)
+	}
+	return nil
+}
+
+func (r *FilesystemRepository) CheckBucketExists(ctx context.Context, bucket string) (bool, error) {
+	if !validLocalBucket(bucket) {
+		return false, nil
+	}
+	info, err := os.Stat(r.bucketDir(bucket))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func (r *FilesystemRepository) CreateBucket(ctx context.Context, bucket string) error {
+	if !validLocalBucket(bucket) {
+		return fmt.Errorf("invalid bucket name pattern")
+	}
+
+	if err := os.Mkdir(r.bucketDir(bucket), 0o755); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return ErrBucketAlreadyExists
+		}
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+	return os.MkdirAll(r.metaDir(bucket), 0o755)
+}
+
+func (r *FilesystemRepository) ListBuckets(ctx context.Context) ([]BucketSummary, error) {
+	entries, err := os.ReadDir(r.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []BucketSummary
+	for _, e := range entries {
+		if !e.IsDir() || !validLocalBucket(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, BucketSummary{Name: e.Name(), CreationDate: info.ModTime()})
+	}
+	return res, nil
+}
+
+func (r *FilesystemRepository) GetStats(ctx context.Context, bucket, prefix string) (*BucketStats, error) {
+	objects, err := r.walk(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := newBucketStats(bucket, prefix)
+	for _, obj := range objects {
+		stats.add(StatsObject{Key: obj.key, SizeBytes: obj.size, LastModified: obj.modTime}, localStorageClass)
+	}
+	return stats.summarize(), nil
+}
+
+func (r *FilesystemRepository) DeleteAll(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error) {
+	if err := r.requireBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	report := &EmptyBucketReport{Bucket: bucket}
+	aborted, err := r.abortUploads(bucket)
+	report.UploadsAborted = aborted
+	if err != nil {
+		return report, fmt.Errorf("failed to empty bucket: %w", err)
+	}
+
+	objects, err := r.walk(ctx, bucket, "")
+	if err != nil {
+		return report, err
+	}
+	versions, err := r.countVersions(bucket)
+	if err != nil {
+		return report, err
+	}
+
+	for _, dir := range []string{r.bucketDir(bucket), r.metaDir(bucket), r.versionsDir(bucket)} {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return report, err
+		}
+		for _, e := range entries {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if e.Name() == fsBucketConfigFile {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				return report, fmt.Errorf("failed to empty bucket: %w", err)
+			}
+		}
+	}
+
+	report.ObjectsDeleted = len(objects) + versions
+	if progress != nil {
+		progress(*report)
+	}
+	return report, nil
+}
+
+func (r *FilesystemRepository) DeleteBucket(ctx context.Context, bucket string) error {
+	if err := r.requireBucket(bucket); err != nil {
+		return err
+	}
+
+	entries, _ := os.ReadDir(r.versionsDir(bucket))
+	for _, e := range entries {
+		if e.IsDir() {
+			return fmt.Errorf("failed to delete bucket: bucket %s still has object versions", bucket)
+		}
+	}
+
+	if err := os.Remove(r.bucketDir(bucket)); err != nil {
+		return fmt.Errorf("failed to delete bucket: %w", err)
+	}
+	if err := os.RemoveAll(r.versionsDir(bucket)); err != nil {
+		return err
+	}
+	return os.RemoveAll(r.metaDir(bucket))
+}
+
+func (r *FilesystemRepository) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (string, error) {
+	if _, _, err := r.objectPaths(bucket, key); err != nil {
+		return "", err
+	}
+	if err := r.requireBucket(bucket); err != nil {
+		return "", err
+	}
+
+	uploadID := uuid.NewString()
+	dir := filepath.Join(r.root, fsUploadsDir, uploadID)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	session := fsUploadSession{Bucket: bucket, Key: key, ContentType: contentType, Metadata: metadata}
+	if err := r.writeJSON(filepath.Join(dir, "session.json"), session); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+func (r *FilesystemRepository) PresignUploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, expiration time.Duration) (string, error) {
+	if _, err := r.uploadDir(bucket, key, uploadID); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("uploadId", uploadID)
+	params.Set("partNumber", strconv.Itoa(int(partNumber)))
+	return r.signer.sign(http.MethodPut, bucket, key, time.Now().Add(expiration), params), nil
+}
+
+func (r *FilesystemRepository) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (string, error) {
+	if !validPartNumber(partNumber) {
+		return "", ErrInvalidPartNumber
+	}
+	return r.writePart(ctx, bucket, key, uploadID, partNumber, io.LimitReader(body, size))
+}
+
+func (r *FilesystemRepository) ListParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error) {
+	dir, err := r.uploadDir(bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+
+	var parts []UploadedPart
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".part")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(name, 10, 32)
+		if err != nil {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		etag, err := os.ReadFile(filepath.Join(dir, name+".etag"))
+		if err != nil {
+			continue
+		}
+
+		parts = append(parts, UploadedPart{
+			PartNumber:   int32(n),
+			ETag:         string(etag),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (r *FilesystemRepository) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error) {
+	dir, err := r.uploadDir(bucket, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	session, err := r.readSession(dir)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := r.ListParts(ctx, bucket, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	stored := make(map[int32]string, len(existing))
+	for _, p := range existing {
+		stored[p.PartNumber] = p.ETag
+	}
+	if err := checkCompletedParts(parts, stored); err != nil {
+		return "", err
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	etags := make([]string, 0, len(parts))
+	for _, p := range parts {
+		f, err := os.Open(filepath.Join(dir, partBaseName(p.PartNumber)+".part"))
+		if err != nil {
+			return "", fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+		defer f.Close()
+
+		readers = append(readers, f)
+		etags = append(etags, stored[p.PartNumber])
+	}
+
+	meta := fsObjectMeta{ContentType: session.ContentType, ETag: multipartETag(etags), Metadata: session.Metadata}
+	if _, err := r.writeObject(ctx, bucket, key, io.MultiReader(readers...), meta); err != nil {
+		return "", err
+	}
+
+	_ = os.RemoveAll(dir)
+	return r.signer.downloadURL(bucket, key), nil
+}
+
+func (r *FilesystemRepository) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	dir, err := r.uploadDir(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (r *FilesystemRepository) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	serveLocal(w, req, r.signer, r)
+}
+
+func (r *FilesystemRepository) putObject(ctx context.Context, bucket, key, contentType string, body io.Reader) (string, error) {
+	meta, err := r.writeObject(ctx, bucket, key, body, fsObjectMeta{ContentType: contentType})
+	if err != nil {
+		return "", err
+	}
+	return meta.ETag, nil
+}
+
+func (r *FilesystemRepository) writePart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.Reader) (string, error) {
+	dir, err := r.uploadDir(bucket, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(r.root, fsTempDir), "part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), readerWithContext(ctx, body))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	etag := hex.EncodeToString(hash.Sum(nil))
+	base := filepath.Join(dir, partBaseName(partNumber))
+	if err := os.Rename(tmp.Name(), base+".part"); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".etag", []byte(etag), 0o644); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+func (r *FilesystemRepository) uploadDir(bucket, key, uploadID string) (string, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", ErrUploadNotFound
+	}
+
+	dir := filepath.Join(r.root, fsUploadsDir, uploadID)
+	session, err := r.readSession(dir)
+	if err != nil {
+		return "", err
+	}
+	if session.Bucket != bucket || session.Key != key {
+		return "", ErrUploadNotFound
+	}
+	return dir, nil
+}
+
+func (r *FilesystemRepository) readSession(dir string) (*fsUploadSession, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "session.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session fsUploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("corrupted upload session: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *FilesystemRepository) writeObject(ctx context.Context, bucket, key string, body io.Reader, meta fsObjectMeta) (fsObjectMeta, error) {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return meta, err
+	}
+	if err := r.requireBucket(bucket); err != nil {
+		return meta, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(r.root, fsTempDir), "upload-*")
+	if err != nil {
+		return meta, fmt.Errorf("failed to upload: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), readerWithContext(ctx, body))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return meta, fmt.Errorf("failed to upload: %w", err)
+	}
+
+	status := r.versioning(bucket)
+	if status != VersioningDisabled {
+		if err := r.archiveCurrent(bucket, key); err != nil {
+			return meta, fmt.Errorf("failed to upload: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0o755); err != nil {
+		return meta, fmt.Errorf("failed to upload: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dataPath); err != nil {
+		return meta, fmt.Errorf("failed to upload: %w", err)
+	}
+
+	if meta.ETag == "" {
+		meta.ETag = hex.EncodeToString(hash.Sum(nil))
+	}
+	meta.VersionID = newVersionID(status)
+	if meta.VersionID == nullVersionID {
+		r.dropVersion(bucket, key, nullVersionID)
+	}
+	if err := r.writeJSON(metaPath, meta); err != nil {
+		return meta, fmt.Errorf("failed to write object metadata: %w", err)
+	}
+	return meta, nil
+}
+
+func (r *FilesystemRepository) openObject(bucket, key, versionID string) (*localObject, func(), error) {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(dataPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if versionID != "" {
+			return r.openVersion(bucket, key, versionID)
+		}
+		return nil, nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open object: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, ErrFileNotFound
+	}
+
+	meta := r.readMeta(metaPath, key)
+	if versionID != "" && versionID != meta.currentVersionID() {
+		f.Close()
+		return r.openVersion(bucket, key, versionID)
+	}
+
+	return &localObject{
+		content:     f,
+		contentType: meta.ContentType,
+		etag:        meta.ETag,
+		versionID:   meta.currentVersionID(),
+		metadata:    meta.Metadata,
+		tags:        meta.Tags,
+		modTime:     info.ModTime(),
+	}, func() { f.Close() }, nil
+}
+
+func (r *FilesystemRepository) openVersion(bucket, key, versionID string) (*localObject, func(), error) {
+	if !validVersionID(versionID) {
+		return nil, nil, ErrVersionNotFound
+	}
+
+	dir := r.keyVersionsDir(bucket, key)
+	v, err := readVersionMeta(filepath.Join(dir, versionID+".json"))
+	if err != nil || v.Key != key || v.DeleteMarker {
+		return nil, nil, ErrVersionNotFound
+	}
+
+	f, err := os.Open(filepath.Join(dir, versionID+".data"))
+	if err != nil {
+		return nil, nil, ErrVersionNotFound
+	}
+
+	return &localObject{
+		content:     f,
+		contentType: v.ContentType,
+		etag:        v.ETag,
+		versionID:   v.VersionID,
+		metadata:    v.Metadata,
+		tags:        v.Tags,
+		modTime:     v.ModTime,
+	}, func() { f.Close() }, nil
+}
+
+// archiveCurrent moves the current version of key into the bucket's version
+// history so that it survives being overwritten or deleted.
+func (r *FilesystemRepository) archiveCurrent(bucket, key string) error {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(dataPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	v := fsVersionMeta{
+		fsObjectMeta: r.readMeta(metaPath, key),
+		Key:          key,
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+	}
+	v.VersionID = v.currentVersionID()
+	if err := r.addVersion(bucket, v); err != nil {
+		return err
+	}
+	return os.Rename(dataPath, filepath.Join(r.keyVersionsDir(bucket, v.Key), v.VersionID+".data"))
+}
+
+func (r *FilesystemRepository) addVersion(bucket string, v fsVersionMeta) error {
+	if v.VersionID == nullVersionID {
+		r.dropVersion(bucket, v.Key, nullVersionID)
+	}
+	return r.writeJSON(filepath.Join(r.keyVersionsDir(bucket, v.Key), v.VersionID+".json"), v)
+}
+
+func (r *FilesystemRepository) dropVersion(bucket, key, versionID string) {
+	dir := r.keyVersionsDir(bucket, key)
+	_ = os.Remove(filepath.Join(dir, versionID+".json"))
+	_ = os.Remove(filepath.Join(dir, versionID+".data"))
+	_ = os.Remove(dir)
+}
+
+// promote makes the newest noncurrent version of key current again once the
+// current version is gone, unless that version is a delete marker.
+func (r *FilesystemRepository) promote(bucket, key string) error {
+	dataPath, metaPath, err := r.objectPaths(bucket, key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dataPath); err == nil {
+		return nil
+	}
+
+	dir := r.keyVersionsDir(bucket, key)
+	history, err := r.readVersions(dir)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		_ = os.Remove(dir)
+		return nil
+	}
+
+	latest := history[0]
+	if latest.DeleteMarker {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(dir, latest.VersionID+".data"), dataPath); err != nil {
+		return err
+	}
+	if err := r.writeJSON(metaPath, latest.fsObjectMeta); err != nil {
+		return err
+	}
+	r.dropVersion(bucket, key, latest.VersionID)
+	return nil
+}
+
+// readVersions returns the noncurrent versions stored in dir, newest first.
+func (r *FilesystemRepository) readVersions(dir string) ([]fsVersionMeta, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []fsVersionMeta
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		v, err := readVersionMeta(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].ModTime.Equal(versions[j].ModTime) {
+			return versions[i].ModTime.After(versions[j].ModTime)
+		}
+		return versions[i].VersionID > versions[j].VersionID
+	})
+	return versions, nil
+}
+
+func (r *FilesystemRepository) removeCurrent(bucket, dataPath, metaPath string) error {
+	if err := os.Remove(dataPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	_ = os.Remove(metaPath)
+
+	pruneEmptyDirs(filepath.Dir(dataPath), r.bucketDir(bucket))
+	pruneEmptyDirs(filepath.Dir(metaPath), r.metaDir(bucket))
+	return nil
+}
+
+func (r *FilesystemRepository) countVersions(bucket string) (int, error) {
+	dirs, err := os.ReadDir(r.versionsDir(bucket))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	count := 0
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		versions, err := r.readVersions(filepath.Join(r.versionsDir(bucket), d.Name()))
+		if err != nil {
+			return 0, err
+		}
+		count += len(versions)
+	}
+	return count, nil
+}
+
+func (r *FilesystemRepository) abortUploads(bucket string) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(r.root, fsUploadsDir))
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, e := range entries {
+		dir := filepath.Join(r.root, fsUploadsDir, e.Name())
+		session, err := r.readSession(dir)
+		if err != nil || session.Bucket != bucket {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return aborted, err
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+func (r *FilesystemRepository) versioning(bucket string) string {
+	var cfg fsBucketConfig
+	if data, err := os.ReadFile(filepath.Join(r.versionsDir(bucket), fsBucketConfigFile)); err == nil {
+		_ = json.Unmarshal(data, &cfg)
+	}
+	if cfg.Versioning == "" {
+		return VersioningDisabled
+	}
+	return cfg.Versioning
+}
+
+func (r *FilesystemRepository) walk(ctx context.Context, bucket, prefix string) ([]fsObject, error) {
+	if err := r.requireBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	base := r.bucketDir(bucket)
+	var objects []fsObject
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			dirKey := key + "/"
+			if rel != "." && !strings.HasPrefix(dirKey, prefix) && !strings.HasPrefix(prefix, dirKey) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, fsObject{key: key, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].key < objects[j].key })
+	return objects, nil
+}
+
+func (r *FilesystemRepository) readMeta(metaPath, key string) fsObjectMeta {
+	var meta fsObjectMeta
+	if data, err := os.ReadFile(metaPath); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = "application/octet-stream"
+	}
+	return meta
+}
+
+func (r *FilesystemRepository) requireBucket(bucket string) error {
+	exists, err := r.CheckBucketExists(context.Background(), bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+	return nil
+}
+
+func (r *FilesystemRepository) bucketDir(bucket string) string {
+	return filepath.Join(r.root, bucket)
+}
+
+func (r *FilesystemRepository) metaDir(bucket string) string {
+	return filepath.Join(r.root, fsMetaDir, bucket)
+}
+
+func (r *FilesystemRepository) versionsDir(bucket string) string {
+	return filepath.Join(r.root, fsVersionsDir, bucket)
+}
+
+// keyVersionsDir hashes the key so that the history of "a" and "a/b" never
+// share a directory.
+func (r *FilesystemRepository) keyVersionsDir(bucket, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(r.versionsDir(bucket), hex.EncodeToString(sum[:]))
+}
+
+func (r *FilesystemRepository) objectPaths(bucket, key string) (dataPath, metaPath string, err error) {
+	if !validLocalBucket(bucket) {
+		return "", "", ErrBucketNotFound
+	}
+	if !validLocalKey(key) {
+		return "", "", ErrInvalidObjectKey
+	}
+
+	rel := filepath.FromSlash(key)
+	return filepath.Join(r.bucketDir(bucket), rel), filepath.Join(r.metaDir(bucket), rel), nil
+}
+
+func pruneEmptyDirs(dir, stop string) {
+	for dir != stop && strings.HasPrefix(dir, stop) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (r *FilesystemRepository) writeJSON(p string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(r.root, fsTempDir), "meta-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (m fsObjectMeta) currentVersionID() string {
+	if m.VersionID == "" {
+		return nullVersionID
+	}
+	return m.VersionID
+}
+
+func readVersionMeta(p string) (fsVersionMeta, error) {
+	var v fsVersionMeta
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("corrupted version metadata: %w", err)
+	}
+	return v, nil
+}
+
+func validVersionID(versionID string) bool {
+	if versionID == nullVersionID {
+		return true
+	}
+	_, err := uuid.Parse(versionID)
+	return err == nil
+}
+
+func partBaseName(partNumber int32) string {
+	return fmt.Sprintf("%05d", partNumber)
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func readerWithContext(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
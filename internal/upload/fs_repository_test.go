@@ -0,0 +1,292 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFilesystemRepository(t *testing.T) *FilesystemRepository {
+	t.Helper()
+
+	repo, err := NewFilesystemRepository(t.TempDir(), "http://localhost:8080", []byte("secret"))
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateBucket(context.Background(), "my-bucket"))
+	return repo
+}
+
+func TestFilesystemRepository_UploadListAndStats(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"b.png", "a.pdf", "docs/c.png"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{
+			Name:        name,
+			Content:     readSeekCloser{strings.NewReader("content-" + name)},
+			ContentType: "image/png",
+		})
+		require.NoError(t, err)
+	}
+
+	page, err := repo.List(ctx, "my-bucket", ListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 2)
+	assert.Equal(t, "a.pdf", page.Files[0].Key)
+	assert.Equal(t, "b.png", page.Files[1].Key)
+	assert.NotEmpty(t, page.NextToken)
+
+	page, err = repo.List(ctx, "my-bucket", ListOptions{Token: page.NextToken, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 1)
+	assert.Equal(t, "docs/c.png", page.Files[0].Key)
+	assert.Empty(t, page.NextToken)
+
+	page, err = repo.List(ctx, "my-bucket", ListOptions{Prefix: "docs/", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 1)
+
+	stats, err := repo.GetStats(ctx, "my-bucket", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalFiles)
+	assert.Equal(t, int64(len("content-b.png")+len("content-a.pdf")+len("content-docs/c.png")), stats.TotalSizeBytes)
+	assert.Equal(t, stats.TotalSizeBytes/3, stats.AverageSizeBytes)
+	assert.Equal(t, StatsBreakdown{Files: 2, SizeBytes: int64(len("content-b.png") + len("content-docs/c.png"))}, stats.ByExtension[".png"])
+	assert.Equal(t, 2, stats.ByPrefix[rootPrefix].Files)
+	assert.Equal(t, 1, stats.ByPrefix["docs/"].Files)
+	assert.Equal(t, 3, stats.ByStorageClass[localStorageClass].Files)
+	require.NotNil(t, stats.OldestObject)
+	require.NotNil(t, stats.NewestObject)
+
+	stats, err = repo.GetStats(ctx, "my-bucket", "docs/")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalFiles)
+	assert.Equal(t, "docs/c.png", stats.NewestObject.Key)
+	assert.Equal(t, 1, stats.ByPrefix[rootPrefix].Files)
+}
+
+func TestFilesystemRepository_DownloadAndDelete(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.Upload(ctx, "my-bucket", &File{Name: "dir/file.txt", Content: readSeekCloser{strings.NewReader("hello")}})
+	require.NoError(t, err)
+
+	obj, err := repo.Download(ctx, "my-bucket", "dir/file.txt", DownloadOptions{})
+	require.NoError(t, err)
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	assert.Equal(t, "hello", string(data))
+
+	require.NoError(t, repo.Delete(ctx, "my-bucket", "dir/file.txt"))
+
+	_, err = repo.Download(ctx, "my-bucket", "dir/file.txt", DownloadOptions{})
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	assert.NoError(t, repo.DeleteBucket(ctx, "my-bucket"))
+}
+
+func TestFilesystemRepository_MetadataAndTags(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.Upload(ctx, "my-bucket", &File{
+		Name:        "photo.png",
+		Content:     readSeekCloser{strings.NewReader("png-bytes")},
+		ContentType: "image/png",
+		Metadata:    map[string]string{"author": "jane"},
+		Tags:        map[string]string{"project": "apollo"},
+	})
+	require.NoError(t, err)
+
+	info, err := repo.Stat(ctx, "my-bucket", "photo.png")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"author": "jane"}, info.Metadata)
+	assert.Equal(t, map[string]string{"project": "apollo"}, info.Tags)
+
+	page, err := repo.List(ctx, "my-bucket", ListOptions{IncludeMetadata: true})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 1)
+	assert.Equal(t, "image/png", page.Files[0].ContentType)
+	assert.Equal(t, "jane", page.Files[0].Metadata["author"])
+	assert.Equal(t, "apollo", page.Files[0].Tags["project"])
+
+	page, err = repo.List(ctx, "my-bucket", ListOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, page.Files[0].Metadata)
+}
+
+func TestFilesystemRepository_RejectsTraversal(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+
+	_, err := repo.Upload(context.Background(), "my-bucket", &File{Name: "../escape.txt", Content: readSeekCloser{strings.NewReader("x")}})
+	assert.ErrorIs(t, err, ErrInvalidObjectKey)
+
+	_, err = repo.Download(context.Background(), "my-bucket", "a/../../b", DownloadOptions{})
+	assert.ErrorIs(t, err, ErrInvalidObjectKey)
+}
+
+func TestFilesystemRepository_ServeSignedURL(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.Upload(ctx, "my-bucket", &File{Name: "photo.png", Content: readSeekCloser{strings.NewReader("png-bytes")}, ContentType: "image/png"})
+	require.NoError(t, err)
+
+	signed, err := repo.GetPresignURL(ctx, "my-bucket", "photo.png", PresignOptions{Expiration: time.Minute})
+	require.NoError(t, err)
+
+	handler := http.StripPrefix(LocalFilesPath, repo)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signed, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "png-bytes", rec.Body.String())
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.Replace(signed, "photo.png", "other.png", 1), nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	expired, err := repo.GetPresignURL(ctx, "my-bucket", "photo.png", PresignOptions{Expiration: -time.Minute})
+	require.NoError(t, err)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, expired, nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestFilesystemRepository_MultipartUpload(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+
+	uploadID, err := repo.CreateMultipartUpload(ctx, "my-bucket", "big/file.bin", "application/octet-stream", nil)
+	require.NoError(t, err)
+
+	var parts []UploadedPart
+	for i, chunk := range []string{"hello ", "world"} {
+		etag, err := repo.writePart(ctx, "my-bucket", "big/file.bin", uploadID, int32(i+1), strings.NewReader(chunk))
+		require.NoError(t, err)
+		parts = append(parts, UploadedPart{PartNumber: int32(i + 1), ETag: etag})
+	}
+
+	listed, err := repo.ListParts(ctx, "my-bucket", "big/file.bin", uploadID)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, int64(5), listed[1].Size)
+
+	_, err = repo.CompleteMultipartUpload(ctx, "my-bucket", "big/file.bin", uploadID, []UploadedPart{parts[1], parts[0]})
+	assert.ErrorIs(t, err, ErrInvalidPart)
+
+	_, err = repo.CompleteMultipartUpload(ctx, "my-bucket", "big/file.bin", uploadID, parts)
+	require.NoError(t, err)
+
+	obj, err := repo.Download(ctx, "my-bucket", "big/file.bin", DownloadOptions{})
+	require.NoError(t, err)
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	assert.Equal(t, "hello world", string(data))
+
+	_, err = repo.ListParts(ctx, "my-bucket", "big/file.bin", uploadID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestFilesystemRepository_Versioning(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+
+	upload := func(content string) {
+		t.Helper()
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: "doc.txt", Content: readSeekCloser{strings.NewReader(content)}})
+		require.NoError(t, err)
+	}
+	read := func(versionID string) string {
+		t.Helper()
+		obj, err := repo.Download(ctx, "my-bucket", "doc.txt", DownloadOptions{VersionID: versionID})
+		require.NoError(t, err)
+		defer obj.Body.Close()
+		data, _ := io.ReadAll(obj.Body)
+		return string(data)
+	}
+
+	upload("unversioned")
+	require.NoError(t, repo.SetBucketVersioning(ctx, "my-bucket", true))
+	status, err := repo.GetBucketVersioning(ctx, "my-bucket")
+	require.NoError(t, err)
+	assert.Equal(t, VersioningEnabled, status)
+
+	upload("first")
+	upload("second")
+
+	page, err := repo.ListVersions(ctx, "my-bucket", VersionListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Versions, 3)
+	assert.True(t, page.Versions[0].IsLatest)
+	assert.Equal(t, nullVersionID, page.Versions[2].VersionID)
+	first := page.Versions[1].VersionID
+
+	assert.Equal(t, "second", read(""))
+	assert.Equal(t, "first", read(first))
+	assert.Equal(t, "unversioned", read(nullVersionID))
+
+	require.NoError(t, repo.Delete(ctx, "my-bucket", "doc.txt"))
+	_, err = repo.Stat(ctx, "my-bucket", "doc.txt")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	page, err = repo.ListVersions(ctx, "my-bucket", VersionListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Versions, 2)
+	assert.True(t, page.Versions[0].DeleteMarker)
+	assert.NotEmpty(t, page.NextToken)
+
+	restored, err := repo.RestoreVersion(ctx, "my-bucket", "doc.txt", first)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, restored)
+	assert.Equal(t, "first", read(""))
+
+	require.NoError(t, repo.DeleteVersion(ctx, "my-bucket", "doc.txt", restored))
+	_, err = repo.Stat(ctx, "my-bucket", "doc.txt")
+	assert.ErrorIs(t, err, ErrFileNotFound, "the delete marker is current again")
+
+	assert.ErrorIs(t, repo.DeleteVersion(ctx, "my-bucket", "doc.txt", restored), ErrVersionNotFound)
+	assert.Error(t, repo.DeleteBucket(ctx, "my-bucket"))
+
+	report, err := repo.DeleteAll(ctx, "my-bucket", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.ObjectsDeleted)
+	page, err = repo.ListVersions(ctx, "my-bucket", VersionListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Versions)
+}
+
+func TestFilesystemRepository_Copy(t *testing.T) {
+	repo := newTestFilesystemRepository(t)
+	ctx := context.Background()
+	require.NoError(t, repo.CreateBucket(ctx, "other-bucket"))
+
+	_, err := repo.Upload(ctx, "my-bucket", &File{
+		Name:        "photo.png",
+		Content:     readSeekCloser{strings.NewReader("png-bytes")},
+		ContentType: "image/png",
+		Metadata:    map[string]string{"author": "jane"},
+	})
+	require.NoError(t, err)
+
+	_, err = repo.Copy(ctx, "my-bucket", "photo.png", "other-bucket", "nested/photo.png", CopyOptions{Tags: map[string]string{"copy": "yes"}})
+	require.NoError(t, err)
+
+	info, err := repo.Stat(ctx, "other-bucket", "nested/photo.png")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), info.Size)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, map[string]string{"author": "jane"}, info.Metadata)
+	assert.Equal(t, map[string]string{"copy": "yes"}, info.Tags)
+
+	_, err = repo.Copy(ctx, "my-bucket", "missing.png", "other-bucket", "x.png", CopyOptions{})
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
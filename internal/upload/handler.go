@@ -1,11 +1,17 @@
 package upload
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strconv"
+	"time"
 
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+	"github.com/JoaoOliveira889/s3-api/internal/search"
 	"github.com/gin-gonic/gin"
 )
 
@@ -32,16 +38,24 @@ func (h *Handler) UploadFile(c *gin.Context) {
 	}
 	defer openedFile.Close()
 
+	metadata, tags, err := metadataFromForm(c.Request.PostForm)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
 	file := &File{
 		Name:        fileHeader.Filename,
 		Content:     openedFile,
 		Size:        fileHeader.Size,
 		ContentType: fileHeader.Header.Get("Content-Type"),
+		Metadata:    metadata,
+		Tags:        tags,
 	}
 
-	url, err := h.service.UploadFile(c.Request.Context(), bucket, file)
+	url, err := h.service.UploadFile(requestContext(c), bucket, file)
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 
@@ -62,6 +76,12 @@ func (h *Handler) UploadMultiple(c *gin.Context) {
 		return
 	}
 
+	metadata, tags, err := metadataFromForm(form.Value)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
 	var filesToUpload []*File
 	for _, header := range filesHeaders {
 		openedFile, err := header.Open()
@@ -74,6 +94,8 @@ func (h *Handler) UploadMultiple(c *gin.Context) {
 			Content:     openedFile,
 			Size:        header.Size,
 			ContentType: header.Header.Get("Content-Type"),
+			Metadata:    metadata,
+			Tags:        tags,
 		})
 	}
 
@@ -83,9 +105,9 @@ func (h *Handler) UploadMultiple(c *gin.Context) {
 		}
 	}()
 
-	urls, err := h.service.UploadMultipleFiles(c.Request.Context(), bucket, filesToUpload)
+	urls, err := h.service.UploadMultipleFiles(requestContext(c), bucket, filesToUpload)
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 
@@ -96,9 +118,23 @@ func (h *Handler) GetPresignedURL(c *gin.Context) {
 	bucket := c.Query("bucket")
 	key := c.Query("key")
 
-	url, err := h.service.GetDownloadURL(c.Request.Context(), bucket, key)
+	opts := PresignOptions{
+		VersionID:          c.Query("version_id"),
+		ContentDisposition: c.Query("response_content_disposition"),
+		ContentType:        c.Query("response_content_type"),
+	}
+	if v := c.Query("expires_in"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be a positive number of seconds"})
+			return
+		}
+		opts.Expiration = time.Duration(seconds) * time.Second
+	}
+
+	url, err := h.service.GetDownloadURL(c.Request.Context(), bucket, key, opts)
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 
@@ -109,32 +145,242 @@ func (h *Handler) DownloadFile(c *gin.Context) {
 	bucket := c.Query("bucket")
 	key := c.Query("key")
 
-	stream, err := h.service.DownloadFile(c.Request.Context(), bucket, key)
+	opts := DownloadOptions{
+		VersionID:   c.Query("version_id"),
+		Range:       c.GetHeader("Range"),
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+	}
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil {
+		opts.IfModifiedSince = since
+	}
+
+	obj, err := h.service.DownloadFile(c.Request.Context(), bucket, key, opts)
+	var condErr *DownloadConditionError
+	if errors.As(err, &condErr) {
+		if errors.Is(err, ErrNotModified) {
+			setValidators(c, condErr.Info)
+		} else {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", condErr.Info.Size))
+		}
+	}
+	if errors.Is(err, ErrNotModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	defer obj.Body.Close()
+
+	contentType := obj.Info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	filename := obj.Info.OriginalName
+	if filename == "" {
+		filename = path.Base(key)
+	}
+
+	c.Header("Content-Disposition", contentDisposition("attachment", filename))
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(obj.ContentLength, 10))
+	c.Header("Accept-Ranges", "bytes")
+	setValidators(c, obj.Info)
+	if obj.Info.VersionID != "" {
+		c.Header("X-Version-Id", obj.Info.VersionID)
+	}
+
+	status := http.StatusOK
+	if obj.ContentRange != "" {
+		c.Header("Content-Range", obj.ContentRange)
+		status = http.StatusPartialContent
+	}
+	c.Status(status)
+
+	_, _ = io.Copy(c.Writer, obj.Body)
+}
+
+// setValidators sets the headers a client revalidates a cached download with.
+func setValidators(c *gin.Context, info ObjectInfo) {
+	if info.ETag != "" {
+		c.Header("ETag", `"`+info.ETag+`"`)
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *Handler) GetFileMetadata(c *gin.Context) {
+	info, err := h.service.GetFileMetadata(c.Request.Context(), c.Query("bucket"), c.Query("key"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if c.Request.Method != http.MethodHead {
+		c.JSON(http.StatusOK, info)
+		return
+	}
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	if info.ETag != "" {
+		c.Header("ETag", `"`+info.ETag+`"`)
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if info.StorageClass != "" {
+		c.Header("X-Storage-Class", info.StorageClass)
+	}
+	if info.VersionID != "" {
+		c.Header("X-Version-Id", info.VersionID)
+	}
+	for k, v := range info.Metadata {
+		c.Header("X-Meta-"+k, v)
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetFileTags(c *gin.Context) {
+	tags, err := h.service.GetFileTags(c.Request.Context(), c.Query("bucket"), c.Query("key"))
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+func (h *Handler) ReplaceFileTags(c *gin.Context) {
+	var body struct {
+		Bucket string            `json:"bucket" binding:"required"`
+		Key    string            `json:"key" binding:"required"`
+		Tags   map[string]string `json:"tags"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket and key are required"})
 		return
 	}
-	defer stream.Close()
 
-	c.Header("Content-Disposition", "attachment; filename="+key)
-	c.Header("Content-Type", "application/octet-stream")
+	if err := h.service.ReplaceFileTags(c.Request.Context(), body.Bucket, body.Key, body.Tags); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": body.Tags})
+}
+
+func (h *Handler) DeleteFileTags(c *gin.Context) {
+	if err := h.service.DeleteFileTags(c.Request.Context(), c.Query("bucket"), c.Query("key")); err != nil {
+		handleError(c, err)
+		return
+	}
 
-	_, _ = io.Copy(c.Writer, stream)
+	c.Status(http.StatusNoContent)
 }
 
 func (h *Handler) ListFiles(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 
-	result, err := h.service.ListFiles(
-		c.Request.Context(),
-		c.Query("bucket"),
-		c.Query("extension"),
-		c.Query("token"),
-		limit,
-	)
+	includeMetadata, _ := strconv.ParseBool(c.Query("include_metadata"))
+	tags, err := parseTagFilters(c.QueryArray("tag"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	q := ListQuery{
+		Prefix:          c.Query("prefix"),
+		Delimiter:       c.Query("delimiter"),
+		Extension:       c.Query("extension"),
+		Token:           c.Query("token"),
+		Limit:           limit,
+		IncludeMetadata: includeMetadata,
+		Tags:            tags,
+		StorageClass:    c.Query("storage_class"),
+		Glob:            c.Query("glob"),
+		Pattern:         c.Query("pattern"),
+	}
+	if err := parseRangeFilters(c, &q); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	result, err := h.service.ListFiles(c.Request.Context(), c.Query("bucket"), q)
+
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parseRangeFilters reads the size and modification time bounds of a listing.
+// Sizes are in bytes and times in RFC 3339.
+func parseRangeFilters(c *gin.Context, q *ListQuery) error {
+	for name, dst := range map[string]*int64{"min_size": &q.MinSize, "max_size": &q.MaxSize} {
+		if raw := c.Query(name); raw != "" {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: %s must be a number of bytes", ErrInvalidFilter, name)
+			}
+			*dst = n
+		}
+	}
+
+	return parseModifiedRange(c, &q.ModifiedAfter, &q.ModifiedBefore)
+}
+
+func parseModifiedRange(c *gin.Context, after, before *time.Time) error {
+	for name, dst := range map[string]*time.Time{"modified_after": after, "modified_before": before} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return fmt.Errorf("%w: %s must be an RFC 3339 timestamp", ErrInvalidFilter, name)
+			}
+			*dst = t
+		}
+	}
+	return nil
+}
+
+func (h *Handler) SearchFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
 
+	tags, err := parseTagFilters(c.QueryArray("tag"))
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
+		return
+	}
+	meta, err := parseKeyValueFilters("meta", c.QueryArray("meta"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	q := search.Query{
+		Text:        c.Query("q"),
+		Bucket:      c.Query("bucket"),
+		ContentType: c.Query("content_type"),
+		Tags:        tags,
+		Metadata:    meta,
+		Limit:       limit,
+		Offset:      offset,
+	}
+	if err := parseModifiedRange(c, &q.ModifiedAfter, &q.ModifiedBefore); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	result, err := h.service.SearchFiles(c.Request.Context(), q)
+	if err != nil {
+		handleError(c, err)
 		return
 	}
 
@@ -142,15 +388,117 @@ func (h *Handler) ListFiles(c *gin.Context) {
 }
 
 func (h *Handler) DeleteFile(c *gin.Context) {
-	err := h.service.DeleteFile(c.Request.Context(), c.Query("bucket"), c.Query("key"))
+	var err error
+	if versionID := c.Query("version_id"); versionID != "" {
+		err = h.service.DeleteFileVersion(c.Request.Context(), c.Query("bucket"), c.Query("key"), versionID)
+	} else {
+		err = h.service.DeleteFile(c.Request.Context(), c.Query("bucket"), c.Query("key"))
+	}
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
+func (h *Handler) DeleteFiles(c *gin.Context) {
+	var body struct {
+		Bucket string   `json:"bucket" binding:"required"`
+		Keys   []string `json:"keys"`
+		Prefix string   `json:"prefix"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket is required"})
+		return
+	}
+
+	report, err := h.service.DeleteFiles(c.Request.Context(), body.Bucket, BatchDeleteRequest{Keys: body.Keys, Prefix: body.Prefix}, nil)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+type copyRequest struct {
+	SourceBucket      string            `json:"source_bucket" binding:"required"`
+	SourceKey         string            `json:"source_key" binding:"required"`
+	DestinationBucket string            `json:"destination_bucket"`
+	DestinationKey    string            `json:"destination_key"`
+	Metadata          map[string]string `json:"metadata"`
+	Tags              map[string]string `json:"tags"`
+}
+
+func (h *Handler) CopyFile(c *gin.Context) {
+	h.transferFile(c, h.service.CopyFile)
+}
+
+func (h *Handler) MoveFile(c *gin.Context) {
+	h.transferFile(c, h.service.MoveFile)
+}
+
+func (h *Handler) transferFile(c *gin.Context, transfer func(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error)) {
+	var body copyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source_bucket and source_key are required"})
+		return
+	}
+
+	dstBucket := firstNonEmpty(body.DestinationBucket, body.SourceBucket)
+	dstKey := firstNonEmpty(body.DestinationKey, body.SourceKey)
+
+	url, err := transfer(c.Request.Context(), body.SourceBucket, body.SourceKey, dstBucket, dstKey, CopyOptions{
+		Metadata: body.Metadata,
+		Tags:     body.Tags,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": url, "bucket": dstBucket, "key": dstKey})
+}
+
+func (h *Handler) ListFileVersions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	result, err := h.service.ListFileVersions(c.Request.Context(), c.Query("bucket"), VersionListOptions{
+		Prefix: c.Query("prefix"),
+		Token:  c.Query("token"),
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) RestoreFileVersion(c *gin.Context) {
+	var body struct {
+		Bucket    string `json:"bucket" binding:"required"`
+		Key       string `json:"key" binding:"required"`
+		VersionID string `json:"version_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket, key and version_id are required"})
+		return
+	}
+
+	versionID, err := h.service.RestoreFileVersion(c.Request.Context(), body.Bucket, body.Key, body.VersionID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"key": body.Key, "version_id": versionID, "restored_from": body.VersionID})
+}
+
 func (h *Handler) GetBucketStats(c *gin.Context) {
 	bucket := c.Query("bucket")
 	if bucket == "" {
@@ -158,9 +506,10 @@ func (h *Handler) GetBucketStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := h.service.GetBucketStats(c.Request.Context(), bucket)
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	stats, err := h.service.GetBucketStats(c.Request.Context(), bucket, StatsOptions{Prefix: c.Query("prefix"), Refresh: refresh})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		handleError(c, err)
 		return
 	}
 
@@ -178,7 +527,7 @@ func (h *Handler) CreateBucket(c *gin.Context) {
 	}
 
 	if err := h.service.CreateBucket(c.Request.Context(), body.Name); err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 
@@ -188,7 +537,7 @@ func (h *Handler) CreateBucket(c *gin.Context) {
 func (h *Handler) ListBuckets(c *gin.Context) {
 	buckets, err := h.service.ListAllBuckets(c.Request.Context())
 	if err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, buckets)
@@ -196,7 +545,7 @@ func (h *Handler) ListBuckets(c *gin.Context) {
 
 func (h *Handler) DeleteBucket(c *gin.Context) {
 	if err := h.service.DeleteBucket(c.Request.Context(), c.Query("name")); err != nil {
-		h.handleError(c, err)
+		handleError(c, err)
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -209,27 +558,215 @@ func (h *Handler) EmptyBucket(c *gin.Context) {
 		return
 	}
 
-	err := h.service.EmptyBucket(c.Request.Context(), bucket)
+	report, err := h.service.EmptyBucket(c.Request.Context(), bucket, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) GetBucketVersioning(c *gin.Context) {
+	bucket := c.Query("bucket")
+
+	status, err := h.service.GetBucketVersioning(c.Request.Context(), bucket)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bucket": bucket, "status": status})
+}
+
+func (h *Handler) SetBucketVersioning(c *gin.Context) {
+	var body struct {
+		Bucket  string `json:"bucket" binding:"required"`
+		Enabled *bool  `json:"enabled" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket and enabled are required"})
+		return
+	}
+
+	if err := h.service.SetBucketVersioning(c.Request.Context(), body.Bucket, *body.Enabled); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	status := VersioningSuspended
+	if *body.Enabled {
+		status = VersioningEnabled
+	}
+	c.JSON(http.StatusOK, gin.H{"bucket": body.Bucket, "status": status})
+}
+
+func (h *Handler) PresignUpload(c *gin.Context) {
+	var body struct {
+		Bucket      string `json:"bucket" binding:"required"`
+		Filename    string `json:"filename" binding:"required"`
+		ContentType string `json:"content_type" binding:"required"`
+		Size        int64  `json:"size"`
+		Method      string `json:"method"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket, filename and content_type are required"})
+		return
+	}
+
+	presigned, err := h.service.PresignUpload(requestContext(c), body.Bucket, body.Filename, body.ContentType, body.Size, body.Method)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, presigned)
+}
+
+func (h *Handler) InitiateUpload(c *gin.Context) {
+	var body struct {
+		Bucket      string `json:"bucket" binding:"required"`
+		Filename    string `json:"filename" binding:"required"`
+		ContentType string `json:"content_type"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket and filename are required"})
+		return
+	}
+
+	session, err := h.service.InitiateUpload(requestContext(c), body.Bucket, body.Filename, body.ContentType)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func (h *Handler) GetUploadPartURL(c *gin.Context) {
+	partNumber, err := strconv.ParseInt(c.Param("part_number"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPartNumber.Error()})
+		return
+	}
+
+	part, err := h.service.GetUploadPartURL(
+		c.Request.Context(),
+		c.Query("bucket"),
+		c.Query("key"),
+		c.Param("upload_id"),
+		int32(partNumber),
+	)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, part)
+}
+
+func (h *Handler) ListUploadParts(c *gin.Context) {
+	parts, err := h.service.ListUploadParts(c.Request.Context(), c.Query("bucket"), c.Query("key"), c.Param("upload_id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upload_id": c.Param("upload_id"), "parts": parts})
+}
+
+func (h *Handler) CompleteUpload(c *gin.Context) {
+	var body struct {
+		Bucket string         `json:"bucket" binding:"required"`
+		Key    string         `json:"key" binding:"required"`
+		Parts  []UploadedPart `json:"parts"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket and key are required"})
+		return
+	}
+
+	url, err := h.service.CompleteUpload(c.Request.Context(), body.Bucket, body.Key, c.Param("upload_id"), body.Parts)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": url, "key": body.Key})
+}
+
+func (h *Handler) AbortUpload(c *gin.Context) {
+	err := h.service.AbortUpload(c.Request.Context(), c.Query("bucket"), c.Query("key"), c.Param("upload_id"))
+	if err != nil {
+		handleError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-func (h *Handler) handleError(c *gin.Context, err error) {
+// requestContext passes the UserHeader identity on to the service, where it
+// fills the {user} placeholder of the key template.
+func requestContext(c *gin.Context) context.Context {
+	return WithUser(c.Request.Context(), c.GetHeader(UserHeader))
+}
+
+func handleError(c *gin.Context, err error) {
+	var violation *PolicyViolation
+	var rejected *inspect.Error
 	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "inspector": rejected.Inspector})
+
+	case errors.As(err, &violation):
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrFileTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "policy": violation.Policy, "rule": violation.Rule})
+
 	case errors.Is(err, ErrInvalidFileType),
-		errors.Is(err, ErrBucketNameRequired):
+		errors.Is(err, ErrBucketNameRequired),
+		errors.Is(err, ErrInvalidObjectKey),
+		errors.Is(err, ErrInvalidPartNumber),
+		errors.Is(err, ErrInvalidPart),
+		errors.Is(err, ErrInvalidPresignMethod),
+		errors.Is(err, ErrInvalidPresignExpiry),
+		errors.Is(err, ErrUploadSizeRequired),
+		errors.Is(err, ErrInvalidDisposition),
+		errors.Is(err, ErrInvalidMetadata),
+		errors.Is(err, ErrInvalidFilter),
+		errors.Is(err, ErrSameBucket):
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 
-	case errors.Is(err, ErrBucketAlreadyExists):
+	case errors.Is(err, ErrInfectedFile):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrInvalidRange):
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrFileTooLarge), errors.Is(err, ErrTooLargeToScan):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrBucketAlreadyExists), errors.Is(err, ErrSourceChanged):
 		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 
-	case errors.Is(err, ErrFileNotFound):
+	case errors.Is(err, ErrFileNotFound),
+		errors.Is(err, ErrBucketNotFound),
+		errors.Is(err, ErrUploadNotFound),
+		errors.Is(err, ErrVersionNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 
+	case errors.Is(err, ErrSearchDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrScanUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrScanUnavailable.Error()})
+
 	case errors.Is(err, ErrOperationTimeout):
 		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out"})
 
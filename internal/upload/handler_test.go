@@ -0,0 +1,1124 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+	"github.com/JoaoOliveira889/s3-api/internal/scan"
+	"github.com/JoaoOliveira889/s3-api/internal/search"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG is a complete 1x1 grayscale PNG; tests append bytes after IEND to
+// vary the size.
+const testPNG = "\x89PNG\r\n\x1a\n" +
+	"\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x00\x00\x00\x00\x3a\x7e\x9b\x55" +
+	"\x00\x00\x00\x0aIDAT\x78\x9c\x63\x60\x00\x00\x00\x02\x00\x01\x48\xaf\xa4\x71" +
+	"\x00\x00\x00\x00IEND\xae\x42\x60\x82"
+
+const testPDF = "%PDF-1.4\n1 0 obj<<>>endobj\nxref\n0 2\ntrailer<<>>\nstartxref\n27\n%%EOF\n"
+
+func newTestRouter(t *testing.T, opts ...ServiceOption) (*gin.Engine, *MemoryRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(context.Background(), "my-bucket"))
+
+	h := NewHandler(NewService(repo, append(opts, WithSearchIndex(search.NewIndex()))...))
+	r := gin.New()
+	r.Any(LocalFilesPath+"/*path", gin.WrapH(http.StripPrefix(LocalFilesPath, repo)))
+
+	api := r.Group("/api/v1")
+	api.GET("/list", h.ListFiles)
+	api.GET("/search", h.SearchFiles)
+	api.POST("/upload", h.UploadFile)
+	api.POST("/upload-multiple", h.UploadMultiple)
+	api.GET("/download", h.DownloadFile)
+	api.GET("/presign", h.GetPresignedURL)
+	api.POST("/presign-upload", h.PresignUpload)
+	api.DELETE("/delete", h.DeleteFile)
+	api.POST("/delete-batch", h.DeleteFiles)
+	api.GET("/files/metadata", h.GetFileMetadata)
+	api.HEAD("/files/metadata", h.GetFileMetadata)
+	api.GET("/files/tags", h.GetFileTags)
+	api.PUT("/files/tags", h.ReplaceFileTags)
+	api.DELETE("/files/tags", h.DeleteFileTags)
+	api.GET("/files/versions", h.ListFileVersions)
+	api.POST("/files/restore", h.RestoreFileVersion)
+	api.POST("/files/copy", h.CopyFile)
+	api.POST("/files/move", h.MoveFile)
+	api.GET("/buckets/versioning", h.GetBucketVersioning)
+	api.PUT("/buckets/versioning", h.SetBucketVersioning)
+	api.GET("/buckets/stats", h.GetBucketStats)
+	api.DELETE("/buckets/empty", h.EmptyBucket)
+	api.POST("/uploads", h.InitiateUpload)
+	api.GET("/uploads/:upload_id/parts", h.ListUploadParts)
+	api.GET("/uploads/:upload_id/parts/:part_number", h.GetUploadPartURL)
+	api.POST("/uploads/:upload_id/complete", h.CompleteUpload)
+	api.DELETE("/uploads/:upload_id", h.AbortUpload)
+
+	return r, repo
+}
+
+func multipartBody(t *testing.T, field string, files map[string]string, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for k, v := range fields {
+		require.NoError(t, w.WriteField(k, v))
+	}
+	for name, content := range files {
+		part, err := w.CreateFormFile(field, name)
+		require.NoError(t, err)
+		_, _ = part.Write([]byte(content))
+	}
+	require.NoError(t, w.Close())
+	return body, w.FormDataContentType()
+}
+
+func doRequest(r http.Handler, method, target string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req := httptest.NewRequest(method, target, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_UploadListDownloadDelete(t *testing.T) {
+	r, _ := newTestRouter(t)
+	content := testPNG + strings.Repeat("0", 600)
+
+	body, ct := multipartBody(t, "file", map[string]string{"photo.png": content}, map[string]string{"bucket": "my-bucket"})
+	rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var page PaginatedFiles
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Files, 1)
+	key := page.Files[0].Key
+	assert.True(t, strings.HasSuffix(key, ".png"))
+	assert.NotEqual(t, "photo.png", key)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/download?bucket=my-bucket&key="+key, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, content, rec.Body.String())
+	assert.Equal(t, "attachment; filename=photo.png", rec.Header().Get("Content-Disposition"))
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/delete?bucket=my-bucket&key="+key, nil, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/download?bucket=my-bucket&key="+key, nil, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_UploadRejectsInvalidType(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	body, ct := multipartBody(t, "file", map[string]string{"script.png": "#!/bin/sh\necho hi"}, map[string]string{"bucket": "my-bucket"})
+	rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_UploadPolicies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"default": {"allowed_types": ["image/png", "application/pdf"]},
+		"rules": [{
+			"name": "avatars",
+			"bucket": "avatars",
+			"allowed_types": ["image/*"],
+			"allowed_extensions": ["png", "jpg", "jpeg"],
+			"max_size_bytes": 1024,
+			"match_sniffed_type": true
+		}]
+	}`), 0o644))
+	policies, err := LoadPolicies(file)
+	require.NoError(t, err)
+
+	r, repo := newTestRouter(t, WithPolicies(policies))
+	require.NoError(t, repo.CreateBucket(context.Background(), "avatars"))
+
+	upload := func(bucket, name, content string) (int, map[string]string) {
+		body, ct := multipartBody(t, "file", map[string]string{name: content}, map[string]string{"bucket": bucket})
+		rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+		var resp map[string]string
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	code, resp := upload("avatars", "me.png", testPNG)
+	assert.Equal(t, http.StatusCreated, code, resp)
+
+	code, resp = upload("avatars", "me.jpg", testPNG)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "avatars", resp["policy"])
+	assert.Equal(t, RuleMatchSniffedType, resp["rule"])
+
+	code, resp = upload("avatars", "me.png", testPNG+strings.Repeat("0", 2048))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Equal(t, RuleMaxSize, resp["rule"])
+
+	code, resp = upload("my-bucket", "notes.png", "just some text")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "default", resp["policy"])
+	assert.Equal(t, RuleAllowedTypes, resp["rule"])
+	assert.Contains(t, resp["error"], "text/plain")
+
+	code, _ = upload("my-bucket", "me.jpg", testPNG)
+	assert.Equal(t, http.StatusCreated, code, "the default policy does not compare extensions")
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(`{"bucket":"avatars","filename":"me.gif","content_type":"image/gif","size":100}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), RuleAllowedExtensions)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/uploads",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"clip.mp4","content_type":"video/mp4"}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), RuleAllowedTypes)
+}
+
+func TestHandler_UploadContentInspection(t *testing.T) {
+	policies := Policies{Default: Policy{AllowedTypes: []string{"image/png", "image/svg+xml", "application/zip"}}}
+	r, _ := newTestRouter(t, WithPolicies(policies), WithInspectors(inspect.DefaultChain(inspect.Options{StripSVGScripts: true})))
+
+	upload := func(name, content string) *httptest.ResponseRecorder {
+		body, ct := multipartBody(t, "file", map[string]string{name: content}, map[string]string{"bucket": "my-bucket"})
+		return doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("zeros.bin")
+	require.NoError(t, err)
+	_, _ = w.Write(make([]byte, 4<<20))
+	require.NoError(t, zw.Close())
+
+	for name, content := range map[string]string{
+		"gifar.png": testPNG + archive.String(),
+		"bomb.zip":  archive.String(),
+		"half.png":  testPNG[:30],
+	} {
+		rec := upload(name, content)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, name)
+		assert.Contains(t, rec.Body.String(), `"inspector"`, name)
+	}
+
+	rec := upload("logo.svg", `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script><circle r="4" onload="alert(2)"/></svg>`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var res struct{ URL string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	key := keyFromURL(t, res.URL)
+	rec = doRequest(r, http.MethodGet, "/api/v1/download?bucket=my-bucket&key="+key, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg"><circle r="4"/></svg>`, rec.Body.String())
+}
+
+// stubScanner flags anything containing the EICAR test string and fails
+// every scan when err is set.
+type stubScanner struct {
+	err error
+}
+
+func (*stubScanner) Name() string {
+	return "stub"
+}
+
+func (s *stubScanner) Scan(ctx context.Context, r io.Reader) (scan.Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return scan.Result{}, err
+	}
+	if s.err != nil {
+		return scan.Result{}, s.err
+	}
+	if bytes.Contains(data, []byte("EICAR-STANDARD-ANTIVIRUS-TEST-FILE")) {
+		return scan.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return scan.Result{}, nil
+}
+
+func TestHandler_MalwareScanning(t *testing.T) {
+	const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	scanner := &stubScanner{}
+	r, repo := newTestRouter(t, WithScanner(scanner))
+
+	upload := func(r *gin.Engine, name, content string) *httptest.ResponseRecorder {
+		body, ct := multipartBody(t, "file", map[string]string{name: content}, map[string]string{"bucket": "my-bucket"})
+		return doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	}
+
+	rec := upload(r, "clean.png", testPNG+"clean")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var res struct{ URL string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	key := keyFromURL(t, res.URL)
+	rec = doRequest(r, http.MethodGet, "/api/v1/download?bucket=my-bucket&key="+key, nil, "")
+	assert.Equal(t, testPNG+"clean", rec.Body.String(), "content is rewound after the scan")
+
+	rec = upload(r, "infected.png", testPNG+eicar)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Eicar-Test-Signature")
+	page, err := repo.List(context.Background(), "my-bucket", ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.Files, 1, "infected upload is never stored")
+
+	// Multipart uploads are scanned once assembled.
+	rec = doRequest(r, http.MethodPost, "/api/v1/uploads",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"parts.png"}`), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var session UploadSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	_, err = repo.writePart(context.Background(), "my-bucket", session.Key, session.UploadID, 1, strings.NewReader(testPNG+eicar))
+	require.NoError(t, err)
+	rec = doRequest(r, http.MethodPost, "/api/v1/uploads/"+session.UploadID+"/complete",
+		bytes.NewBufferString(fmt.Sprintf(`{"bucket":"my-bucket","key":%q}`, session.Key)), "application/json")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	_, err = repo.Download(context.Background(), "my-bucket", session.Key, DownloadOptions{})
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	scanner.err = errors.New("connection refused")
+	rec = upload(r, "unscanned.png", testPNG)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+
+	failOpen, _ := newTestRouter(t, WithScanner(&stubScanner{err: errors.New("timeout")}), WithScanFailOpen(true))
+	rec = upload(failOpen, "unscanned.png", testPNG)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+}
+
+func TestHandler_MalwareScanningRemovesRejectedVersion(t *testing.T) {
+	const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	r, repo := newTestRouter(t, WithScanner(&stubScanner{}))
+	require.NoError(t, repo.SetBucketVersioning(context.Background(), "my-bucket", true))
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/uploads",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"parts.png"}`), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var session UploadSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	_, err := repo.writePart(context.Background(), "my-bucket", session.Key, session.UploadID, 1, strings.NewReader(testPNG+eicar))
+	require.NoError(t, err)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/uploads/"+session.UploadID+"/complete",
+		bytes.NewBufferString(fmt.Sprintf(`{"bucket":"my-bucket","key":%q}`, session.Key)), "application/json")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	versions, err := repo.ListVersions(context.Background(), "my-bucket", VersionListOptions{Prefix: session.Key})
+	require.NoError(t, err)
+	assert.Empty(t, versions.Versions, "no noncurrent version or delete marker is left behind")
+}
+
+func TestHandler_MalwareScanningSizeLimit(t *testing.T) {
+	upload := func(r *gin.Engine, content string) *httptest.ResponseRecorder {
+		body, ct := multipartBody(t, "file", map[string]string{"photo.png": content}, map[string]string{"bucket": "my-bucket"})
+		return doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	}
+
+	r, _ := newTestRouter(t, WithScanner(&stubScanner{}), WithScanMaxSize(int64(len(testPNG))))
+	assert.Equal(t, http.StatusCreated, upload(r, testPNG).Code)
+	rec := upload(r, testPNG+"over the limit")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrTooLargeToScan.Error())
+
+	// clamd's own limit is lower than the configured one.
+	r, _ = newTestRouter(t, WithScanner(&stubScanner{err: fmt.Errorf("clamd: INSTREAM size limit exceeded. ERROR: %w", scan.ErrTooLarge)}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, upload(r, testPNG).Code)
+
+	r, _ = newTestRouter(t, WithScanner(&stubScanner{}), WithScanMaxSize(1), WithScanFailOpen(true))
+	rec = upload(r, testPNG)
+	assert.Equal(t, http.StatusCreated, rec.Code, "failing open stores files too large to scan")
+}
+
+func TestHandler_UploadMultipleAndStats(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	files := map[string]string{
+		"a.png": testPNG + "a",
+		"b.png": testPNG + "bb",
+		"c.pdf": testPDF + "ccc",
+	}
+	body, ct := multipartBody(t, "files", files, map[string]string{"bucket": "my-bucket"})
+	rec := doRequest(r, http.MethodPost, "/api/v1/upload-multiple", body, ct)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/buckets/stats?bucket=my-bucket", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats BucketStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, 3, stats.TotalFiles)
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/buckets/empty?bucket=my-bucket", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var report EmptyBucketReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 3, report.ObjectsDeleted)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket", nil, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &PaginatedFiles{}))
+	assert.NotContains(t, rec.Body.String(), ".png")
+}
+
+func TestHandler_ListFolders(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.pdf", "docs/x.pdf", "docs/y.pdf", "docs/2024/z.pdf", "logs/1.pdf"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: name, Content: readSeekCloser{strings.NewReader("%PDF-1.4")}})
+		require.NoError(t, err)
+	}
+
+	list := func(query string) PaginatedFiles {
+		t.Helper()
+		rec := doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket"+query, nil, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page PaginatedFiles
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+	keys := func(page PaginatedFiles) []string {
+		var out []string
+		for _, f := range page.Files {
+			out = append(out, f.Key)
+		}
+		return out
+	}
+
+	page := list("&delimiter=/")
+	assert.Equal(t, []string{"a.pdf"}, keys(page))
+	assert.Equal(t, []Folder{{Name: "docs", Prefix: "docs/"}, {Name: "logs", Prefix: "logs/"}}, page.Folders)
+	assert.Equal(t, []Breadcrumb{{Name: "my-bucket"}}, page.Breadcrumbs)
+
+	page = list("&prefix=docs/&delimiter=/")
+	assert.Equal(t, []string{"docs/x.pdf", "docs/y.pdf"}, keys(page))
+	assert.Equal(t, []Folder{{Name: "2024", Prefix: "docs/2024/"}}, page.Folders)
+	assert.Equal(t, []Breadcrumb{{Name: "my-bucket"}, {Name: "docs", Prefix: "docs/"}}, page.Breadcrumbs)
+	assert.Equal(t, "docs/", page.Prefix)
+
+	page = list("&delimiter=/&limit=2")
+	assert.Equal(t, []string{"a.pdf"}, keys(page))
+	assert.Equal(t, []Folder{{Name: "docs", Prefix: "docs/"}}, page.Folders)
+	require.NotEmpty(t, page.NextToken)
+
+	page = list("&delimiter=/&limit=2&token=" + page.NextToken)
+	assert.Empty(t, page.Files)
+	assert.Equal(t, []Folder{{Name: "logs", Prefix: "logs/"}}, page.Folders)
+	assert.Empty(t, page.NextToken)
+
+	page = list("&prefix=docs/")
+	assert.Len(t, page.Files, 3, "without a delimiter the listing stays flat")
+	assert.Empty(t, page.Folders)
+}
+
+func TestHandler_ListFiltersFillTheLimit(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.txt", "b.pdf", "c.txt", "d.txt", "e.txt", "f.pdf", "g.txt", "h.pdf", "reports/q1.pdf"} {
+		content := "%PDF-1.4"
+		if name == "h.pdf" {
+			content += strings.Repeat(" ", 100)
+		}
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: name, Content: readSeekCloser{strings.NewReader(content)}})
+		require.NoError(t, err)
+	}
+
+	list := func(query string) PaginatedFiles {
+		t.Helper()
+		rec := doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket"+query, nil, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page PaginatedFiles
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+	keys := func(page PaginatedFiles) []string {
+		var out []string
+		for _, f := range page.Files {
+			out = append(out, f.Key)
+		}
+		return out
+	}
+
+	// include_metadata keeps the underlying pages as small as the limit, so the
+	// matches are spread over several of them.
+	page := list("&extension=pdf&limit=2&include_metadata=true")
+	assert.Equal(t, []string{"b.pdf", "f.pdf"}, keys(page))
+	require.NotEmpty(t, page.NextToken)
+
+	page = list("&extension=pdf&limit=2&include_metadata=true&token=" + page.NextToken)
+	assert.Equal(t, []string{"h.pdf", "reports/q1.pdf"}, keys(page))
+	assert.Empty(t, page.NextToken)
+
+	page = list("&glob=*.pdf&max_size=10")
+	assert.Equal(t, []string{"b.pdf", "f.pdf"}, keys(page), "glob does not cross slashes")
+
+	page = list("&pattern=^reports/&delimiter=/")
+	assert.Empty(t, page.Files)
+	assert.Equal(t, []Folder{{Name: "reports", Prefix: "reports/"}}, page.Folders)
+
+	page = list("&modified_after=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	assert.Empty(t, page.Files)
+	assert.Empty(t, page.NextToken)
+
+	page = list("&extension=txt&limit=1&include_metadata=true")
+	require.NotEmpty(t, page.NextToken)
+	for query, want := range map[string]int{
+		"&pattern=(":                             http.StatusBadRequest,
+		"&min_size=abc":                          http.StatusBadRequest,
+		"&min_size=10&max_size=5":                http.StatusBadRequest,
+		"&modified_before=yesterday":             http.StatusBadRequest,
+		"&extension=pdf&token=" + page.NextToken: http.StatusBadRequest,
+	} {
+		rec := doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket"+query, nil, "")
+		assert.Equal(t, want, rec.Code, query)
+	}
+}
+
+func TestHandler_SearchFollowsUploadsAndDeletes(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	fields := map[string]string{"bucket": "my-bucket", "x-amz-meta-department": "finance", "x-amz-tagging": "year=2024"}
+	body, ct := multipartBody(t, "file", map[string]string{"Quarterly Report.pdf": testPDF}, fields)
+	rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	body, ct = multipartBody(t, "file", map[string]string{"holiday.png": testPNG}, map[string]string{"bucket": "my-bucket"})
+	rec = doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	find := func(query string) search.Results {
+		t.Helper()
+		rec := doRequest(r, http.MethodGet, "/api/v1/search?"+query, nil, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res search.Results
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	res := find("q=quart")
+	require.Equal(t, 1, res.Total)
+	report := res.Results[0]
+	assert.Equal(t, "Quarterly Report.pdf", report.Name)
+	assert.Equal(t, "finance", report.Metadata["department"])
+
+	assert.Equal(t, 1, find("q=finance&tag=year:2024").Total)
+	assert.Equal(t, 1, find("q=png&bucket=my-bucket").Total)
+	assert.Equal(t, 2, find("content_type=application/&limit=1").Total)
+	assert.Equal(t, 2, find("").Total)
+	assert.Zero(t, find("q=report&meta=department:legal").Total)
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/delete?bucket=my-bucket&key="+report.Key, nil, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Zero(t, find("q=quarterly").Total)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/search?meta=department", nil, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_StatsCacheIsMaintainedIncrementally(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+
+	stats := func(query string) BucketStats {
+		t.Helper()
+		rec := doRequest(r, http.MethodGet, "/api/v1/buckets/stats?bucket=my-bucket"+query, nil, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var s BucketStats
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		return s
+	}
+	upload := func(name, content string) string {
+		t.Helper()
+		body, ct := multipartBody(t, "file", map[string]string{name: content}, map[string]string{"bucket": "my-bucket"})
+		rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var res struct{ URL string }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return keyFromURL(t, res.URL)
+	}
+
+	upload("a.png", testPNG+"a")
+	first := stats("")
+	assert.Equal(t, 1, first.TotalFiles)
+	assert.False(t, first.ComputedAt.IsZero())
+
+	middle := upload("b.png", testPNG+"bb")
+	upload("c.pdf", testPDF+"ccc")
+	cached := stats("")
+	assert.Equal(t, 3, cached.TotalFiles)
+	assert.Equal(t, int64(len(testPNG)*2+3+len(testPDF)+3), cached.TotalSizeBytes)
+	assert.Equal(t, 1, cached.ByExtension[".pdf"].Files)
+	assert.Equal(t, first.ComputedAt, cached.ComputedAt, "served from the cache")
+
+	rec := doRequest(r, http.MethodDelete, "/api/v1/delete?bucket=my-bucket&key="+middle, nil, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	cached = stats("")
+	assert.Equal(t, 2, cached.TotalFiles)
+	assert.Equal(t, 1, cached.ByExtension[".png"].Files)
+	assert.Equal(t, first.ComputedAt, cached.ComputedAt)
+
+	_, err := repo.Upload(ctx, "my-bucket", &File{Name: "outside.pdf", Content: readSeekCloser{strings.NewReader("%PDF-1.4")}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats("").TotalFiles, "writes outside the service wait for a rescan")
+
+	refreshed := stats("&refresh=true")
+	assert.Equal(t, 3, refreshed.TotalFiles)
+	assert.True(t, refreshed.ComputedAt.After(first.ComputedAt))
+}
+
+func TestHandler_PresignedURLIsServed(t *testing.T) {
+	r, repo := newTestRouter(t)
+
+	_, err := repo.Upload(context.Background(), "my-bucket", &File{
+		Name:        "doc.pdf",
+		Content:     readSeekCloser{strings.NewReader("%PDF-1.4")},
+		ContentType: "application/pdf",
+	})
+	require.NoError(t, err)
+
+	rec := doRequest(r, http.MethodGet, "/api/v1/presign?bucket=my-bucket&key=doc.pdf", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res struct {
+		URL string `json:"presigned_url"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	rec = doRequest(r, http.MethodGet, strings.TrimPrefix(res.URL, "http://localhost:8080"), nil, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "%PDF-1.4", rec.Body.String())
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/presign?bucket=my-bucket&key=doc.pdf&expires_in=60"+
+		"&response_content_disposition=attachment%3B+filename%3Dreport.pdf&response_content_type=application/octet-stream", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	rec = doRequest(r, http.MethodGet, strings.TrimPrefix(res.URL, "http://localhost:8080"), nil, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "attachment; filename=report.pdf", rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/presign?bucket=my-bucket&key=doc.pdf&expires_in=99999999", nil, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_ResumableMultipartUpload(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/uploads",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"video.png","content_type":"image/png"}`), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var session UploadSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	assert.True(t, strings.HasSuffix(session.Key, ".png"))
+	query := "?bucket=my-bucket&key=" + session.Key
+
+	chunks := []string{testPNG + "first-", "second"}
+	for i, chunk := range chunks {
+		rec = doRequest(r, http.MethodGet, fmt.Sprintf("/api/v1/uploads/%s/parts/%d%s", session.UploadID, i+1, query), nil, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var part PresignedPart
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &part))
+
+		rec = doRequest(r, http.MethodPut, strings.TrimPrefix(part.URL, "http://localhost:8080"), bytes.NewBufferString(chunk), "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+	}
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/uploads/"+session.UploadID+"/parts"+query, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"part_number":2`)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/uploads/"+session.UploadID+"/complete",
+		bytes.NewBufferString(fmt.Sprintf(`{"bucket":"my-bucket","key":%q}`, session.Key)), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/download"+query, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, strings.Join(chunks, ""), rec.Body.String())
+	assert.Equal(t, "attachment; filename=video.png", rec.Header().Get("Content-Disposition"))
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/uploads/"+session.UploadID+query, nil, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_ResumableUploadRejectsInvalidContent(t *testing.T) {
+	r, repo := newTestRouter(t)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/uploads",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"fake.png"}`), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var session UploadSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+
+	_, err := repo.writePart(context.Background(), "my-bucket", session.Key, session.UploadID, 1, strings.NewReader("<?php echo 1; ?>"))
+	require.NoError(t, err)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/uploads/"+session.UploadID+"/complete",
+		bytes.NewBufferString(fmt.Sprintf(`{"bucket":"my-bucket","key":%q}`, session.Key)), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, err = repo.Download(context.Background(), "my-bucket", session.Key, DownloadOptions{})
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
+
+func TestHandler_PresignUploadPut(t *testing.T) {
+	r, repo := newTestRouter(t)
+	content := testPNG + "direct"
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(fmt.Sprintf(`{"bucket":"my-bucket","filename":"photo.png","content_type":"image/png","size":%d}`, len(content))), "application/json")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var presigned PresignedUpload
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &presigned))
+	assert.Equal(t, http.MethodPut, presigned.Method)
+	assert.True(t, strings.HasSuffix(presigned.Key, ".png"))
+	target := strings.TrimPrefix(presigned.URL, "http://localhost:8080")
+
+	rec = doRequest(r, http.MethodPut, target, bytes.NewBufferString(content), "application/pdf")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = doRequest(r, http.MethodPut, target, bytes.NewBufferString(content+"more"), presigned.Headers["Content-Type"])
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the body must match the signed size")
+
+	rec = doRequest(r, http.MethodPut, target, bytes.NewBufferString(content), presigned.Headers["Content-Type"])
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	obj, err := repo.Download(context.Background(), "my-bucket", presigned.Key, DownloadOptions{})
+	require.NoError(t, err)
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	assert.Equal(t, content, string(data))
+}
+
+func TestHandler_PresignUploadPost(t *testing.T) {
+	r, repo := newTestRouter(t)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"doc.pdf","content_type":"application/pdf","method":"post"}`), "application/json")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var presigned PresignedUpload
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &presigned))
+	assert.Equal(t, http.MethodPost, presigned.Method)
+	assert.Equal(t, presigned.Key, presigned.Fields["key"])
+
+	body, ct := multipartBody(t, "file", map[string]string{"doc.pdf": "%PDF-1.4"}, presigned.Fields)
+	rec = doRequest(r, http.MethodPost, strings.TrimPrefix(presigned.URL, "http://localhost:8080"), body, ct)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	_, err := repo.Download(context.Background(), "my-bucket", presigned.Key, DownloadOptions{})
+	assert.NoError(t, err)
+}
+
+func TestHandler_PresignUploadRejectsDisallowedRequests(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"run.sh","content_type":"text/x-shellscript"}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"huge.png","content_type":"image/png","size":10000000000000}`), "application/json")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"a.png","content_type":"image/png","method":"DELETE"}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/presign-upload",
+		bytes.NewBufferString(`{"bucket":"my-bucket","filename":"a.png","content_type":"image/png"}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "a PUT without size would accept any length")
+	assert.Contains(t, rec.Body.String(), ErrUploadSizeRequired.Error())
+}
+
+// keyFromURL returns the object key of a URL the local backends return.
+func keyFromURL(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return path.Base(u.Path)
+}
+
+func TestHandler_UploadReturnsFetchableURL(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	body, ct := multipartBody(t, "file", map[string]string{"a.png": testPNG + "a"}, map[string]string{"bucket": "my-bucket"})
+	rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var res struct{ URL string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	rec = doRequest(r, http.MethodGet, strings.TrimPrefix(res.URL, "http://localhost:8080"), nil, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, testPNG+"a", rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Files []struct{ URL string } `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Files, 1)
+	rec = doRequest(r, http.MethodGet, strings.TrimPrefix(list.Files[0].URL, "http://localhost:8080"), nil, "")
+	assert.Equal(t, http.StatusOK, rec.Code, "listed URLs are signed too")
+}
+
+func TestHandler_DownloadRangeAndConditional(t *testing.T) {
+	r, repo := newTestRouter(t)
+
+	_, err := repo.Upload(context.Background(), "my-bucket", &File{
+		Name:        "clip.pdf",
+		Content:     readSeekCloser{strings.NewReader("%PDF-0123456789")},
+		ContentType: "application/pdf",
+	})
+	require.NoError(t, err)
+	target := "/api/v1/download?bucket=my-bucket&key=clip.pdf"
+
+	download := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := download(nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "15", rec.Header().Get("Content-Length"))
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+
+	rec = download(map[string]string{"Range": "bytes=5-9"})
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "01234", rec.Body.String())
+	assert.Equal(t, "bytes 5-9/15", rec.Header().Get("Content-Range"))
+
+	rec = download(map[string]string{"Range": "bytes=-3"})
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "789", rec.Body.String())
+
+	rec = download(map[string]string{"Range": "bytes=100-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	assert.Equal(t, "bytes */15", rec.Header().Get("Content-Range"))
+
+	rec = download(map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	assert.Equal(t, lastModified, rec.Header().Get("Last-Modified"))
+
+	rec = download(map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = download(map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestHandler_FileMetadata(t *testing.T) {
+	r, repo := newTestRouter(t)
+
+	_, err := repo.Upload(context.Background(), "my-bucket", &File{
+		Name:        "doc.pdf",
+		Content:     readSeekCloser{strings.NewReader("%PDF-1.4")},
+		ContentType: "application/pdf",
+	})
+	require.NoError(t, err)
+
+	rec := doRequest(r, http.MethodGet, "/api/v1/files/metadata?bucket=my-bucket&key=doc.pdf", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var info ObjectInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, "doc.pdf", info.Key)
+	assert.Equal(t, int64(8), info.Size)
+	assert.Equal(t, "application/pdf", info.ContentType)
+	assert.NotEmpty(t, info.ETag)
+	assert.False(t, info.LastModified.IsZero())
+
+	rec = doRequest(r, http.MethodHead, "/api/v1/files/metadata?bucket=my-bucket&key=doc.pdf", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, "8", rec.Header().Get("Content-Length"))
+	assert.Equal(t, `"`+info.ETag+`"`, rec.Header().Get("ETag"))
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/files/metadata?bucket=my-bucket&key=missing.pdf", nil, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_UploadWithMetadataAndTags(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	body, ct := multipartBody(t, "file", map[string]string{"photo.png": testPNG + "data"}, map[string]string{
+		"bucket":            "my-bucket",
+		"x-amz-meta-author": "jane",
+		"x-amz-tagging":     "project=apollo&stage=draft",
+	})
+	rec := doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket&include_metadata=true", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var page PaginatedFiles
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Files, 1)
+	assert.Equal(t, map[string]string{"author": "jane"}, page.Files[0].Metadata)
+	assert.Equal(t, map[string]string{"project": "apollo", "stage": "draft"}, page.Files[0].Tags)
+	assert.Equal(t, "photo.png", page.Files[0].OriginalName)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/files/metadata?bucket=my-bucket&key="+page.Files[0].Key, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"author":"jane"`)
+	assert.Contains(t, rec.Body.String(), `"original_name":"photo.png"`)
+	assert.Contains(t, rec.Body.String(), `"project":"apollo"`)
+
+	body, ct = multipartBody(t, "file", map[string]string{"photo.png": testPNG + "data"}, map[string]string{
+		"bucket":              "my-bucket",
+		"x-amz-meta-Bad Key!": "x",
+	})
+	rec = doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	body, ct = multipartBody(t, "file", map[string]string{"photo.png": testPNG + "data"}, map[string]string{
+		"bucket":        "my-bucket",
+		"x-amz-tagging": "aws:owner=me",
+	})
+	rec = doRequest(r, http.MethodPost, "/api/v1/upload", body, ct)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_FileTags(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"contract.pdf", "invoice.pdf"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: name, Content: readSeekCloser{strings.NewReader("%PDF-1.4")}})
+		require.NoError(t, err)
+	}
+
+	rec := doRequest(r, http.MethodPut, "/api/v1/files/tags",
+		bytes.NewBufferString(`{"bucket":"my-bucket","key":"contract.pdf","tags":{"retention":"legal","team":"ops"}}`), "application/json")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/files/tags?bucket=my-bucket&key=contract.pdf", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"tags":{"retention":"legal","team":"ops"}}`, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket&tag=retention:legal&tag=team:ops", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page PaginatedFiles
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Files, 1)
+	assert.Equal(t, "contract.pdf", page.Files[0].Key)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/list?bucket=my-bucket&tag=retention", nil, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/files/tags?bucket=my-bucket&key=contract.pdf", nil, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/files/tags?bucket=my-bucket&key=contract.pdf", nil, "")
+	assert.JSONEq(t, `{"tags":{}}`, rec.Body.String())
+
+	rec = doRequest(r, http.MethodPut, "/api/v1/files/tags",
+		bytes.NewBufferString(`{"bucket":"my-bucket","key":"missing.pdf","tags":{"a":"b"}}`), "application/json")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_Versioning(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+
+	rec := doRequest(r, http.MethodPut, "/api/v1/buckets/versioning",
+		bytes.NewBufferString(`{"bucket":"my-bucket","enabled":true}`), "application/json")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/buckets/versioning?bucket=my-bucket", nil, "")
+	assert.JSONEq(t, `{"bucket":"my-bucket","status":"Enabled"}`, rec.Body.String())
+
+	for _, content := range []string{"%PDF-1.4 v1", "%PDF-1.4 v2"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: "doc.pdf", Content: readSeekCloser{strings.NewReader(content)}})
+		require.NoError(t, err)
+	}
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/files/versions?bucket=my-bucket&prefix=doc", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page PaginatedVersions
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Versions, 2)
+	assert.True(t, page.Versions[0].IsLatest)
+	oldest := page.Versions[1].VersionID
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/download?bucket=my-bucket&key=doc.pdf&version_id="+oldest, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "%PDF-1.4 v1", rec.Body.String())
+	assert.Equal(t, oldest, rec.Header().Get("X-Version-Id"))
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/presign?bucket=my-bucket&key=doc.pdf&version_id="+oldest, nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var presigned struct {
+		URL string `json:"presigned_url"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &presigned))
+	rec = doRequest(r, http.MethodGet, strings.TrimPrefix(presigned.URL, "http://localhost:8080"), nil, "")
+	assert.Equal(t, "%PDF-1.4 v1", rec.Body.String())
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/files/restore",
+		bytes.NewBufferString(fmt.Sprintf(`{"bucket":"my-bucket","key":"doc.pdf","version_id":%q}`, oldest)), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/download?bucket=my-bucket&key=doc.pdf", nil, "")
+	assert.Equal(t, "%PDF-1.4 v1", rec.Body.String())
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/delete?bucket=my-bucket&key=doc.pdf&version_id="+oldest, nil, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/delete?bucket=my-bucket&key=doc.pdf&version_id="+oldest, nil, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(r, http.MethodGet, "/api/v1/files/versions?bucket=my-bucket", nil, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Len(t, page.Versions, 2)
+}
+
+func TestHandler_CopyAndMove(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+	require.NoError(t, repo.CreateBucket(ctx, "archive"))
+
+	_, err := repo.Upload(ctx, "my-bucket", &File{
+		Name:     "report.pdf",
+		Content:  readSeekCloser{strings.NewReader("%PDF-1.4")},
+		Metadata: map[string]string{"author": "jane"},
+		Tags:     map[string]string{"team": "ops"},
+	})
+	require.NoError(t, err)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/files/copy",
+		bytes.NewBufferString(`{"source_bucket":"my-bucket","source_key":"report.pdf","destination_key":"copies/report.pdf"}`), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	info, err := repo.Stat(ctx, "my-bucket", "copies/report.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"author": "jane"}, info.Metadata)
+	assert.Equal(t, map[string]string{"team": "ops"}, info.Tags)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/files/move",
+		bytes.NewBufferString(`{"source_bucket":"my-bucket","source_key":"report.pdf","destination_bucket":"archive","tags":{"state":"archived"}}`), "application/json")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"bucket":"archive"`)
+
+	info, err = repo.Stat(ctx, "archive", "report.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"author": "jane"}, info.Metadata)
+	assert.Equal(t, map[string]string{"state": "archived"}, info.Tags)
+
+	_, err = repo.Stat(ctx, "my-bucket", "report.pdf")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/files/copy",
+		bytes.NewBufferString(`{"source_bucket":"archive","source_key":"report.pdf"}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/files/move",
+		bytes.NewBufferString(`{"source_bucket":"my-bucket","source_key":"missing.pdf","destination_bucket":"archive"}`), "application/json")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_DeleteBatch(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.pdf", "b.pdf", "logs/1.pdf", "logs/2.pdf", "keep.pdf"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: name, Content: readSeekCloser{strings.NewReader("%PDF-1.4")}})
+		require.NoError(t, err)
+	}
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/delete-batch",
+		bytes.NewBufferString(`{"bucket":"my-bucket","keys":["a.pdf","b.pdf","a.pdf",""],"prefix":"logs/"}`), "application/json")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var report BatchDeleteReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 4, report.Deleted)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Results, 3, "keys deleted through the prefix are only counted")
+
+	page, err := repo.List(ctx, "my-bucket", ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 1)
+	assert.Equal(t, "keep.pdf", page.Files[0].Key)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/delete-batch", bytes.NewBufferString(`{"bucket":"my-bucket"}`), "application/json")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(r, http.MethodPost, "/api/v1/delete-batch", bytes.NewBufferString(`{"bucket":"no-such-bucket","keys":["a"]}`), "application/json")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_EmptyVersionedBucket(t *testing.T) {
+	r, repo := newTestRouter(t)
+	ctx := context.Background()
+	require.NoError(t, repo.SetBucketVersioning(ctx, "my-bucket", true))
+
+	for _, content := range []string{"%PDF-1.4 v1", "%PDF-1.4 v2"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: "doc.pdf", Content: readSeekCloser{strings.NewReader(content)}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Delete(ctx, "my-bucket", "doc.pdf"))
+	_, err := repo.CreateMultipartUpload(ctx, "my-bucket", "big.pdf", "application/pdf", nil)
+	require.NoError(t, err)
+
+	rec := doRequest(r, http.MethodDelete, "/api/v1/buckets/empty?bucket=my-bucket", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var report EmptyBucketReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, EmptyBucketReport{Bucket: "my-bucket", ObjectsDeleted: 3, UploadsAborted: 1}, report)
+	assert.NoError(t, repo.DeleteBucket(ctx, "my-bucket"))
+
+	rec = doRequest(r, http.MethodDelete, "/api/v1/buckets/empty?bucket=no-such-bucket", nil, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
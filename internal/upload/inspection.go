@@ -0,0 +1,126 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+)
+
+// objectBlockSize is the range fetched per request when inspecting a stored
+// object, so archive and PDF checks do not issue one request per small read.
+const objectBlockSize = 1 << 20
+
+// WithInspectors replaces the default inspector chain run on every upload
+// after its type passed the upload policy.
+func WithInspectors(chain inspect.Chain) ServiceOption {
+	return func(s *uploadService) {
+		s.inspectors = chain
+	}
+}
+
+// inspectContent detects the real type of in, checks it against policy and
+// runs the inspector chain. in may come back with sanitized content.
+func (s *uploadService) inspectContent(ctx context.Context, in *inspect.Input, policy uploadPolicy, declaredType string) error {
+	detected, err := inspect.Detect(in)
+	if err != nil {
+		return fmt.Errorf("failed to read file header: %w", err)
+	}
+	in.Type = detected
+
+	if err := policy.checkContent(detected, in.Name, declaredType); err != nil {
+		return err
+	}
+	if err := s.inspectors.Inspect(ctx, in); err != nil {
+		slog.Warn("content inspection failed", "error", err, "type", detected, "filename", in.Name)
+		return err
+	}
+	if in.Modified() {
+		slog.Info("content sanitized", "type", detected, "filename", in.Name, "size", in.Size)
+	}
+	return nil
+}
+
+// sanitizedContent serves the cleaned copy of an upload and closes the
+// original with it.
+type sanitizedContent struct {
+	*io.SectionReader
+	original io.Closer
+}
+
+func (c sanitizedContent) Close() error {
+	if c.original == nil {
+		return nil
+	}
+	return c.original.Close()
+}
+
+// seekerReaderAt gives random access to upload content that only implements
+// io.ReadSeeker. Reads move the offset; callers rewind when done.
+type seekerReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *seekerReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r.rs, p)
+}
+
+// objectReaderAt reads a stored object through ranged downloads, keeping the
+// last block fetched.
+type objectReaderAt struct {
+	ctx    context.Context
+	repo   Repository
+	bucket string
+	key    string
+	size   int64
+
+	mu     sync.Mutex
+	offset int64
+	block  []byte
+}
+
+func (r *objectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+		if pos < r.offset || pos >= r.offset+int64(len(r.block)) {
+			if err := r.fetch(pos - pos%objectBlockSize); err != nil {
+				return n, err
+			}
+		}
+		n += copy(p[n:], r.block[pos-r.offset:])
+	}
+	return n, nil
+}
+
+func (r *objectReaderAt) fetch(start int64) error {
+	end := min(start+objectBlockSize, r.size) - 1
+	obj, err := r.repo.Download(r.ctx, r.bucket, r.key, DownloadOptions{Range: fmt.Sprintf("bytes=%d-%d", start, end)})
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	block := make([]byte, end-start+1)
+	if _, err := io.ReadFull(obj.Body, block); err != nil {
+		return err
+	}
+	r.offset, r.block = start, block
+	return nil
+}
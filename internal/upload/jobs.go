@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/JoaoOliveira889/s3-api/internal/jobs"
+)
+
+const (
+	JobEmptyBucket = "empty_bucket"
+	JobCopyFiles   = "copy_files"
+	JobDeleteFiles = "delete_files"
+	JobBucketStats = "bucket_stats"
+	JobReindex     = "search_reindex"
+)
+
+type bucketJobParams struct {
+	Bucket string `json:"bucket"`
+}
+
+type statsJobParams struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+}
+
+type copyJobParams struct {
+	SourceBucket      string `json:"source_bucket"`
+	DestinationBucket string `json:"destination_bucket"`
+	Prefix            string `json:"prefix"`
+}
+
+type deleteJobParams struct {
+	Bucket string   `json:"bucket"`
+	Keys   []string `json:"keys"`
+	Prefix string   `json:"prefix"`
+}
+
+// RegisterJobs makes the bucket-wide operations available as background jobs
+// so they are not bound by the request timeout.
+func RegisterJobs(m *jobs.Manager, s Service) {
+	m.Register(JobEmptyBucket, jobRunner[bucketJobParams]{
+		validate: requireBucket,
+		run: func(ctx context.Context, p bucketJobParams, progress jobs.ProgressFunc) (any, error) {
+			return s.EmptyBucket(ctx, p.Bucket, func(r EmptyBucketReport) { progress(r) })
+		},
+	})
+
+	m.Register(JobCopyFiles, jobRunner[copyJobParams]{
+		validate: func(p copyJobParams) error {
+			if p.SourceBucket == "" || p.DestinationBucket == "" {
+				return errors.New("source_bucket and destination_bucket are required")
+			}
+			if p.SourceBucket == p.DestinationBucket {
+				return ErrSameBucket
+			}
+			return nil
+		},
+		run: func(ctx context.Context, p copyJobParams, progress jobs.ProgressFunc) (any, error) {
+			req := BulkCopyRequest{SourceBucket: p.SourceBucket, DestinationBucket: p.DestinationBucket, Prefix: p.Prefix}
+			return s.CopyFiles(ctx, req, func(bp BatchProgress) { progress(bp) })
+		},
+	})
+
+	m.Register(JobDeleteFiles, jobRunner[deleteJobParams]{
+		validate: func(p deleteJobParams) error {
+			if p.Bucket == "" {
+				return ErrBucketNameRequired
+			}
+			if len(p.Keys) == 0 && p.Prefix == "" {
+				return errors.New("keys or prefix is required")
+			}
+			return nil
+		},
+		run: func(ctx context.Context, p deleteJobParams, progress jobs.ProgressFunc) (any, error) {
+			req := BatchDeleteRequest{Keys: p.Keys, Prefix: p.Prefix}
+			return s.DeleteFiles(ctx, p.Bucket, req, func(bp BatchProgress) { progress(bp) })
+		},
+	})
+
+	m.Register(JobBucketStats, jobRunner[statsJobParams]{
+		validate: func(p statsJobParams) error {
+			if p.Bucket == "" {
+				return ErrBucketNameRequired
+			}
+			return nil
+		},
+		run: func(ctx context.Context, p statsJobParams, progress jobs.ProgressFunc) (any, error) {
+			return s.GetBucketStats(ctx, p.Bucket, StatsOptions{Prefix: p.Prefix, Refresh: true})
+		},
+	})
+
+	m.Register(JobReindex, jobRunner[bucketJobParams]{
+		validate: func(bucketJobParams) error { return nil },
+		run: func(ctx context.Context, p bucketJobParams, progress jobs.ProgressFunc) (any, error) {
+			return s.Reindex(ctx, p.Bucket, func(bp BatchProgress) { progress(bp) })
+		},
+	})
+}
+
+// jobRunner adapts a typed job function to jobs.Runner, decoding and
+// validating the raw params before every run.
+type jobRunner[P any] struct {
+	validate func(P) error
+	run      func(ctx context.Context, params P, progress jobs.ProgressFunc) (any, error)
+}
+
+func (r jobRunner[P]) Validate(raw json.RawMessage) error {
+	_, err := r.decode(raw)
+	return err
+}
+
+func (r jobRunner[P]) Run(ctx context.Context, raw json.RawMessage, progress jobs.ProgressFunc) (any, error) {
+	params, err := r.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	return r.run(ctx, params, progress)
+}
+
+func (r jobRunner[P]) decode(raw json.RawMessage) (P, error) {
+	var params P
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return params, fmt.Errorf("%w: %v", jobs.ErrInvalidParams, err)
+	}
+	if err := r.validate(params); err != nil {
+		return params, fmt.Errorf("%w: %v", jobs.ErrInvalidParams, err)
+	}
+	return params, nil
+}
+
+func requireBucket(p bucketJobParams) error {
+	if p.Bucket == "" {
+		return ErrBucketNameRequired
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoaoOliveira889/s3-api/internal/jobs"
+	"github.com/JoaoOliveira889/s3-api/internal/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs_CopyFilesThenEmptyBucket(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(ctx, "my-bucket"))
+	require.NoError(t, repo.CreateBucket(ctx, "backup-bucket"))
+
+	for _, name := range []string{"docs/a.pdf", "docs/b.pdf", "other.pdf"} {
+		_, err := repo.Upload(ctx, "my-bucket", &File{Name: name, Content: readSeekCloser{strings.NewReader("%PDF-1.4")}})
+		require.NoError(t, err)
+	}
+
+	index := search.NewIndex()
+	m := jobs.NewManager(jobs.NewMemoryStore())
+	RegisterJobs(m, NewService(repo, WithSearchIndex(index)))
+
+	run := func(jobType, params string) *jobs.Job {
+		t.Helper()
+		job, err := m.Submit(ctx, jobType, json.RawMessage(params))
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			job, err = m.Get(ctx, job.ID)
+			require.NoError(t, err)
+			return job.Finished()
+		}, 2*time.Second, 5*time.Millisecond)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		return job
+	}
+
+	job := run(JobCopyFiles, `{"source_bucket":"my-bucket","destination_bucket":"backup-bucket","prefix":"docs/"}`)
+	var copied BulkCopyReport
+	require.NoError(t, json.Unmarshal(job.Result, &copied))
+	assert.Equal(t, 2, copied.Copied)
+	assert.JSONEq(t, `{"total":2,"processed":2,"failed":0}`, string(job.Progress))
+
+	job = run(JobEmptyBucket, `{"bucket":"my-bucket"}`)
+	var emptied EmptyBucketReport
+	require.NoError(t, json.Unmarshal(job.Result, &emptied))
+	assert.Equal(t, 3, emptied.ObjectsDeleted)
+
+	job = run(JobBucketStats, `{"bucket":"backup-bucket"}`)
+	var stats BucketStats
+	require.NoError(t, json.Unmarshal(job.Result, &stats))
+	assert.Equal(t, 2, stats.TotalFiles)
+
+	job = run(JobReindex, `{}`)
+	var reindexed ReindexReport
+	require.NoError(t, json.Unmarshal(job.Result, &reindexed))
+	assert.Equal(t, 2, reindexed.Indexed)
+	assert.Equal(t, 2, index.Search(search.Query{Text: "docs", Bucket: "backup-bucket"}).Total)
+
+	_, err := m.Submit(ctx, JobDeleteFiles, json.RawMessage(`{"bucket":"my-bucket"}`))
+	assert.ErrorIs(t, err, jobs.ErrInvalidParams)
+
+	_, err = m.Submit(ctx, JobCopyFiles, json.RawMessage(`{"source_bucket":"my-bucket","destination_bucket":"my-bucket"}`))
+	assert.ErrorIs(t, err, jobs.ErrInvalidParams)
+	assert.ErrorContains(t, err, ErrSameBucket.Error())
+}
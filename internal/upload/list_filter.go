@@ -0,0 +1,244 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	filterScanPageSize = 1000
+	filterMaxScanPages = 50
+	maxFilterPattern   = 256
+)
+
+// fileFilter holds the server-side filters of a ListQuery. Folders returned by
+// a delimited listing are never filtered.
+type fileFilter struct {
+	extension      string
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+	storageClass   string
+	glob           string
+	pattern        *regexp.Regexp
+	tags           map[string]string
+}
+
+func newFileFilter(q ListQuery) (*fileFilter, error) {
+	f := &fileFilter{
+		extension:      strings.ToLower(q.Extension),
+		minSize:        q.MinSize,
+		maxSize:        q.MaxSize,
+		modifiedAfter:  q.ModifiedAfter,
+		modifiedBefore: q.ModifiedBefore,
+		storageClass:   q.StorageClass,
+		glob:           q.Glob,
+		tags:           q.Tags,
+	}
+	if f.extension != "" && !strings.HasPrefix(f.extension, ".") {
+		f.extension = "." + f.extension
+	}
+
+	if f.minSize < 0 || f.maxSize < 0 || (f.maxSize > 0 && f.minSize > f.maxSize) {
+		return nil, fmt.Errorf("%w: invalid size range", ErrInvalidFilter)
+	}
+	if !f.modifiedAfter.IsZero() && !f.modifiedBefore.IsZero() && !f.modifiedAfter.Before(f.modifiedBefore) {
+		return nil, fmt.Errorf("%w: modified_after must be before modified_before", ErrInvalidFilter)
+	}
+	if len(q.Glob) > maxFilterPattern || len(q.Pattern) > maxFilterPattern {
+		return nil, fmt.Errorf("%w: patterns must be at most %d bytes", ErrInvalidFilter, maxFilterPattern)
+	}
+	if _, err := path.Match(f.glob, ""); err != nil {
+		return nil, fmt.Errorf("%w: invalid glob: %v", ErrInvalidFilter, err)
+	}
+	if q.Pattern != "" {
+		re, err := regexp.Compile(q.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid pattern: %v", ErrInvalidFilter, err)
+		}
+		f.pattern = re
+	}
+	return f, nil
+}
+
+func (f *fileFilter) active() bool {
+	return f.extension != "" || f.minSize > 0 || f.maxSize > 0 ||
+		!f.modifiedAfter.IsZero() || !f.modifiedBefore.IsZero() ||
+		f.storageClass != "" || f.glob != "" || f.pattern != nil || len(f.tags) > 0
+}
+
+func (f *fileFilter) match(file FileSummary) bool {
+	switch {
+	case f.extension != "" && strings.ToLower(file.Extension) != f.extension:
+		return false
+	case file.Size < f.minSize:
+		return false
+	case f.maxSize > 0 && file.Size > f.maxSize:
+		return false
+	case !f.modifiedAfter.IsZero() && file.LastModified.Before(f.modifiedAfter):
+		return false
+	case !f.modifiedBefore.IsZero() && !file.LastModified.Before(f.modifiedBefore):
+		return false
+	case f.storageClass != "" && !strings.EqualFold(file.StorageClass, f.storageClass):
+		return false
+	case f.pattern != nil && !f.pattern.MatchString(file.Key):
+		return false
+	case len(f.tags) > 0 && !hasTags(file.Tags, f.tags):
+		return false
+	}
+	if f.glob != "" {
+		if ok, _ := path.Match(f.glob, file.Key); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies the filters and listing scope a cursor was issued
+// for, so a cursor cannot be replayed against a different query.
+func (f *fileFilter) fingerprint(q ListQuery) string {
+	pattern := ""
+	if f.pattern != nil {
+		pattern = f.pattern.String()
+	}
+	tags, _ := json.Marshal(f.tags)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%d\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s",
+		q.Prefix, q.Delimiter, f.extension, f.minSize, f.maxSize,
+		f.modifiedAfter.Format(time.RFC3339Nano), f.modifiedBefore.Format(time.RFC3339Nano),
+		f.storageClass, f.glob, pattern, tags)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// filterCursor resumes a filtered listing in the middle of a storage page:
+// Token and Size fetch the page again and Skip entries of it were already
+// consumed.
+type filterCursor struct {
+	Token  string `json:"t,omitempty"`
+	Size   int32  `json:"n,omitempty"`
+	Skip   int    `json:"s,omitempty"`
+	Filter string `json:"f"`
+}
+
+func (c filterCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFilterCursor(token, fingerprint string) (filterCursor, error) {
+	var c filterCursor
+	if token == "" {
+		return filterCursor{Filter: fingerprint}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.Skip < 0 || c.Size < 0 || c.Size > filterScanPageSize {
+		return c, fmt.Errorf("%w: invalid continuation token", ErrInvalidFilter)
+	}
+	if c.Filter != fingerprint {
+		return c, fmt.Errorf("%w: continuation token was issued for different filters", ErrInvalidFilter)
+	}
+	return c, nil
+}
+
+type listEntry struct {
+	file   *FileSummary
+	folder *Folder
+}
+
+// mergeEntries interleaves files and folders in key order, the order in which
+// the storage listing returned them.
+func mergeEntries(page *PaginatedFiles) []listEntry {
+	entries := make([]listEntry, 0, len(page.Files)+len(page.Folders))
+	i, j := 0, 0
+	for i < len(page.Files) || j < len(page.Folders) {
+		if j == len(page.Folders) || (i < len(page.Files) && page.Files[i].Key < page.Folders[j].Prefix) {
+			entries = append(entries, listEntry{file: &page.Files[i]})
+			i++
+		} else {
+			entries = append(entries, listEntry{folder: &page.Folders[j]})
+			j++
+		}
+	}
+	return entries
+}
+
+// listFiltered keeps fetching storage pages until limit entries match or the
+// listing ends, so filtering never produces empty pages with a next token.
+// At most filterMaxScanPages pages are read per call to bound request time.
+func (s *uploadService) listFiltered(ctx context.Context, bucket string, q ListQuery, filter *fileFilter, limit int) (*PaginatedFiles, error) {
+	fingerprint := filter.fingerprint(q)
+	cursor, err := decodeFilterCursor(q.Token, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Describing objects costs a request each, so pages that need metadata are
+	// only as large as the requested limit.
+	metadata := q.IncludeMetadata || len(q.Tags) > 0
+	size := int32(filterScanPageSize)
+	if metadata {
+		size = int32(min(limit, filterScanPageSize))
+	}
+	if cursor.Size > 0 {
+		size = cursor.Size
+	}
+
+	res := &PaginatedFiles{}
+	count := 0
+	for scanned := 0; ; scanned++ {
+		if scanned == filterMaxScanPages {
+			res.NextToken = cursor.encode()
+			return res, nil
+		}
+
+		page, err := s.repo.List(ctx, bucket, ListOptions{
+			Prefix:          q.Prefix,
+			Delimiter:       q.Delimiter,
+			Token:           cursor.Token,
+			Limit:           size,
+			IncludeMetadata: metadata,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		entries := mergeEntries(page)
+		for i := min(cursor.Skip, len(entries)); i < len(entries); i++ {
+			e := entries[i]
+			switch {
+			case e.folder != nil:
+				res.Folders = append(res.Folders, *e.folder)
+			case filter.match(*e.file):
+				res.Files = append(res.Files, *e.file)
+			default:
+				continue
+			}
+
+			if count++; count == limit {
+				switch {
+				case i+1 < len(entries):
+					res.NextToken = filterCursor{Token: cursor.Token, Size: size, Skip: i + 1, Filter: fingerprint}.encode()
+				case page.NextToken != "":
+					res.NextToken = filterCursor{Token: page.NextToken, Filter: fingerprint}.encode()
+				}
+				return res, nil
+			}
+		}
+
+		if page.NextToken == "" {
+			return res, nil
+		}
+		cursor = filterCursor{Token: page.NextToken, Filter: fingerprint}
+	}
+}
@@ -0,0 +1,607 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxLocalPartSize  = 5 * 1024 * 1024 * 1024
+	localStorageClass = "STANDARD"
+	nullVersionID     = "null"
+)
+
+const (
+	responseContentDisposition = "response-content-disposition"
+	responseContentType        = "response-content-type"
+)
+
+var errBodyTooSmall = errors.New("body is smaller than the allowed minimum")
+
+type localObject struct {
+	content     io.ReadSeeker
+	contentType string
+	etag        string
+	versionID   string
+	metadata    map[string]string
+	tags        map[string]string
+	modTime     time.Time
+}
+
+type localBackend interface {
+	openObject(bucket, key, versionID string) (*localObject, func(), error)
+	putObject(ctx context.Context, bucket, key, contentType string, body io.Reader) (string, error)
+	writePart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.Reader) (string, error)
+}
+
+func serveLocal(w http.ResponseWriter, req *http.Request, signer urlSigner, backend localBackend) {
+	bucket, key, ok := splitObjectPath(req.URL.Path)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		serveLocalObject(w, req, signer, backend, bucket, key)
+	case http.MethodPut:
+		if req.URL.Query().Has("uploadId") {
+			serveLocalPart(w, req, signer, backend, bucket, key)
+			return
+		}
+		serveLocalPut(w, req, signer, backend, bucket, key)
+	case http.MethodPost:
+		serveLocalPost(w, req, signer, backend, bucket, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func serveLocalObject(w http.ResponseWriter, req *http.Request, signer urlSigner, backend localBackend, bucket, key string) {
+	if err := signer.verify(http.MethodGet, bucket, key, req.URL.Query(), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	obj, release, err := backend.openObject(bucket, key, req.URL.Query().Get("versionId"))
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	defer release()
+
+	q := req.URL.Query()
+	w.Header().Set("Content-Type", firstNonEmpty(q.Get(responseContentType), obj.contentType))
+	if disposition := q.Get(responseContentDisposition); disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	if obj.etag != "" {
+		w.Header().Set("ETag", `"`+obj.etag+`"`)
+	}
+
+	http.ServeContent(w, req, path.Base(key), obj.modTime, obj.content)
+}
+
+func serveLocalPart(w http.ResponseWriter, req *http.Request, signer urlSigner, backend localBackend, bucket, key string) {
+	q := req.URL.Query()
+	if err := signer.verify(http.MethodPut, bucket, key, q, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	partNumber, err := strconv.ParseInt(q.Get("partNumber"), 10, 32)
+	if err != nil || !validPartNumber(int32(partNumber)) {
+		http.Error(w, ErrInvalidPartNumber.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := http.MaxBytesReader(w, req.Body, maxLocalPartSize)
+	etag, err := backend.writePart(req.Context(), bucket, key, q.Get("uploadId"), int32(partNumber), body)
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "failed to store part", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func serveLocalPut(w http.ResponseWriter, req *http.Request, signer urlSigner, backend localBackend, bucket, key string) {
+	q := req.URL.Query()
+	if err := signer.verify(http.MethodPut, bucket, key, q, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	contentType := q.Get("contentType")
+	if contentType != "" && req.Header.Get("Content-Type") != contentType {
+		http.Error(w, "Content-Type does not match the signed value", http.StatusForbidden)
+		return
+	}
+
+	if length := q.Get("contentLength"); length == "" || length != strconv.FormatInt(req.ContentLength, 10) {
+		http.Error(w, "Content-Length does not match the signed value", http.StatusForbidden)
+		return
+	}
+
+	maxSize, _ := strconv.ParseInt(q.Get("maxSize"), 10, 64)
+	etag, err := backend.putObject(req.Context(), bucket, key, contentType, newSizeRangeReader(req.Body, 1, maxSize))
+	if err != nil {
+		writeLocalPutError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func serveLocalPost(w http.ResponseWriter, req *http.Request, signer urlSigner, backend localBackend, bucket, key string) {
+	q := req.URL.Query()
+	if err := signer.verify(http.MethodPost, bucket, key, q, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		http.Error(w, "multipart form body is required", http.StatusBadRequest)
+		return
+	}
+
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "file field is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "invalid multipart form body", http.StatusBadRequest)
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, _ := io.ReadAll(io.LimitReader(part, 8*1024))
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		if k, ok := fields["key"]; ok && k != key {
+			http.Error(w, "key does not match the policy", http.StatusForbidden)
+			return
+		}
+		contentType := q.Get("contentType")
+		if fields["Content-Type"] != contentType {
+			http.Error(w, "Content-Type does not match the policy", http.StatusForbidden)
+			return
+		}
+
+		maxSize, _ := strconv.ParseInt(q.Get("maxSize"), 10, 64)
+		if _, err := backend.putObject(req.Context(), bucket, key, contentType, newSizeRangeReader(part, 1, maxSize)); err != nil {
+			writeLocalPutError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+}
+
+func writeLocalPutError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrFileTooLarge), errors.Is(err, errBodyTooSmall):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrBucketNotFound), errors.Is(err, ErrInvalidObjectKey):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "failed to store object", http.StatusInternalServerError)
+	}
+}
+
+func localObjectInfo(obj *localObject, key string) (ObjectInfo, error) {
+	size, err := obj.content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to open object: %w", err)
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         size,
+		ContentType:  obj.contentType,
+		ETag:         obj.etag,
+		VersionID:    obj.versionID,
+		StorageClass: localStorageClass,
+		Metadata:     obj.metadata,
+		Tags:         obj.tags,
+		LastModified: obj.modTime,
+	}, nil
+}
+
+func statLocalObject(obj *localObject, release func(), key string) (*ObjectInfo, error) {
+	defer release()
+
+	info, err := localObjectInfo(obj, key)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func openLocalDownload(obj *localObject, release func(), key string, opts DownloadOptions) (*Object, error) {
+	info, err := localObjectInfo(obj, key)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	size := info.Size
+
+	if notModified(info, opts) {
+		release()
+		return nil, &DownloadConditionError{Info: info, err: ErrNotModified}
+	}
+
+	start, length, ranged, err := parseByteRange(opts.Range, size)
+	if err != nil {
+		release()
+		return nil, &DownloadConditionError{Info: info, err: err}
+	}
+	if _, err := obj.content.Seek(start, io.SeekStart); err != nil {
+		release()
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+
+	res := &Object{
+		Body:          releaseReader{Reader: io.LimitReader(obj.content, length), release: release},
+		Info:          info,
+		ContentLength: length,
+	}
+	if ranged {
+		res.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size)
+	}
+	return res, nil
+}
+
+func notModified(info ObjectInfo, opts DownloadOptions) bool {
+	if opts.IfNoneMatch != "" {
+		for _, tag := range strings.Split(opts.IfNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.Trim(strings.TrimPrefix(tag, "W/"), `"`) == info.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	if !opts.IfModifiedSince.IsZero() {
+		return !info.LastModified.Truncate(time.Second).After(opts.IfModifiedSince)
+	}
+	return false
+}
+
+func parseByteRange(header string, size int64) (start, length int64, ranged bool, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size, false, nil
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, size, false, nil
+	}
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, size, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, ErrInvalidRange
+		}
+		n = min(n, size)
+		return size - n, n, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, size, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, size, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, false, ErrInvalidRange
+	}
+	return start, end - start + 1, true, nil
+}
+
+type releaseReader struct {
+	io.Reader
+	release func()
+}
+
+func (r releaseReader) Close() error {
+	r.release()
+	return nil
+}
+
+func presignLocalDownload(signer urlSigner, bucket, key string, opts PresignOptions) string {
+	params := url.Values{}
+	if opts.VersionID != "" {
+		params.Set("versionId", opts.VersionID)
+	}
+	if opts.ContentDisposition != "" {
+		params.Set(responseContentDisposition, opts.ContentDisposition)
+	}
+	if opts.ContentType != "" {
+		params.Set(responseContentType, opts.ContentType)
+	}
+	return signer.sign(http.MethodGet, bucket, key, time.Now().Add(opts.Expiration), params)
+}
+
+func presignLocalUpload(signer urlSigner, bucket, key string, opts UploadPresignOptions) (*PresignedUpload, error) {
+	if !validLocalKey(key) {
+		return nil, ErrInvalidObjectKey
+	}
+
+	expires := time.Now().Add(opts.Expiration)
+	params := url.Values{}
+	params.Set("contentType", opts.ContentType)
+	params.Set("maxSize", strconv.FormatInt(opts.MaxSize, 10))
+
+	res := &PresignedUpload{
+		Method:    opts.Method,
+		Bucket:    bucket,
+		Key:       key,
+		ExpiresAt: expires.UTC(),
+	}
+
+	switch opts.Method {
+	case http.MethodPut:
+		if opts.ContentLength <= 0 {
+			return nil, ErrUploadSizeRequired
+		}
+		params.Set("contentLength", strconv.FormatInt(opts.ContentLength, 10))
+		res.URL = signer.sign(http.MethodPut, bucket, key, expires, params)
+		res.Headers = map[string]string{"Content-Type": opts.ContentType}
+	case http.MethodPost:
+		res.URL = signer.sign(http.MethodPost, bucket, key, expires, params)
+		res.Fields = map[string]string{"key": key, "Content-Type": opts.ContentType}
+	default:
+		return nil, ErrInvalidPresignMethod
+	}
+
+	return res, nil
+}
+
+type sizeRangeReader struct {
+	r        io.Reader
+	read     int64
+	min, max int64
+}
+
+func newSizeRangeReader(r io.Reader, min, max int64) io.Reader {
+	return &sizeRangeReader{r: r, min: min, max: max}
+}
+
+func (s *sizeRangeReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.read += int64(n)
+
+	if s.max > 0 && s.read > s.max {
+		return n, ErrFileTooLarge
+	}
+	if err == io.EOF && s.read < s.min {
+		return n, errBodyTooSmall
+	}
+	return n, err
+}
+
+func validPartNumber(n int32) bool {
+	return n >= 1 && n <= maxUploadParts
+}
+
+func checkCompletedParts(requested []UploadedPart, stored map[int32]string) error {
+	if len(requested) == 0 {
+		return fmt.Errorf("%w: at least one part is required", ErrInvalidPart)
+	}
+
+	prev := int32(0)
+	for _, p := range requested {
+		if p.PartNumber <= prev {
+			return fmt.Errorf("%w: parts must be listed in ascending order", ErrInvalidPart)
+		}
+		prev = p.PartNumber
+
+		etag, ok := stored[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != etag {
+			return fmt.Errorf("%w: part %d", ErrInvalidPart, p.PartNumber)
+		}
+	}
+	return nil
+}
+
+func multipartETag(etags []string) string {
+	hash := md5.New()
+	for _, etag := range etags {
+		raw, _ := hex.DecodeString(etag)
+		hash.Write(raw)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(hash.Sum(nil)), len(etags))
+}
+
+// deleteEach removes keys one at a time for backends without a native batch
+// delete, reporting the outcome of every key.
+func deleteEach(ctx context.Context, repo Repository, bucket string, keys []string) ([]DeleteResult, error) {
+	results := make([]DeleteResult, 0, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		err := repo.Delete(ctx, bucket, key)
+		if errors.Is(err, ErrBucketNotFound) {
+			return nil, err
+		}
+
+		res := DeleteResult{Key: key, Deleted: err == nil}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func newVersionID(status string) string {
+	if status != VersioningEnabled {
+		return nullVersionID
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+func paginateVersions(versions []ObjectVersion, token string, limit int32) (*PaginatedVersions, error) {
+	marker, err := decodeListToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if !a.LastModified.Equal(b.LastModified) {
+			return a.LastModified.After(b.LastModified)
+		}
+		return a.VersionID > b.VersionID
+	})
+	for i := range versions {
+		versions[i].IsLatest = i == 0 || versions[i-1].Key != versions[i].Key
+	}
+
+	if marker != "" {
+		key, versionID, _ := strings.Cut(marker, "\x00")
+		start := sort.Search(len(versions), func(i int) bool { return versions[i].Key > key })
+		for i, v := range versions {
+			if v.Key == key && v.VersionID == versionID {
+				start = i + 1
+				break
+			}
+		}
+		versions = versions[start:]
+	}
+
+	next := ""
+	if limit > 0 && len(versions) > int(limit) {
+		versions = versions[:limit]
+		last := versions[len(versions)-1]
+		next = encodeListToken(last.Key + "\x00" + last.VersionID)
+	}
+
+	return &PaginatedVersions{Versions: versions, NextToken: next}, nil
+}
+
+// groupListing pages through sorted keys (already filtered by opts.Prefix) the
+// way ListObjectsV2 does. With a delimiter, keys that share a prefix up to the
+// next delimiter collapse into a single folder, and folders and files count
+// together against the limit.
+func groupListing(keys []string, opts ListOptions) (files []string, folders []Folder, next string, err error) {
+	startAfter, err := decodeListToken(opts.Token)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	start := sort.Search(len(keys), func(i int) bool { return keys[i] > startAfter })
+	count, last := 0, ""
+	for _, key := range keys[start:] {
+		if opts.Delimiter != "" && strings.HasSuffix(startAfter, opts.Delimiter) && strings.HasPrefix(key, startAfter) {
+			continue
+		}
+
+		entry, isFolder := key, false
+		if opts.Delimiter != "" {
+			rest := strings.TrimPrefix(key, opts.Prefix)
+			if i := strings.Index(rest, opts.Delimiter); i >= 0 {
+				entry, isFolder = opts.Prefix+rest[:i+len(opts.Delimiter)], true
+			}
+		}
+		if isFolder && entry == last {
+			continue
+		}
+
+		if opts.Limit > 0 && count == int(opts.Limit) {
+			return files, folders, encodeListToken(last), nil
+		}
+		if isFolder {
+			folders = append(folders, newFolder(entry, opts))
+		} else {
+			files = append(files, key)
+		}
+		count++
+		last = entry
+	}
+	return files, folders, "", nil
+}
+
+func newFolder(commonPrefix string, opts ListOptions) Folder {
+	name := strings.TrimSuffix(strings.TrimPrefix(commonPrefix, opts.Prefix), opts.Delimiter)
+	return Folder{Name: name, Prefix: commonPrefix}
+}
+
+func encodeListToken(lastKey string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+}
+
+func decodeListToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid continuation token")
+	}
+	return string(raw), nil
+}
+
+func validLocalBucket(bucket string) bool {
+	return bucket != "" && !strings.HasPrefix(bucket, ".") && !strings.ContainsAny(bucket, `/\`)
+}
+
+func validLocalKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,746 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryObject struct {
+	data         []byte
+	contentType  string
+	etag         string
+	versionID    string
+	deleteMarker bool
+	metadata     map[string]string
+	tags         map[string]string
+	modTime      time.Time
+}
+
+type memoryBucket struct {
+	created    time.Time
+	versioning string
+	objects    map[string]*memoryObject
+	history    map[string][]*memoryObject
+}
+
+type memoryUpload struct {
+	bucket      string
+	key         string
+	contentType string
+	metadata    map[string]string
+	parts       map[int32]*memoryObject
+}
+
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	buckets map[string]*memoryBucket
+	uploads map[string]*memoryUpload
+	signer  urlSigner
+}
+
+func NewMemoryRepository(baseURL string, secret []byte) *MemoryRepository {
+	return &MemoryRepository{
+		buckets: make(map[string]*memoryBucket),
+		uploads: make(map[string]*memoryUpload),
+		signer:  newURLSigner(baseURL, secret),
+	}
+}
+
+func (r *MemoryRepository) Upload(ctx context.Context, bucket string, file *File) (string, error) {
+	attrs := memoryObject{contentType: file.ContentType, metadata: file.Metadata, tags: file.Tags}
+	if _, err := r.writeObject(ctx, bucket, file.Name, file.Content, attrs); err != nil {
+		return "", err
+	}
+	return r.signer.downloadURL(bucket, file.Name), nil
+}
+
+func (r *MemoryRepository) GetPresignURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error) {
+	if !validLocalKey(key) {
+		return "", ErrInvalidObjectKey
+	}
+	return presignLocalDownload(r.signer, bucket, key, opts), nil
+}
+
+func (r *MemoryRepository) PresignUpload(ctx context.Context, bucket, key string, opts UploadPresignOptions) (*PresignedUpload, error) {
+	return presignLocalUpload(r.signer, bucket, key, opts)
+}
+
+func (r *MemoryRepository) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	obj, release, err := r.openObject(bucket, key, "")
+	if err != nil {
+		return nil, err
+	}
+	return statLocalObject(obj, release, key)
+}
+
+func (r *MemoryRepository) GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	obj, err := r.object(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return maps.Clone(obj.tags), nil
+}
+
+func (r *MemoryRepository) PutObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+	obj, ok := b.objects[key]
+	if !ok {
+		return ErrFileNotFound
+	}
+
+	updated := *obj
+	updated.tags = maps.Clone(tags)
+	b.objects[key] = &updated
+	return nil
+}
+
+func (r *MemoryRepository) DeleteObjectTags(ctx context.Context, bucket, key string) error {
+	return r.PutObjectTags(ctx, bucket, key, nil)
+}
+
+func (r *MemoryRepository) Download(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error) {
+	obj, release, err := r.openObject(bucket, key, opts.VersionID)
+	if err != nil {
+		return nil, err
+	}
+	return openLocalDownload(obj, release, key, opts)
+}
+
+func (r *MemoryRepository) List(ctx context.Context, bucket string, opts ListOptions) (*PaginatedFiles, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("failed to list objects: %w", ErrBucketNotFound)
+	}
+
+	keys, folders, next, err := groupListing(b.sortedKeys(opts.Prefix), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileSummary
+	for _, key := range keys {
+		obj := b.objects[key]
+		size := int64(len(obj.data))
+		summary := FileSummary{
+			Key:               key,
+			Size:              size,
+			HumanReadableSize: formatBytes(size),
+			StorageClass:      localStorageClass,
+			LastModified:      obj.modTime,
+			Extension:         strings.ToLower(path.Ext(key)),
+			URL:               r.signer.downloadURL(bucket, key),
+		}
+		if opts.IncludeMetadata {
+			summary.ContentType = obj.contentType
+			summary.Metadata = maps.Clone(obj.metadata)
+			summary.Tags = maps.Clone(obj.tags)
+		}
+		files = append(files, summary)
+	}
+
+	return &PaginatedFiles{Files: files, Folders: folders, NextToken: next}, nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, bucket string, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+
+	cur, ok := b.objects[key]
+	if !ok {
+		return nil
+	}
+	delete(b.objects, key)
+	if b.versioning != VersioningDisabled {
+		b.archive(key, cur)
+		b.archive(key, &memoryObject{versionID: newVersionID(b.versioning), deleteMarker: true, modTime: time.Now().UTC()})
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteMany(ctx context.Context, bucket string, keys []string) ([]DeleteResult, error) {
+	return deleteEach(ctx, r, bucket, keys)
+}
+
+func (r *MemoryRepository) Copy(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	if !validLocalKey(dstKey) {
+		return "", ErrInvalidObjectKey
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	src, ok := r.buckets[srcBucket]
+	if !ok {
+		return "", ErrBucketNotFound
+	}
+	dst, ok := r.buckets[dstBucket]
+	if !ok {
+		return "", ErrBucketNotFound
+	}
+	obj, ok := src.objects[srcKey]
+	if !ok {
+		return "", ErrFileNotFound
+	}
+
+	copied := &memoryObject{
+		data:        obj.data,
+		contentType: obj.contentType,
+		etag:        obj.etag,
+		metadata:    maps.Clone(obj.metadata),
+		tags:        maps.Clone(obj.tags),
+		modTime:     time.Now().UTC(),
+	}
+	if opts.Metadata != nil {
+		copied.metadata = maps.Clone(opts.Metadata)
+	}
+	if opts.Tags != nil {
+		copied.tags = maps.Clone(opts.Tags)
+	}
+	dst.put(dstKey, copied)
+
+	return r.signer.downloadURL(dstBucket, dstKey), nil
+}
+
+func (r *MemoryRepository) DeleteVersion(ctx context.Context, bucket, key, versionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+
+	if cur, ok := b.objects[key]; ok && cur.versionID == versionID {
+		delete(b.objects, key)
+		b.promote(key)
+		return nil
+	}
+
+	history := b.history[key]
+	i := slices.IndexFunc(history, func(v *memoryObject) bool { return v.versionID == versionID })
+	if i < 0 {
+		return ErrVersionNotFound
+	}
+	b.history[key] = slices.Delete(history, i, i+1)
+	b.promote(key)
+	return nil
+}
+
+func (r *MemoryRepository) ListVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("failed to list versions: %w", ErrBucketNotFound)
+	}
+
+	var versions []ObjectVersion
+	add := func(key string, obj *memoryObject) {
+		if strings.HasPrefix(key, opts.Prefix) {
+			versions = append(versions, ObjectVersion{
+				Key:          key,
+				VersionID:    obj.versionID,
+				DeleteMarker: obj.deleteMarker,
+				Size:         int64(len(obj.data)),
+				ETag:         obj.etag,
+				LastModified: obj.modTime,
+			})
+		}
+	}
+	for key, obj := range b.objects {
+		add(key, obj)
+	}
+	for key, history := range b.history {
+		for _, obj := range history {
+			add(key, obj)
+		}
+	}
+
+	return paginateVersions(versions, opts.Token, opts.Limit)
+}
+
+func (r *MemoryRepository) RestoreVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return "", ErrBucketNotFound
+	}
+
+	src, err := b.version(key, versionID)
+	if err != nil {
+		return "", err
+	}
+
+	restored := *src
+	restored.modTime = time.Now().UTC()
+	return b.put(key, &restored), nil
+}
+
+func (r *MemoryRepository) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return "", ErrBucketNotFound
+	}
+	return b.versioning, nil
+}
+
+func (r *MemoryRepository) SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+
+	b.versioning = VersioningSuspended
+	if enabled {
+		b.versioning = VersioningEnabled
+	}
+	return nil
+}
+
+func (r *MemoryRepository) CheckBucketExists(ctx context.Context, bucket string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.buckets[bucket]
+	return ok, nil
+}
+
+func (r *MemoryRepository) CreateBucket(ctx context.Context, bucket string) error {
+	if !validLocalBucket(bucket) {
+		return fmt.Errorf("invalid bucket name pattern")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.buckets[bucket]; ok {
+		return ErrBucketAlreadyExists
+	}
+	r.buckets[bucket] = &memoryBucket{
+		created:    time.Now().UTC(),
+		versioning: VersioningDisabled,
+		objects:    make(map[string]*memoryObject),
+		history:    make(map[string][]*memoryObject),
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListBuckets(ctx context.Context) ([]BucketSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var res []BucketSummary
+	for name, b := range r.buckets {
+		res = append(res, BucketSummary{Name: name, CreationDate: b.created})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (r *MemoryRepository) GetStats(ctx context.Context, bucket, prefix string) (*BucketStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+
+	stats := newBucketStats(bucket, prefix)
+	for key, obj := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			stats.add(StatsObject{Key: key, SizeBytes: int64(len(obj.data)), LastModified: obj.modTime}, localStorageClass)
+		}
+	}
+	return stats.summarize(), nil
+}
+
+func (r *MemoryRepository) DeleteAll(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+
+	report := &EmptyBucketReport{Bucket: bucket, ObjectsDeleted: len(b.objects)}
+	for _, versions := range b.history {
+		report.ObjectsDeleted += len(versions)
+	}
+	for id, u := range r.uploads {
+		if u.bucket == bucket {
+			delete(r.uploads, id)
+			report.UploadsAborted++
+		}
+	}
+
+	b.objects = make(map[string]*memoryObject)
+	b.history = make(map[string][]*memoryObject)
+	if progress != nil {
+		progress(*report)
+	}
+	return report, nil
+}
+
+func (r *MemoryRepository) DeleteBucket(ctx context.Context, bucket string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+	if len(b.objects) > 0 || len(b.history) > 0 {
+		return fmt.Errorf("failed to delete bucket: bucket %s is not empty", bucket)
+	}
+	delete(r.buckets, bucket)
+	return nil
+}
+
+func (r *MemoryRepository) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (string, error) {
+	if !validLocalKey(key) {
+		return "", ErrInvalidObjectKey
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.buckets[bucket]; !ok {
+		return "", ErrBucketNotFound
+	}
+
+	uploadID := uuid.NewString()
+	r.uploads[uploadID] = &memoryUpload{
+		bucket:      bucket,
+		key:         key,
+		contentType: contentType,
+		metadata:    maps.Clone(metadata),
+		parts:       make(map[int32]*memoryObject),
+	}
+	return uploadID, nil
+}
+
+func (r *MemoryRepository) PresignUploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, expiration time.Duration) (string, error) {
+	if _, err := r.upload(bucket, key, uploadID); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("uploadId", uploadID)
+	params.Set("partNumber", strconv.Itoa(int(partNumber)))
+	return r.signer.sign(http.MethodPut, bucket, key, time.Now().Add(expiration), params), nil
+}
+
+func (r *MemoryRepository) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (string, error) {
+	if !validPartNumber(partNumber) {
+		return "", ErrInvalidPartNumber
+	}
+	return r.writePart(ctx, bucket, key, uploadID, partNumber, io.LimitReader(body, size))
+}
+
+func (r *MemoryRepository) ListParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, err := r.uploadLocked(bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]UploadedPart, 0, len(u.parts))
+	for n, p := range u.parts {
+		parts = append(parts, UploadedPart{
+			PartNumber:   n,
+			ETag:         p.etag,
+			Size:         int64(len(p.data)),
+			LastModified: p.modTime,
+		})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (r *MemoryRepository) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, err := r.uploadLocked(bucket, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return "", ErrBucketNotFound
+	}
+
+	stored := make(map[int32]string, len(u.parts))
+	for n, p := range u.parts {
+		stored[n] = p.etag
+	}
+	if err := checkCompletedParts(parts, stored); err != nil {
+		return "", err
+	}
+
+	var (
+		buf   bytes.Buffer
+		etags []string
+	)
+	for _, p := range parts {
+		buf.Write(u.parts[p.PartNumber].data)
+		etags = append(etags, u.parts[p.PartNumber].etag)
+	}
+
+	b.put(key, &memoryObject{
+		data:        buf.Bytes(),
+		contentType: u.contentType,
+		metadata:    u.metadata,
+		etag:        multipartETag(etags),
+		modTime:     time.Now().UTC(),
+	})
+	delete(r.uploads, uploadID)
+
+	return r.signer.downloadURL(bucket, key), nil
+}
+
+func (r *MemoryRepository) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.uploadLocked(bucket, key, uploadID); err != nil {
+		return err
+	}
+	delete(r.uploads, uploadID)
+	return nil
+}
+
+func (r *MemoryRepository) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	serveLocal(w, req, r.signer, r)
+}
+
+func (r *MemoryRepository) putObject(ctx context.Context, bucket, key, contentType string, body io.Reader) (string, error) {
+	return r.writeObject(ctx, bucket, key, body, memoryObject{contentType: contentType})
+}
+
+func (r *MemoryRepository) writeObject(ctx context.Context, bucket, key string, body io.Reader, attrs memoryObject) (string, error) {
+	if !validLocalKey(key) {
+		return "", ErrInvalidObjectKey
+	}
+
+	data, err := io.ReadAll(readerWithContext(ctx, body))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+	sum := md5.Sum(data)
+	etag := hex.EncodeToString(sum[:])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return "", ErrBucketNotFound
+	}
+
+	b.put(key, &memoryObject{
+		data:        data,
+		contentType: attrs.contentType,
+		etag:        etag,
+		metadata:    maps.Clone(attrs.metadata),
+		tags:        maps.Clone(attrs.tags),
+		modTime:     time.Now().UTC(),
+	})
+	return etag, nil
+}
+
+func (r *MemoryRepository) writePart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.Reader) (string, error) {
+	data, err := io.ReadAll(readerWithContext(ctx, body))
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(data)
+	etag := hex.EncodeToString(sum[:])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, err := r.uploadLocked(bucket, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	u.parts[partNumber] = &memoryObject{data: data, etag: etag, modTime: time.Now().UTC()}
+	return etag, nil
+}
+
+func (r *MemoryRepository) upload(bucket, key, uploadID string) (*memoryUpload, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.uploadLocked(bucket, key, uploadID)
+}
+
+func (r *MemoryRepository) uploadLocked(bucket, key, uploadID string) (*memoryUpload, error) {
+	u, ok := r.uploads[uploadID]
+	if !ok || u.bucket != bucket || u.key != key {
+		return nil, ErrUploadNotFound
+	}
+	return u, nil
+}
+
+func (r *MemoryRepository) openObject(bucket, key, versionID string) (*localObject, func(), error) {
+	obj, err := r.objectVersion(bucket, key, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contentType := obj.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &localObject{
+		content:     bytes.NewReader(obj.data),
+		contentType: contentType,
+		etag:        obj.etag,
+		versionID:   obj.versionID,
+		metadata:    maps.Clone(obj.metadata),
+		tags:        maps.Clone(obj.tags),
+		modTime:     obj.modTime,
+	}, func() {}, nil
+}
+
+func (r *MemoryRepository) object(bucket, key string) (*memoryObject, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return obj, nil
+}
+
+func (r *MemoryRepository) objectVersion(bucket, key, versionID string) (*memoryObject, error) {
+	if versionID == "" {
+		return r.object(bucket, key)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.buckets[bucket]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+	return b.version(key, versionID)
+}
+
+// put stores obj as the current version of key, keeping the previous one
+// as history unless versioning has never been enabled on the bucket.
+func (b *memoryBucket) put(key string, obj *memoryObject) string {
+	obj.versionID = newVersionID(b.versioning)
+	if cur, ok := b.objects[key]; ok && b.versioning != VersioningDisabled {
+		b.archive(key, cur)
+	}
+	b.objects[key] = obj
+	if obj.versionID == nullVersionID {
+		b.dropNullVersion(key)
+	}
+	return obj.versionID
+}
+
+func (b *memoryBucket) archive(key string, obj *memoryObject) {
+	if obj.versionID == nullVersionID {
+		b.dropNullVersion(key)
+	}
+	b.history[key] = append(b.history[key], obj)
+}
+
+func (b *memoryBucket) dropNullVersion(key string) {
+	b.history[key] = slices.DeleteFunc(b.history[key], func(v *memoryObject) bool {
+		return v.versionID == nullVersionID
+	})
+	if len(b.history[key]) == 0 {
+		delete(b.history, key)
+	}
+}
+
+// promote makes the newest noncurrent version of key current again once the
+// current version is gone, unless that version is a delete marker.
+func (b *memoryBucket) promote(key string) {
+	history := b.history[key]
+	if _, ok := b.objects[key]; !ok && len(history) > 0 && !history[len(history)-1].deleteMarker {
+		b.objects[key] = history[len(history)-1]
+		history = history[:len(history)-1]
+	}
+
+	b.history[key] = history
+	if len(history) == 0 {
+		delete(b.history, key)
+	}
+}
+
+func (b *memoryBucket) version(key, versionID string) (*memoryObject, error) {
+	if cur, ok := b.objects[key]; ok && cur.versionID == versionID {
+		return cur, nil
+	}
+	for _, v := range b.history[key] {
+		if v.versionID == versionID && !v.deleteMarker {
+			return v, nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+func (b *memoryBucket) sortedKeys(prefix string) []string {
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,232 @@
+package upload
+
+import (
+	"fmt"
+	"maps"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	metadataFormPrefix = "x-amz-meta-"
+	taggingFormField   = "x-amz-tagging"
+
+	// originalNameKey is the reserved metadata entry holding the name the file
+	// was uploaded with, since the object key is generated.
+	originalNameKey     = "original-filename"
+	maxOriginalNameSize = 255
+)
+
+var (
+	metadataKeyPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	metadataValuePattern = regexp.MustCompile(`^[\x20-\x7e]*$`)
+	tagPattern           = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+)
+
+type MetadataLimits struct {
+	MaxMetadataKeys   int
+	MaxMetadataBytes  int
+	MaxTags           int
+	MaxTagKeyLength   int
+	MaxTagValueLength int
+}
+
+func DefaultMetadataLimits() MetadataLimits {
+	return MetadataLimits{
+		MaxMetadataKeys:   16,
+		MaxMetadataBytes:  2048,
+		MaxTags:           10,
+		MaxTagKeyLength:   128,
+		MaxTagValueLength: 256,
+	}
+}
+
+func (l MetadataLimits) normalized() MetadataLimits {
+	def := DefaultMetadataLimits()
+	if l.MaxMetadataKeys <= 0 {
+		l.MaxMetadataKeys = def.MaxMetadataKeys
+	}
+	if l.MaxMetadataBytes <= 0 {
+		l.MaxMetadataBytes = def.MaxMetadataBytes
+	}
+	if l.MaxTags <= 0 {
+		l.MaxTags = def.MaxTags
+	}
+	if l.MaxTagKeyLength <= 0 {
+		l.MaxTagKeyLength = def.MaxTagKeyLength
+	}
+	if l.MaxTagValueLength <= 0 {
+		l.MaxTagValueLength = def.MaxTagValueLength
+	}
+	return l
+}
+
+func (l MetadataLimits) validateMetadata(meta map[string]string) error {
+	if len(meta) > l.MaxMetadataKeys {
+		return fmt.Errorf("%w: at most %d metadata keys are allowed", ErrInvalidMetadata, l.MaxMetadataKeys)
+	}
+
+	total := 0
+	for k, v := range meta {
+		if !metadataKeyPattern.MatchString(k) {
+			return fmt.Errorf("%w: metadata key %q must be lowercase letters, digits, '-' or '_'", ErrInvalidMetadata, k)
+		}
+		if !metadataValuePattern.MatchString(v) {
+			return fmt.Errorf("%w: metadata value for %q must be printable ASCII", ErrInvalidMetadata, k)
+		}
+		total += len(k) + len(v)
+	}
+	if total > l.MaxMetadataBytes {
+		return fmt.Errorf("%w: metadata exceeds %d bytes", ErrInvalidMetadata, l.MaxMetadataBytes)
+	}
+	return nil
+}
+
+func (l MetadataLimits) validateTags(tags map[string]string) error {
+	if len(tags) > l.MaxTags {
+		return fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidMetadata, l.MaxTags)
+	}
+
+	for k, v := range tags {
+		if k == "" || utf8.RuneCountInString(k) > l.MaxTagKeyLength {
+			return fmt.Errorf("%w: tag keys must be 1 to %d characters", ErrInvalidMetadata, l.MaxTagKeyLength)
+		}
+		if utf8.RuneCountInString(v) > l.MaxTagValueLength {
+			return fmt.Errorf("%w: tag values must be at most %d characters", ErrInvalidMetadata, l.MaxTagValueLength)
+		}
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			return fmt.Errorf("%w: tag keys must not use the aws: prefix", ErrInvalidMetadata)
+		}
+		if !tagPattern.MatchString(k) || !tagPattern.MatchString(v) {
+			return fmt.Errorf("%w: tag %q contains unsupported characters", ErrInvalidMetadata, k)
+		}
+	}
+	return nil
+}
+
+func hasTags(tags, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTagFilters(values []string) (map[string]string, error) {
+	return parseKeyValueFilters("tag", values)
+}
+
+func parseKeyValueFilters(kind string, values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	filters := make(map[string]string, len(values))
+	for _, v := range values {
+		k, val, ok := strings.Cut(v, ":")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%w: %s filter must be key:value", ErrInvalidMetadata, kind)
+		}
+		filters[k] = val
+	}
+	return filters, nil
+}
+
+func metadataFromForm(values map[string][]string) (map[string]string, map[string]string, error) {
+	var meta, tags map[string]string
+
+	for name, vs := range values {
+		if len(vs) == 0 {
+			continue
+		}
+		lower := strings.ToLower(name)
+
+		if key, ok := strings.CutPrefix(lower, metadataFormPrefix); ok {
+			if meta == nil {
+				meta = make(map[string]string)
+			}
+			meta[key] = vs[0]
+			continue
+		}
+
+		if lower == taggingFormField {
+			parsed, err := parseTagging(vs[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			tags = parsed
+		}
+	}
+	return meta, tags, nil
+}
+
+func parseTagging(raw string) (map[string]string, error) {
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tagging must be URL query encoded", ErrInvalidMetadata)
+	}
+
+	tags := make(map[string]string, len(q))
+	for k, vs := range q {
+		if len(vs) > 1 {
+			return nil, fmt.Errorf("%w: duplicate tag %q", ErrInvalidMetadata, k)
+		}
+		tags[k] = vs[0]
+	}
+	return tags, nil
+}
+
+func encodeTagging(tags map[string]string) string {
+	q := url.Values{}
+	for k, v := range tags {
+		q.Set(k, v)
+	}
+	return q.Encode()
+}
+
+// withOriginalName returns a copy of meta that records name under
+// originalNameKey. Metadata travels as HTTP headers, so names outside
+// printable ASCII are stored RFC 2047 encoded.
+func withOriginalName(meta map[string]string, name string) map[string]string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" || name == "." || name == "/" {
+		return meta
+	}
+	for len(name) > maxOriginalNameSize {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	out := maps.Clone(meta)
+	if out == nil {
+		out = make(map[string]string, 1)
+	}
+	out[originalNameKey] = mime.QEncoding.Encode("utf-8", name)
+	return out
+}
+
+// splitOriginalName separates the stored original filename from the user
+// metadata it was saved with.
+func splitOriginalName(meta map[string]string) (string, map[string]string) {
+	encoded, ok := meta[originalNameKey]
+	if !ok {
+		return "", meta
+	}
+
+	rest := maps.Clone(meta)
+	delete(rest, originalNameKey)
+	if len(rest) == 0 {
+		rest = nil
+	}
+
+	name, err := new(mime.WordDecoder).DecodeHeader(encoded)
+	if err != nil {
+		return encoded, rest
+	}
+	return name, rest
+}
@@ -0,0 +1,113 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultKeyTemplate names objects by a UUIDv7 and the original extension.
+const DefaultKeyTemplate = "{uuid}{ext}"
+
+// UserHeader carries the caller identity used by the {user} placeholder. It is
+// meant to be set by an authenticating proxy in front of the API.
+const UserHeader = "X-User-Id"
+
+const (
+	anonymousUser    = "anonymous"
+	maxKeyPartLength = 64
+)
+
+var (
+	keyPlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+	unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// KeyTemplate builds object keys for new uploads. Supported placeholders are
+// {uuid}, {ext}, {name} (the original filename without extension), {user},
+// {yyyy}, {mm} and {dd}. {name} and {user} are reduced to letters, digits,
+// '.', '_' and '-' so they cannot introduce extra path segments.
+type KeyTemplate struct {
+	raw string
+}
+
+// ParseKeyTemplate checks that tmpl only uses known placeholders, contains
+// {uuid} so keys never collide, and expands to a valid object key.
+func ParseKeyTemplate(tmpl string) (KeyTemplate, error) {
+	t := KeyTemplate{raw: tmpl}
+	if tmpl == "" {
+		t.raw = DefaultKeyTemplate
+	}
+
+	known := keyValues("", "", "", time.Time{})
+	for _, m := range keyPlaceholder.FindAllStringSubmatch(t.raw, -1) {
+		if _, ok := known[m[1]]; !ok {
+			return t, fmt.Errorf("unknown key template placeholder %s", m[0])
+		}
+	}
+	if !strings.Contains(t.raw, "{uuid}") {
+		return t, errors.New("key template must contain {uuid}")
+	}
+
+	sample := t.expand("0192d3a6-0000-7000-8000-000000000000", "file.pdf", "user", time.Now())
+	if strings.ContainsAny(sample, "{}") || !validLocalKey(sample) {
+		return t, fmt.Errorf("key template %q does not produce a valid object key", t.raw)
+	}
+	return t, nil
+}
+
+func (t KeyTemplate) String() string {
+	return t.raw
+}
+
+func (t KeyTemplate) expand(id, filename, user string, now time.Time) string {
+	values := keyValues(id, filename, user, now.UTC())
+	return keyPlaceholder.ReplaceAllStringFunc(t.raw, func(m string) string {
+		if v, ok := values[m[1:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+func keyValues(id, filename, user string, now time.Time) map[string]string {
+	ext := filepath.Ext(filename)
+	return map[string]string{
+		"uuid": id,
+		"ext":  ext,
+		"name": keyPart(strings.TrimSuffix(filepath.Base(filename), ext), "file"),
+		"user": keyPart(user, anonymousUser),
+		"yyyy": now.Format("2006"),
+		"mm":   now.Format("01"),
+		"dd":   now.Format("02"),
+	}
+}
+
+// keyPart makes s safe to embed in a key segment, falling back to def when
+// nothing usable is left.
+func keyPart(s, def string) string {
+	s = strings.Trim(unsafeKeyChars.ReplaceAllString(s, "-"), "-.")
+	if len(s) > maxKeyPartLength {
+		s = s[:maxKeyPartLength]
+	}
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+type userContextKey struct{}
+
+// WithUser attaches the caller identity used by the {user} key placeholder.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+func userFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey{}).(string)
+	return user
+}
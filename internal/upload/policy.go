@@ -0,0 +1,286 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+)
+
+// Rule names reported by PolicyViolation. They match the JSON fields of a
+// policy file so a rejection points straight at the line to change.
+const (
+	RuleAllowedTypes      = "allowed_types"
+	RuleAllowedExtensions = "allowed_extensions"
+	RuleMaxSize           = "max_size_bytes"
+	RuleMatchSniffedType  = "match_sniffed_type"
+
+	anyBucket     = "*"
+	defaultPolicy = "default"
+)
+
+// Policy lists the checks applied to uploads in one scope. In a rule, empty
+// fields fall back to the default policy.
+type Policy struct {
+	// AllowedTypes are media types accepted after sniffing the content. A
+	// trailing wildcard such as "image/*" allows a whole family.
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+	// AllowedExtensions restricts the original filename extension; empty
+	// allows any.
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+	// MaxSize caps the object size, never above MAX_UPLOAD_SIZE_MB when that
+	// is set; zero means no limit of its own.
+	MaxSize int64 `json:"max_size_bytes,omitempty"`
+	// MatchSniffedType requires the declared Content-Type and the extension
+	// to agree with the sniffed type.
+	MatchSniffedType *bool `json:"match_sniffed_type,omitempty"`
+}
+
+// PolicyRule scopes a Policy to a bucket, or every bucket with "*", and
+// optionally to keys under Prefix. Prefixes match the generated object key.
+type PolicyRule struct {
+	Name   string `json:"name,omitempty"`
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix,omitempty"`
+	Policy
+}
+
+// Policies is the content of an upload policy file. The most specific rule
+// wins: a named bucket over "*", then the longest prefix.
+type Policies struct {
+	Default Policy       `json:"default"`
+	Rules   []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyViolation names the policy and rule that rejected an upload. It wraps
+// ErrFileTooLarge for size limits and ErrInvalidFileType otherwise.
+type PolicyViolation struct {
+	Policy string
+	Rule   string
+	Reason string
+	err    error
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s (policy %q, rule %s)", v.err, v.Reason, v.Policy, v.Rule)
+}
+
+func (v *PolicyViolation) Unwrap() error {
+	return v.err
+}
+
+// DefaultPolicies accepts JPEG, PNG, WebP and PDF files of any extension up
+// to the global size limit.
+func DefaultPolicies() Policies {
+	return Policies{Default: Policy{
+		AllowedTypes: []string{"image/jpeg", "image/png", "image/webp", "application/pdf"},
+	}}
+}
+
+// LoadPolicies reads a JSON policy file. Unknown fields are rejected so a typo
+// cannot silently disable a check.
+func LoadPolicies(file string) (Policies, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Policies{}, err
+	}
+
+	var p Policies
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return Policies{}, fmt.Errorf("parse %s: %w", file, err)
+	}
+	if err := p.validate(); err != nil {
+		return Policies{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return p.normalized(), nil
+}
+
+// normalized falls back to the default allowed types when the default policy
+// lists none, so an omitted field never rejects every upload.
+func (p Policies) normalized() Policies {
+	if len(p.Default.AllowedTypes) == 0 {
+		p.Default.AllowedTypes = DefaultPolicies().Default.AllowedTypes
+	}
+	return p
+}
+
+func (p Policies) validate() error {
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default policy: %w", err)
+	}
+
+	seen := make(map[string]bool, len(p.Rules))
+	for i, r := range p.Rules {
+		if r.Bucket == "" {
+			return fmt.Errorf("rule %d: bucket is required, use %q for every bucket", i, anyBucket)
+		}
+		scope := r.Bucket + "/" + r.Prefix
+		if seen[scope] {
+			return fmt.Errorf("rule %d: another rule already covers %s", i, scope)
+		}
+		seen[scope] = true
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, r.name(), err)
+		}
+	}
+	return nil
+}
+
+func (p Policy) validate() error {
+	for _, t := range p.AllowedTypes {
+		mediaType, params, err := mime.ParseMediaType(t)
+		if err != nil || len(params) > 0 || !strings.Contains(mediaType, "/") {
+			return fmt.Errorf("invalid media type %q in %s", t, RuleAllowedTypes)
+		}
+	}
+	for _, ext := range p.AllowedExtensions {
+		if strings.Trim(ext, ".") == "" || strings.ContainsAny(ext, "/\\ ") {
+			return fmt.Errorf("invalid extension %q in %s", ext, RuleAllowedExtensions)
+		}
+	}
+	if p.MaxSize < 0 {
+		return fmt.Errorf("%s must not be negative", RuleMaxSize)
+	}
+	return nil
+}
+
+func (r PolicyRule) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Bucket + "/" + r.Prefix
+}
+
+// WithPolicies replaces DefaultPolicies. The policies should come from
+// LoadPolicies or be checked the same way.
+func WithPolicies(p Policies) ServiceOption {
+	return func(s *uploadService) {
+		s.policies = p.normalized()
+	}
+}
+
+// uploadPolicy is a rule merged over the default policy, ready to check a
+// single upload.
+type uploadPolicy struct {
+	name         string
+	types        []string
+	extensions   []string
+	maxSize      int64
+	matchSniffed bool
+}
+
+func (s *uploadService) policyFor(bucket, key string) uploadPolicy {
+	var best *PolicyRule
+	for i, r := range s.policies.Rules {
+		if (r.Bucket != bucket && r.Bucket != anyBucket) || !strings.HasPrefix(key, r.Prefix) {
+			continue
+		}
+		if best == nil || moreSpecific(r, *best) {
+			best = &s.policies.Rules[i]
+		}
+	}
+
+	def := s.policies.Default
+	p := uploadPolicy{
+		name:         defaultPolicy,
+		types:        def.AllowedTypes,
+		extensions:   def.AllowedExtensions,
+		maxSize:      def.MaxSize,
+		matchSniffed: def.MatchSniffedType != nil && *def.MatchSniffedType,
+	}
+	if best != nil {
+		p.name = best.name()
+		if len(best.AllowedTypes) > 0 {
+			p.types = best.AllowedTypes
+		}
+		if len(best.AllowedExtensions) > 0 {
+			p.extensions = best.AllowedExtensions
+		}
+		if best.MaxSize > 0 {
+			p.maxSize = best.MaxSize
+		}
+		if best.MatchSniffedType != nil {
+			p.matchSniffed = *best.MatchSniffedType
+		}
+	}
+
+	if s.maxUploadSize > 0 && (p.maxSize <= 0 || p.maxSize > s.maxUploadSize) {
+		p.maxSize = s.maxUploadSize
+	}
+	return p
+}
+
+func moreSpecific(a, b PolicyRule) bool {
+	if (a.Bucket == anyBucket) != (b.Bucket == anyBucket) {
+		return b.Bucket == anyBucket
+	}
+	return len(a.Prefix) > len(b.Prefix)
+}
+
+func (p uploadPolicy) violation(rule string, err error, format string, args ...any) error {
+	return &PolicyViolation{Policy: p.name, Rule: rule, Reason: fmt.Sprintf(format, args...), err: err}
+}
+
+func (p uploadPolicy) allowsType(mediaType string) bool {
+	for _, t := range p.types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeclared applies the rules that need no content: the size, when known,
+// the extension of filename and the declared contentType, when given.
+func (p uploadPolicy) checkDeclared(filename, contentType string, size int64) error {
+	if p.maxSize > 0 && size > p.maxSize {
+		return p.violation(RuleMaxSize, ErrFileTooLarge, "size %d exceeds the limit of %d bytes", size, p.maxSize)
+	}
+
+	if len(p.extensions) > 0 {
+		ext := strings.ToLower(path.Ext(filename))
+		allowed := false
+		for _, e := range p.extensions {
+			if strings.EqualFold(ext, "."+strings.TrimPrefix(e, ".")) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return p.violation(RuleAllowedExtensions, ErrInvalidFileType, "extension %q is not one of %s", ext, strings.Join(p.extensions, ", "))
+		}
+	}
+
+	if contentType != "" && !p.allowsType(contentType) {
+		return p.violation(RuleAllowedTypes, ErrInvalidFileType, "declared type %s is not one of %s", contentType, strings.Join(p.types, ", "))
+	}
+	return nil
+}
+
+// checkContent checks the detected type and, when the policy asks for it,
+// compares it with the declared contentType and the extension of filename.
+func (p uploadPolicy) checkContent(detected, filename, contentType string) error {
+	if !p.allowsType(detected) {
+		return p.violation(RuleAllowedTypes, ErrInvalidFileType, "detected type %s is not one of %s", detected, strings.Join(p.types, ", "))
+	}
+	if !p.matchSniffed {
+		return nil
+	}
+
+	if declared, _, err := mime.ParseMediaType(contentType); err == nil && declared != "application/octet-stream" && declared != detected {
+		return p.violation(RuleMatchSniffedType, ErrInvalidFileType, "declared type %s does not match detected type %s", declared, detected)
+	}
+	if ext := path.Ext(filename); ext != "" {
+		if inspect.TypeByExtension(ext) != detected {
+			return p.violation(RuleMatchSniffedType, ErrInvalidFileType, "extension %s does not match detected type %s", ext, detected)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package upload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPolicies_RejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown field": `{"default": {"allowed_type": ["image/png"]}}`,
+		"bad type":      `{"default": {"allowed_types": ["png"]}}`,
+		"no bucket":     `{"rules": [{"prefix": "a/"}]}`,
+		"duplicate":     `{"rules": [{"bucket": "*", "prefix": "a/"}, {"bucket": "*", "prefix": "a/"}]}`,
+		"negative size": `{"rules": [{"bucket": "docs", "max_size_bytes": -1}]}`,
+	} {
+		file := filepath.Join(dir, "policies.json")
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		_, err := LoadPolicies(file)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestPolicyFor_MostSpecificRuleWins(t *testing.T) {
+	yes := true
+	s := NewService(nil, WithMaxUploadSize(1000), WithPolicies(Policies{
+		Rules: []PolicyRule{
+			{Bucket: "*", Prefix: "public/", Policy: Policy{MaxSize: 10}},
+			{Bucket: "docs", Policy: Policy{AllowedTypes: []string{"application/pdf"}, MatchSniffedType: &yes}},
+			{Bucket: "docs", Prefix: "public/big/", Policy: Policy{MaxSize: 5000}},
+		},
+	})).(*uploadService)
+
+	p := s.policyFor("media", "a.png")
+	assert.Equal(t, "default", p.name)
+	assert.Equal(t, int64(1000), p.maxSize)
+	assert.True(t, p.allowsType("image/png"))
+
+	assert.Equal(t, int64(10), s.policyFor("media", "public/a.png").maxSize)
+
+	p = s.policyFor("docs", "public/a.pdf")
+	assert.Equal(t, "docs/", p.name, "a named bucket beats a longer wildcard prefix")
+	assert.True(t, p.matchSniffed)
+	assert.False(t, p.allowsType("image/png"))
+
+	p = s.policyFor("docs", "public/big/a.pdf")
+	assert.Equal(t, int64(1000), p.maxSize, "MAX_UPLOAD_SIZE_MB stays the ceiling when set")
+	assert.True(t, p.allowsType("image/png"), "unset fields fall back to the default policy")
+
+	unlimited := NewService(nil).(*uploadService).policyFor("media", "a.png")
+	assert.Zero(t, unlimited.maxSize)
+	assert.NoError(t, unlimited.checkDeclared("a.png", "image/png", 50<<30), "no size limit unless one is configured")
+}
@@ -6,16 +6,36 @@ import (
 	"time"
 )
 
+// Repository is the storage backend. Stat may leave ObjectInfo.Tags empty
+// when tags cost an extra request; callers that need them use GetObjectTags.
 type Repository interface {
 	Upload(ctx context.Context, bucket string, file *File) (string, error)
-	GetPresignURL(ctx context.Context, bucket, key string, expiration time.Duration) (string, error)
-	Download(ctx context.Context, bucket, key string) (io.ReadCloser, error)
-	List(ctx context.Context, bucket, prefix, token string, limit int32) (*PaginatedFiles, error)
+	GetPresignURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error)
+	PresignUpload(ctx context.Context, bucket, key string, opts UploadPresignOptions) (*PresignedUpload, error)
+	Download(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error)
+	Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+	GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error)
+	PutObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error
+	DeleteObjectTags(ctx context.Context, bucket, key string) error
+	List(ctx context.Context, bucket string, opts ListOptions) (*PaginatedFiles, error)
 	Delete(ctx context.Context, bucket string, key string) error
+	DeleteMany(ctx context.Context, bucket string, keys []string) ([]DeleteResult, error)
+	Copy(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error)
+	DeleteVersion(ctx context.Context, bucket, key, versionID string) error
+	ListVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error)
+	RestoreVersion(ctx context.Context, bucket, key, versionID string) (string, error)
+	GetBucketVersioning(ctx context.Context, bucket string) (string, error)
+	SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error
 	CheckBucketExists(ctx context.Context, bucket string) (bool, error)
 	CreateBucket(ctx context.Context, bucket string) error
 	ListBuckets(ctx context.Context) ([]BucketSummary, error)
-	GetStats(ctx context.Context, bucket string) (*BucketStats, error)
-	DeleteAll(ctx context.Context, bucket string) error
+	GetStats(ctx context.Context, bucket, prefix string) (*BucketStats, error)
+	DeleteAll(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error)
 	DeleteBucket(ctx context.Context, bucket string) error
+	CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (string, error)
+	PresignUploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, expiration time.Duration) (string, error)
+	UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (string, error)
+	ListParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error)
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
 }
@@ -16,7 +16,7 @@ func (m *RepositoryMock) Delete(ctx context.Context, bucket string, key string)
 	panic("unimplemented")
 }
 
-func (m *RepositoryMock) DeleteAll(ctx context.Context, bucket string) error {
+func (m *RepositoryMock) DeleteAll(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error) {
 	panic("unimplemented")
 }
 
@@ -24,7 +24,7 @@ func (m *RepositoryMock) DeleteBucket(ctx context.Context, bucket string) error
 	panic("unimplemented")
 }
 
-func (m *RepositoryMock) GetStats(ctx context.Context, bucket string) (*BucketStats, error) {
+func (m *RepositoryMock) GetStats(ctx context.Context, bucket, prefix string) (*BucketStats, error) {
 	panic("unimplemented")
 }
 
@@ -37,21 +37,96 @@ func (m *RepositoryMock) Upload(ctx context.Context, bucket string, file *File)
 	return args.String(0), args.Error(1)
 }
 
-func (m *RepositoryMock) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+func (m *RepositoryMock) Download(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error) {
+	args := m.Called(ctx, bucket, key, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Object), args.Error(1)
+}
+
+func (m *RepositoryMock) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	args := m.Called(ctx, bucket, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ObjectInfo), args.Error(1)
+}
+
+func (m *RepositoryMock) GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
 	args := m.Called(ctx, bucket, key)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(io.ReadCloser), args.Error(1)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (m *RepositoryMock) PutObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error {
+	args := m.Called(ctx, bucket, key, tags)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) DeleteObjectTags(ctx context.Context, bucket, key string) error {
+	args := m.Called(ctx, bucket, key)
+	return args.Error(0)
 }
 
-func (m *RepositoryMock) GetPresignURL(ctx context.Context, bucket, key string, expiration time.Duration) (string, error) {
-	args := m.Called(ctx, bucket, key, expiration)
+func (m *RepositoryMock) DeleteMany(ctx context.Context, bucket string, keys []string) ([]DeleteResult, error) {
+	args := m.Called(ctx, bucket, keys)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]DeleteResult), args.Error(1)
+}
+
+func (m *RepositoryMock) Copy(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	args := m.Called(ctx, srcBucket, srcKey, dstBucket, dstKey, opts)
 	return args.String(0), args.Error(1)
 }
 
-func (m *RepositoryMock) List(ctx context.Context, bucket, prefix, token string, limit int32) (*PaginatedFiles, error) {
-	args := m.Called(ctx, bucket, prefix, token, limit)
+func (m *RepositoryMock) DeleteVersion(ctx context.Context, bucket, key, versionID string) error {
+	args := m.Called(ctx, bucket, key, versionID)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) ListVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error) {
+	args := m.Called(ctx, bucket, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PaginatedVersions), args.Error(1)
+}
+
+func (m *RepositoryMock) RestoreVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	args := m.Called(ctx, bucket, key, versionID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	args := m.Called(ctx, bucket)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error {
+	args := m.Called(ctx, bucket, enabled)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetPresignURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error) {
+	args := m.Called(ctx, bucket, key, opts)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) PresignUpload(ctx context.Context, bucket, key string, opts UploadPresignOptions) (*PresignedUpload, error) {
+	args := m.Called(ctx, bucket, key, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PresignedUpload), args.Error(1)
+}
+
+func (m *RepositoryMock) List(ctx context.Context, bucket string, opts ListOptions) (*PaginatedFiles, error) {
+	args := m.Called(ctx, bucket, opts)
 	return args.Get(0).(*PaginatedFiles), args.Error(1)
 }
 
@@ -64,3 +139,36 @@ func (m *RepositoryMock) CreateBucket(ctx context.Context, bucket string) error
 	args := m.Called(ctx, bucket)
 	return args.Error(0)
 }
+
+func (m *RepositoryMock) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (string, error) {
+	args := m.Called(ctx, bucket, key, contentType, metadata)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) PresignUploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, expiration time.Duration) (string, error) {
+	args := m.Called(ctx, bucket, key, uploadID, partNumber, expiration)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (string, error) {
+	args := m.Called(ctx, bucket, key, uploadID, partNumber, body, size)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) ListParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error) {
+	args := m.Called(ctx, bucket, key, uploadID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]UploadedPart), args.Error(1)
+}
+
+func (m *RepositoryMock) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error) {
+	args := m.Called(ctx, bucket, key, uploadID, parts)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RepositoryMock) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	args := m.Called(ctx, bucket, key, uploadID)
+	return args.Error(0)
+}
@@ -0,0 +1,169 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+func (r *S3Repository) Copy(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	src, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to copy object: %w", mapS3Error(err))
+	}
+
+	source := copySource(srcBucket, srcKey)
+	size := aws.ToInt64(src.ContentLength)
+
+	if size > maxCopyObjectSize {
+		if _, err := r.copyMultipart(ctx, source, srcBucket, srcKey, "", dstBucket, dstKey, src, opts); err != nil {
+			return "", fmt.Errorf("failed to copy object: %w", mapS3Error(err))
+		}
+		return r.objectURL(dstBucket, dstKey), nil
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(source),
+	}
+	if opts.Metadata != nil {
+		input.MetadataDirective = types.MetadataDirectiveReplace
+		input.Metadata = opts.Metadata
+		input.ContentType = src.ContentType
+	}
+	if opts.Tags != nil {
+		input.TaggingDirective = types.TaggingDirectiveReplace
+		input.Tagging = aws.String(encodeTagging(opts.Tags))
+	}
+
+	if _, err := r.client.CopyObject(ctx, input); err != nil {
+		return "", fmt.Errorf("failed to copy object: %w", mapS3Error(err))
+	}
+	return r.objectURL(dstBucket, dstKey), nil
+}
+
+// copyMultipart copies objects above the 5 GB CopyObject limit with
+// UploadPartCopy and returns the version it created. Metadata and tags are
+// not carried over by multipart copies, so they are read from the source, at
+// srcVersionID when set, and set on the new upload explicitly.
+func (r *S3Repository) copyMultipart(ctx context.Context, source, srcBucket, srcKey, srcVersionID, dstBucket, dstKey string, src *s3.HeadObjectOutput, opts CopyOptions) (string, error) {
+	metadata, tags := src.Metadata, opts.Tags
+	if opts.Metadata != nil {
+		metadata = opts.Metadata
+	}
+	if tags == nil {
+		existing, err := r.objectTags(ctx, srcBucket, srcKey, srcVersionID)
+		if err != nil {
+			return "", err
+		}
+		tags = existing
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(dstBucket),
+		Key:         aws.String(dstKey),
+		ContentType: src.ContentType,
+		Metadata:    metadata,
+	}
+	if len(tags) > 0 {
+		input.Tagging = aws.String(encodeTagging(tags))
+	}
+
+	out, err := r.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	uploadID := aws.ToString(out.UploadId)
+
+	// A version is immutable, but the current object can be overwritten while
+	// the parts are copied, so each part is pinned to the ETag read up front.
+	ifMatch := ""
+	if srcVersionID == "" {
+		ifMatch = aws.ToString(src.ETag)
+	}
+
+	parts, err := r.copyParts(ctx, source, ifMatch, dstBucket, dstKey, uploadID, aws.ToInt64(src.ContentLength))
+	if err != nil {
+		r.abortMultipart(ctx, dstBucket, dstKey, uploadID)
+		return "", err
+	}
+
+	completed, err := r.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(dstBucket),
+		Key:             aws.String(dstKey),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		r.abortMultipart(ctx, dstBucket, dstKey, uploadID)
+		return "", fmt.Errorf("failed to complete multipart copy: %w", err)
+	}
+	return aws.ToString(completed.VersionId), nil
+}
+
+func (r *S3Repository) copyParts(ctx context.Context, source, ifMatch, bucket, key, uploadID string, size int64) ([]types.CompletedPart, error) {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(r.multipart.Concurrency)
+
+	var (
+		mu    sync.Mutex
+		parts []types.CompletedPart
+	)
+
+	partSize := r.multipart.partSizeFor(size)
+	for start, number := int64(0), int32(1); start < size; start, number = start+partSize, number+1 {
+		end := min(start+partSize, size) - 1
+		partNumber := number
+		byteRange := fmt.Sprintf("bytes=%d-%d", start, end)
+
+		g.Go(func() error {
+			input := &s3.UploadPartCopyInput{
+				Bucket:          aws.String(bucket),
+				Key:             aws.String(key),
+				UploadId:        aws.String(uploadID),
+				PartNumber:      aws.Int32(partNumber),
+				CopySource:      aws.String(source),
+				CopySourceRange: aws.String(byteRange),
+			}
+			if ifMatch != "" {
+				input.CopySourceIfMatch = aws.String(ifMatch)
+			}
+
+			out, err := r.client.UploadPartCopy(gctx, input)
+			if err != nil {
+				return fmt.Errorf("failed to copy part %d: %w", partNumber, err)
+			}
+
+			mu.Lock()
+			parts = append(parts, types.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int32(partNumber)})
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return aws.ToInt32(parts[i].PartNumber) < aws.ToInt32(parts[j].PartNumber)
+	})
+	return parts, nil
+}
+
+func copySource(bucket, key string) string {
+	return url.PathEscape(bucket) + "/" + escapeKey(key)
+}
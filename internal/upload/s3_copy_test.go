@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gib = 1 << 30
+
+func TestS3Repository_CopyLargeObjectInParts(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["big.iso"] = fakeObject{
+		size:        maxCopyObjectSize + 1,
+		etag:        `"abc"`,
+		contentType: "application/x-iso9660-image",
+		metadata:    map[string]string{"original-name": "disk.iso"},
+		tags:        map[string]string{"team": "infra"},
+	}
+	repo := newTestS3Repository(t, fake, MultipartConfig{PartSize: gib, Concurrency: 4})
+
+	_, err := repo.Copy(context.Background(), "my-bucket", "big.iso", "archive", "big.iso", CopyOptions{})
+	require.NoError(t, err)
+
+	assert.Zero(t, fake.called("CopyObject"), "CopyObject is limited to 5 GB")
+	assert.Equal(t, "disk.iso", fake.created.Get("X-Amz-Meta-Original-Name"))
+	assert.Equal(t, "team=infra", fake.created.Get("X-Amz-Tagging"))
+	assert.Equal(t, "application/x-iso9660-image", fake.created.Get("Content-Type"))
+
+	require.Len(t, fake.copyRanges, 6)
+	for part := int32(1); part <= 5; part++ {
+		start := int64(part-1) * gib
+		assert.Equal(t, fmt.Sprintf("bytes=%d-%d", start, start+gib-1), fake.copyRanges[part])
+	}
+	assert.Equal(t, fmt.Sprintf("bytes=%d-%d", maxCopyObjectSize, maxCopyObjectSize), fake.copyRanges[6])
+	assert.Equal(t, []int32{1, 2, 3, 4, 5, 6}, fake.completed)
+	for part, ifMatch := range fake.copyIfMatch {
+		assert.Equal(t, `"abc"`, ifMatch, "part %d is pinned to the source ETag", part)
+	}
+}
+
+func TestS3Repository_CopyFailsWhenSourceChanges(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["big.iso"] = fakeObject{size: maxCopyObjectSize + 1, etag: `"abc"`}
+	fake.sourceETag = `"overwritten"`
+	repo := newTestS3Repository(t, fake, MultipartConfig{PartSize: gib, Concurrency: 2})
+
+	_, err := repo.Copy(context.Background(), "my-bucket", "big.iso", "archive", "big.iso", CopyOptions{Tags: map[string]string{}})
+	assert.ErrorIs(t, err, ErrSourceChanged)
+	assert.Equal(t, 1, fake.called("AbortMultipartUpload"))
+	assert.Zero(t, fake.called("CompleteMultipartUpload"))
+}
+
+func TestS3Repository_CopyAbortsFailedMultipartCopy(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["big.iso"] = fakeObject{size: maxCopyObjectSize + 1}
+	fake.failPart = func(part int32, _ int) bool {
+		return part == 4
+	}
+	repo := newTestS3Repository(t, fake, MultipartConfig{PartSize: gib, Concurrency: 2})
+
+	_, err := repo.Copy(context.Background(), "my-bucket", "big.iso", "archive", "big.iso", CopyOptions{Tags: map[string]string{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to copy part 4")
+
+	assert.Equal(t, 1, fake.called("AbortMultipartUpload"))
+	assert.Zero(t, fake.called("CompleteMultipartUpload"))
+	assert.Zero(t, fake.called("GetObjectTagging"), "explicit tags replace the source tags")
+}
+
+func TestS3Repository_CopySmallObject(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["a.png"] = fakeObject{size: 10}
+	repo := newTestS3Repository(t, fake, MultipartConfig{})
+
+	_, err := repo.Copy(context.Background(), "my-bucket", "a.png", "my-bucket", "b.png", CopyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.called("CopyObject"))
+	assert.Zero(t, fake.called("CreateMultipartUpload"))
+}
+
+func TestS3Repository_RestoreLargeVersionInParts(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["big.iso"] = fakeObject{size: maxCopyObjectSize + 1, etag: `"abc"`, tags: map[string]string{"team": "infra"}}
+	repo := newTestS3Repository(t, fake, MultipartConfig{PartSize: gib, Concurrency: 4})
+
+	restored, err := repo.RestoreVersion(context.Background(), "my-bucket", "big.iso", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", restored)
+
+	assert.Zero(t, fake.called("CopyObject"), "CopyObject is limited to 5 GB")
+	assert.Equal(t, []string{"v1", "v1"}, fake.versionIDs, "size and tags come from the restored version")
+	assert.Equal(t, "my-bucket/big.iso?versionId=v1", fake.copySource)
+	assert.Equal(t, "team=infra", fake.created.Get("X-Amz-Tagging"))
+	assert.Len(t, fake.completed, 6)
+	for _, ifMatch := range fake.copyIfMatch {
+		assert.Empty(t, ifMatch, "a version cannot change during the copy")
+	}
+}
+
+func TestS3Repository_RestoreSmallVersion(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["a.png"] = fakeObject{size: 10}
+	repo := newTestS3Repository(t, fake, MultipartConfig{})
+
+	restored, err := repo.RestoreVersion(context.Background(), "my-bucket", "a.png", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "copied", restored)
+	assert.Equal(t, 1, fake.called("CopyObject"))
+
+	_, err = repo.RestoreVersion(context.Background(), "my-bucket", "missing.png", "v1")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const emptyBucketConcurrency = 4
+
+// DeleteAll aborts the bucket's incomplete multipart uploads and then removes
+// every object version and delete marker, so versioned buckets end up truly
+// empty. Version pages are listed sequentially; each page of up to 1000
+// entries becomes one DeleteObjects call, with a few calls in flight at once.
+// progress is called after every batch, never concurrently.
+func (r *S3Repository) DeleteAll(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error) {
+	report := &EmptyBucketReport{Bucket: bucket}
+
+	aborted, err := r.abortUploads(ctx, bucket)
+	report.UploadsAborted = aborted
+	if err != nil {
+		return report, fmt.Errorf("failed to empty bucket: %w", mapS3Error(err))
+	}
+
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(emptyBucketConcurrency)
+
+	var listErr error
+	paginator := s3.NewListObjectVersionsPaginator(r.client, &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(gctx)
+		if err != nil {
+			listErr = err
+			break
+		}
+
+		objects := versionIdentifiers(page)
+		if len(objects) == 0 {
+			continue
+		}
+
+		g.Go(func() error {
+			deleted, err := r.deleteVersions(gctx, bucket, objects)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.ObjectsDeleted += deleted
+			if progress != nil {
+				progress(*report)
+			}
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return report, fmt.Errorf("failed to empty bucket: %w", mapS3Error(err))
+	}
+	if listErr != nil {
+		return report, fmt.Errorf("failed to empty bucket: %w", mapS3Error(listErr))
+	}
+	return report, nil
+}
+
+func (r *S3Repository) deleteVersions(ctx context.Context, bucket string, objects []types.ObjectIdentifier) (int, error) {
+	out, err := r.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := len(objects) - len(out.Errors)
+	if len(out.Errors) > 0 {
+		e := out.Errors[0]
+		return deleted, fmt.Errorf("%d objects could not be deleted, first %s: %s",
+			len(out.Errors), aws.ToString(e.Key), firstNonEmpty(aws.ToString(e.Message), aws.ToString(e.Code)))
+	}
+	return deleted, nil
+}
+
+func (r *S3Repository) abortUploads(ctx context.Context, bucket string) (int, error) {
+	aborted := 0
+	paginator := s3.NewListMultipartUploadsPaginator(r.client, &s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return aborted, err
+		}
+		for _, u := range page.Uploads {
+			_, err := r.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(bucket),
+				Key:      u.Key,
+				UploadId: u.UploadId,
+			})
+			if err != nil {
+				return aborted, err
+			}
+			aborted++
+		}
+	}
+	return aborted, nil
+}
+
+func versionIdentifiers(page *s3.ListObjectVersionsOutput) []types.ObjectIdentifier {
+	objects := make([]types.ObjectIdentifier, 0, len(page.Versions)+len(page.DeleteMarkers))
+	for _, v := range page.Versions {
+		objects = append(objects, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+	}
+	for _, m := range page.DeleteMarkers {
+		objects = append(objects, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+	}
+	return objects
+}
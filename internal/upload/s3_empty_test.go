@@ -0,0 +1,52 @@
+package upload
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Repository_DeleteAllRemovesVersionsAndUploads(t *testing.T) {
+	fake := newFakeS3()
+	fake.uploads = []string{"big.iso", "backup.tar"}
+	fake.versionPages = [][]string{{"a", "a", "b"}, {"c", "c"}, {"d", "e", "f", "f"}}
+	repo := newTestS3Repository(t, fake, MultipartConfig{})
+
+	var (
+		mu      sync.Mutex
+		reports []EmptyBucketReport
+	)
+	report, err := repo.DeleteAll(context.Background(), "my-bucket", func(r EmptyBucketReport) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.UploadsAborted)
+	assert.Equal(t, 9, report.ObjectsDeleted, "versions and delete markers are both removed")
+	assert.Equal(t, 2, fake.called("AbortMultipartUpload"))
+	assert.Equal(t, 3, fake.called("ListObjectVersions"))
+	assert.Equal(t, 3, fake.called("DeleteObjects"), "one batch per version page")
+
+	require.Len(t, reports, 3)
+	for i := 1; i < len(reports); i++ {
+		assert.Greater(t, reports[i].ObjectsDeleted, reports[i-1].ObjectsDeleted)
+	}
+	assert.Equal(t, 9, reports[2].ObjectsDeleted)
+}
+
+func TestS3Repository_DeleteAllReportsFailedKeys(t *testing.T) {
+	fake := newFakeS3()
+	fake.versionPages = [][]string{{"a", "b", "c"}}
+	fake.deleteErrors = 1
+	repo := newTestS3Repository(t, fake, MultipartConfig{})
+
+	report, err := repo.DeleteAll(context.Background(), "my-bucket", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 objects could not be deleted, first a: Access Denied")
+	assert.Equal(t, 2, report.ObjectsDeleted)
+}
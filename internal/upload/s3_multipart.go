@@ -0,0 +1,315 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	minPartSize      = 5 * 1024 * 1024
+	maxUploadParts   = 10000
+	abortTimeout     = 30 * time.Second
+	partRetryBackoff = 200 * time.Millisecond
+)
+
+type MultipartConfig struct {
+	Threshold   int64
+	PartSize    int64
+	Concurrency int
+	MaxRetries  int
+}
+
+func (c MultipartConfig) normalized() MultipartConfig {
+	if c.PartSize < minPartSize {
+		c.PartSize = minPartSize
+	}
+	if c.Concurrency < 1 {
+		c.Concurrency = 1
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	return c
+}
+
+func (c MultipartConfig) partSizeFor(size int64) int64 {
+	partSize := c.PartSize
+	if size > partSize*maxUploadParts {
+		partSize = (size + maxUploadParts - 1) / maxUploadParts
+	}
+	return partSize
+}
+
+func (r *S3Repository) uploadMultipart(ctx context.Context, bucket string, file *File) error {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(file.Name),
+		Metadata: file.Metadata,
+	}
+	if file.ContentType != "" {
+		input.ContentType = aws.String(file.ContentType)
+	}
+	if len(file.Tags) > 0 {
+		input.Tagging = aws.String(encodeTagging(file.Tags))
+	}
+
+	out, err := r.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return err
+	}
+	uploadID := aws.ToString(out.UploadId)
+
+	parts, err := r.uploadParts(ctx, bucket, file.Name, uploadID, file.Content, r.multipart.partSizeFor(file.Size))
+	if err != nil {
+		r.abortMultipart(ctx, bucket, file.Name, uploadID)
+		return err
+	}
+
+	_, err = r.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(file.Name),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		r.abortMultipart(ctx, bucket, file.Name, uploadID)
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+func (r *S3Repository) uploadParts(ctx context.Context, bucket, key, uploadID string, body io.Reader, partSize int64) ([]types.CompletedPart, error) {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(r.multipart.Concurrency)
+
+	var (
+		mu    sync.Mutex
+		parts []types.CompletedPart
+	)
+
+	for partNumber := int32(1); gctx.Err() == nil; partNumber++ {
+		if partNumber > maxUploadParts {
+			_ = g.Wait()
+			return nil, fmt.Errorf("failed to upload: object exceeds %d parts", maxUploadParts)
+		}
+
+		buf := make([]byte, partSize)
+		n, readErr := io.ReadFull(body, buf)
+		if readErr == io.EOF && partNumber > 1 {
+			break
+		}
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			_ = g.Wait()
+			return nil, fmt.Errorf("failed to read part %d: %w", partNumber, readErr)
+		}
+
+		data, number := buf[:n], partNumber
+		g.Go(func() error {
+			etag, err := r.uploadPartWithRetry(gctx, bucket, key, uploadID, number, data)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			parts = append(parts, types.CompletedPart{ETag: etag, PartNumber: aws.Int32(number)})
+			mu.Unlock()
+			return nil
+		})
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return aws.ToInt32(parts[i].PartNumber) < aws.ToInt32(parts[j].PartNumber)
+	})
+	return parts, nil
+}
+
+func (r *S3Repository) uploadPartWithRetry(ctx context.Context, bucket, key, uploadID string, partNumber int32, data []byte) (*string, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= r.multipart.MaxRetries; attempt++ {
+		if attempt > 0 {
+			slog.Warn("retrying multipart part upload", "key", key, "part", partNumber, "attempt", attempt, "error", lastErr)
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(partRetryBackoff << (attempt - 1)):
+			}
+		}
+
+		out, err := r.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			UploadId:      aws.String(uploadID),
+			PartNumber:    aws.Int32(partNumber),
+			Body:          bytes.NewReader(data),
+			ContentLength: aws.Int64(int64(len(data))),
+		})
+		if err == nil {
+			return out.ETag, nil
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("failed to upload part %d after %d attempts: %w", partNumber, r.multipart.MaxRetries+1, lastErr)
+}
+
+func (r *S3Repository) abortMultipart(ctx context.Context, bucket, key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+
+	if err := r.AbortMultipartUpload(ctx, bucket, key, uploadID); err != nil {
+		slog.Error("failed to abort multipart upload", "bucket", bucket, "key", key, "upload_id", uploadID, "error", err)
+	}
+}
+
+func (r *S3Repository) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		Metadata: metadata,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	out, err := r.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+func (r *S3Repository) PresignUploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, expiration time.Duration) (string, error) {
+	pc := s3.NewPresignClient(r.client)
+	req, err := pc.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, s3.WithPresignExpires(expiration))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (r *S3Repository) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (string, error) {
+	out, err := r.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return "", mapMultipartError(err)
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+func (r *S3Repository) ListParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error) {
+	paginator := s3.NewListPartsPaginator(r.client, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	var parts []UploadedPart
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, mapMultipartError(err)
+		}
+		for _, p := range page.Parts {
+			parts = append(parts, UploadedPart{
+				PartNumber:   aws.ToInt32(p.PartNumber),
+				ETag:         aws.ToString(p.ETag),
+				Size:         aws.ToInt64(p.Size),
+				LastModified: aws.ToTime(p.LastModified),
+			})
+		}
+	}
+	return parts, nil
+}
+
+func (r *S3Repository) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error) {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(p.ETag),
+			PartNumber: aws.Int32(p.PartNumber),
+		})
+	}
+
+	_, err := r.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return "", mapMultipartError(err)
+	}
+	return r.objectURL(bucket, key), nil
+}
+
+func (r *S3Repository) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	_, err := r.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return mapMultipartError(err)
+}
+
+func mapMultipartError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var noSuchUpload *types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) {
+		return ErrUploadNotFound
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchUpload":
+			return ErrUploadNotFound
+		case "InvalidPart", "InvalidPartOrder":
+			return fmt.Errorf("%w: %s", ErrInvalidPart, apiErr.ErrorMessage())
+		}
+	}
+	return mapS3Error(err)
+}
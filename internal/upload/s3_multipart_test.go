@@ -0,0 +1,87 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartFile is three parts at the minimum part size, the last one short.
+func multipartFile(t *testing.T) (*File, []byte) {
+	t.Helper()
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*minPartSize+minPartSize/2)/16)
+	return &File{
+		Name:        "backup.tar",
+		Content:     readSeekCloser{strings.NewReader(string(data))},
+		Size:        int64(len(data)),
+		ContentType: "application/x-tar",
+		Metadata:    map[string]string{"original-name": "backup.tar"},
+	}, data
+}
+
+func TestS3Repository_MultipartUploadRetriesFailedParts(t *testing.T) {
+	fake := newFakeS3()
+	fake.failPart = func(part int32, attempt int) bool {
+		return part == 2 && attempt == 1
+	}
+	repo := newTestS3Repository(t, fake, MultipartConfig{Threshold: minPartSize, Concurrency: 3, MaxRetries: 2})
+
+	file, data := multipartFile(t)
+	_, err := repo.Upload(context.Background(), "my-bucket", file)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, fake.attempts[2], "the failed part is retried")
+	assert.Equal(t, []int32{1, 2, 3}, fake.completed, "parts are completed in order")
+	assert.Zero(t, fake.called("AbortMultipartUpload"))
+	assert.Equal(t, "backup.tar", fake.created.Get("X-Amz-Meta-Original-Name"))
+
+	var joined []byte
+	for part := int32(1); part <= 3; part++ {
+		joined = append(joined, fake.parts[part]...)
+	}
+	assert.Equal(t, data, joined)
+}
+
+func TestS3Repository_MultipartUploadAbortsWhenRetriesRunOut(t *testing.T) {
+	fake := newFakeS3()
+	fake.failPart = func(part int32, _ int) bool {
+		return part == 3
+	}
+	repo := newTestS3Repository(t, fake, MultipartConfig{Threshold: minPartSize, Concurrency: 1, MaxRetries: 1})
+
+	file, _ := multipartFile(t)
+	_, err := repo.Upload(context.Background(), "my-bucket", file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to upload part 3 after 2 attempts")
+
+	assert.Equal(t, 2, fake.attempts[3])
+	assert.Equal(t, 1, fake.called("AbortMultipartUpload"))
+	assert.Zero(t, fake.called("CompleteMultipartUpload"))
+}
+
+func TestS3Repository_MultipartUploadStopsOnCancel(t *testing.T) {
+	fake := newFakeS3()
+	fake.partStarted = make(chan int32, 8)
+	repo := newTestS3Repository(t, fake, MultipartConfig{Threshold: minPartSize, Concurrency: 1, MaxRetries: 3})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		file, _ := multipartFile(t)
+		_, err := repo.Upload(ctx, "my-bucket", file)
+		done <- err
+	}()
+
+	<-fake.partStarted
+	cancel()
+	err := <-done
+	require.ErrorIs(t, err, context.Canceled)
+
+	assert.Equal(t, 1, fake.called("UploadPart"), "cancelled parts are not retried and no new parts start")
+	assert.Equal(t, 1, fake.called("AbortMultipartUpload"), "the upload is aborted even though ctx is done")
+	assert.Zero(t, fake.called("CompleteMultipartUpload"))
+}
@@ -2,59 +2,87 @@ package upload
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"net/http"
+	"net/url"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"golang.org/x/sync/errgroup"
 )
 
+const describeConcurrency = 8
+
 type S3Repository struct {
-	client *s3.Client
-	region string
+	client    *s3.Client
+	region    string
+	multipart MultipartConfig
 }
 
-func NewS3Repository(client *s3.Client, region string) Repository {
+func NewS3Repository(client *s3.Client, region string, multipart MultipartConfig) Repository {
 	return &S3Repository{
-		client: client,
-		region: region,
+		client:    client,
+		region:    region,
+		multipart: multipart.normalized(),
 	}
 }
 
 func (r *S3Repository) Upload(ctx context.Context, bucket string, file *File) (string, error) {
+	if r.multipart.Threshold > 0 && file.Size >= r.multipart.Threshold {
+		if err := r.uploadMultipart(ctx, bucket, file); err != nil {
+			return "", fmt.Errorf("failed to upload: %w", mapS3Error(err))
+		}
+		return r.objectURL(bucket, file.Name), nil
+	}
+
 	input := &s3.PutObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(file.Name),
-		Body:   file.Content,
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(file.Name),
+		Body:     file.Content,
+		Metadata: file.Metadata,
+	}
+	if file.ContentType != "" {
+		input.ContentType = aws.String(file.ContentType)
+	}
+	if len(file.Tags) > 0 {
+		input.Tagging = aws.String(encodeTagging(file.Tags))
 	}
 
 	_, err := r.client.PutObject(ctx, input)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload: %w", err)
+		return "", fmt.Errorf("failed to upload: %w", mapS3Error(err))
 	}
 
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, r.region, file.Name), nil
+	return r.objectURL(bucket, file.Name), nil
 }
 
-func (r *S3Repository) List(ctx context.Context, bucket, prefix, token string, limit int32) (*PaginatedFiles, error) {
+func (r *S3Repository) List(ctx context.Context, bucket string, opts ListOptions) (*PaginatedFiles, error) {
 	input := &s3.ListObjectsV2Input{
 		Bucket:            aws.String(bucket),
-		Prefix:            aws.String(prefix),
-		ContinuationToken: aws.String(token),
-		MaxKeys:           aws.Int32(limit),
+		Prefix:            aws.String(opts.Prefix),
+		ContinuationToken: aws.String(opts.Token),
+		MaxKeys:           aws.Int32(opts.Limit),
 	}
 
-	if token == "" {
+	if opts.Token == "" {
 		input.ContinuationToken = nil
 	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
 
 	output, err := r.client.ListObjectsV2(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		return nil, fmt.Errorf("failed to list objects: %w", mapS3Error(err))
 	}
 
 	var files []FileSummary
@@ -68,16 +96,50 @@ func (r *S3Repository) List(ctx context.Context, bucket, prefix, token string, l
 			StorageClass:      string(obj.StorageClass),
 			LastModified:      aws.ToTime(obj.LastModified),
 			Extension:         strings.ToLower(filepath.Ext(key)),
-			URL:               fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, r.region, key),
+			URL:               r.objectURL(bucket, key),
 		})
 	}
 
+	if opts.IncludeMetadata {
+		if err := r.describeObjects(ctx, bucket, files); err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+	}
+
+	var folders []Folder
+	for _, p := range output.CommonPrefixes {
+		folders = append(folders, newFolder(aws.ToString(p.Prefix), opts))
+	}
+
 	next := ""
 	if output.NextContinuationToken != nil {
 		next = *output.NextContinuationToken
 	}
 
-	return &PaginatedFiles{Files: files, NextToken: next}, nil
+	return &PaginatedFiles{Files: files, Folders: folders, NextToken: next}, nil
+}
+
+func (r *S3Repository) describeObjects(ctx context.Context, bucket string, files []FileSummary) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(describeConcurrency)
+
+	for i := range files {
+		g.Go(func() error {
+			info, err := r.Stat(ctx, bucket, files[i].Key)
+			if err != nil {
+				return err
+			}
+			tags, err := r.objectTags(ctx, bucket, files[i].Key, "")
+			if err != nil {
+				return err
+			}
+			files[i].ContentType = info.ContentType
+			files[i].Metadata = info.Metadata
+			files[i].Tags = tags
+			return nil
+		})
+	}
+	return g.Wait()
 }
 
 func (r *S3Repository) Delete(ctx context.Context, bucket, key string) error {
@@ -85,49 +147,401 @@ func (r *S3Repository) Delete(ctx context.Context, bucket, key string) error {
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
-	return err
+	return mapS3Error(err)
+}
+
+func (r *S3Repository) DeleteMany(ctx context.Context, bucket string, keys []string) ([]DeleteResult, error) {
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+
+	output, err := r.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &types.Delete{Objects: objects},
+	})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	failed := make(map[string]string, len(output.Errors))
+	for _, e := range output.Errors {
+		failed[aws.ToString(e.Key)] = firstNonEmpty(aws.ToString(e.Message), aws.ToString(e.Code))
+	}
+
+	results := make([]DeleteResult, 0, len(keys))
+	for _, key := range keys {
+		msg, isFailed := failed[key]
+		results = append(results, DeleteResult{Key: key, Deleted: !isFailed, Error: msg})
+	}
+	return results, nil
+}
+
+func (r *S3Repository) DeleteVersion(ctx context.Context, bucket, key, versionID string) error {
+	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	return mapS3Error(err)
+}
+
+func (r *S3Repository) ListVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error) {
+	marker, err := decodeListToken(opts.Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(opts.Prefix),
+		MaxKeys: aws.Int32(opts.Limit),
+	}
+	if marker != "" {
+		key, versionID, _ := strings.Cut(marker, "\x00")
+		input.KeyMarker = aws.String(key)
+		input.VersionIdMarker = aws.String(versionID)
+	}
+
+	output, err := r.client.ListObjectVersions(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", mapS3Error(err))
+	}
+
+	var versions []ObjectVersion
+	for _, v := range output.Versions {
+		versions = append(versions, ObjectVersion{
+			Key:          aws.ToString(v.Key),
+			VersionID:    aws.ToString(v.VersionId),
+			IsLatest:     aws.ToBool(v.IsLatest),
+			Size:         aws.ToInt64(v.Size),
+			ETag:         strings.Trim(aws.ToString(v.ETag), `"`),
+			LastModified: aws.ToTime(v.LastModified),
+		})
+	}
+	for _, m := range output.DeleteMarkers {
+		versions = append(versions, ObjectVersion{
+			Key:          aws.ToString(m.Key),
+			VersionID:    aws.ToString(m.VersionId),
+			IsLatest:     aws.ToBool(m.IsLatest),
+			DeleteMarker: true,
+			LastModified: aws.ToTime(m.LastModified),
+		})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+
+	next := ""
+	if aws.ToBool(output.IsTruncated) {
+		next = encodeListToken(aws.ToString(output.NextKeyMarker) + "\x00" + aws.ToString(output.NextVersionIdMarker))
+	}
+
+	return &PaginatedVersions{Versions: versions, NextToken: next}, nil
+}
+
+func (r *S3Repository) RestoreVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	src, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		if err = mapS3Error(err); errors.Is(err, ErrFileNotFound) {
+			err = ErrVersionNotFound
+		}
+		return "", fmt.Errorf("failed to restore version: %w", err)
+	}
+
+	source := copySource(bucket, key) + "?versionId=" + url.QueryEscape(versionID)
+	if aws.ToInt64(src.ContentLength) > maxCopyObjectSize {
+		restored, err := r.copyMultipart(ctx, source, bucket, key, versionID, bucket, key, src, CopyOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to restore version: %w", mapS3Error(err))
+		}
+		return restored, nil
+	}
+
+	output, err := r.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(source),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to restore version: %w", mapS3Error(err))
+	}
+	return aws.ToString(output.VersionId), nil
+}
+
+func (r *S3Repository) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	output, err := r.client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return "", mapS3Error(err)
+	}
+	if output.Status == "" {
+		return VersioningDisabled, nil
+	}
+	return string(output.Status), nil
+}
+
+func (r *S3Repository) SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error {
+	status := types.BucketVersioningStatusSuspended
+	if enabled {
+		status = types.BucketVersioningStatusEnabled
+	}
+
+	_, err := r.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: status},
+	})
+	return mapS3Error(err)
+}
+
+func (r *S3Repository) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	return r.statVersion(ctx, bucket, key, "")
+}
+
+func (r *S3Repository) statVersion(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	output, err := r.client.HeadObject(ctx, input)
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	storageClass := string(output.StorageClass)
+	if storageClass == "" {
+		storageClass = string(types.StorageClassStandard)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         strings.Trim(aws.ToString(output.ETag), `"`),
+		VersionID:    aws.ToString(output.VersionId),
+		StorageClass: storageClass,
+		Metadata:     output.Metadata,
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
 }
 
-func (r *S3Repository) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
-	output, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+func (r *S3Repository) GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	return r.objectTags(ctx, bucket, key, "")
+}
+
+func (r *S3Repository) PutObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error {
+	tagSet := make([]types.Tag, 0, len(tags))
+	for k, v := range tags {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	_, err := r.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	return mapS3Error(err)
+}
+
+func (r *S3Repository) DeleteObjectTags(ctx context.Context, bucket, key string) error {
+	_, err := r.client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
+	return mapS3Error(err)
+}
+
+// objectTags reads the tags of the current version when versionID is empty.
+func (r *S3Repository) objectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	out, err := r.client.GetObjectTagging(ctx, input)
 	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	if len(out.TagSet) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(out.TagSet))
+	for _, tag := range out.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+func (r *S3Repository) Download(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
+	}
+	if opts.Range != "" {
+		input.Range = aws.String(opts.Range)
+	}
+	if opts.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(opts.IfNoneMatch)
+	}
+	if !opts.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(opts.IfModifiedSince)
+	}
+
+	output, err := r.client.GetObject(ctx, input)
+	if err != nil {
+		err = mapS3Error(err)
+		if errors.Is(err, ErrNotModified) || errors.Is(err, ErrInvalidRange) {
+			// Neither response carries the object's validators or size.
+			if info, statErr := r.statVersion(ctx, bucket, key, opts.VersionID); statErr == nil {
+				return nil, &DownloadConditionError{Info: *info, err: err}
+			}
+		}
 		return nil, err
 	}
-	return output.Body, nil
+
+	size := aws.ToInt64(output.ContentLength)
+	if output.ContentRange != nil {
+		if _, total, ok := strings.Cut(*output.ContentRange, "/"); ok {
+			size, _ = strconv.ParseInt(total, 10, 64)
+		}
+	}
+
+	return &Object{
+		Body: output.Body,
+		Info: ObjectInfo{
+			Key:          key,
+			Size:         size,
+			ContentType:  aws.ToString(output.ContentType),
+			ETag:         strings.Trim(aws.ToString(output.ETag), `"`),
+			VersionID:    aws.ToString(output.VersionId),
+			Metadata:     output.Metadata,
+			LastModified: aws.ToTime(output.LastModified),
+		},
+		ContentLength: aws.ToInt64(output.ContentLength),
+		ContentRange:  aws.ToString(output.ContentRange),
+	}, nil
 }
 
-func (r *S3Repository) GetPresignURL(ctx context.Context, bucket, key string, exp time.Duration) (string, error) {
-	pc := s3.NewPresignClient(r.client)
-	req, err := pc.PresignGetObject(ctx, &s3.GetObjectInput{
+func (r *S3Repository) GetPresignURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error) {
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
-	}, s3.WithPresignExpires(exp))
+	}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
+	}
+	if opts.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	if opts.ContentType != "" {
+		input.ResponseContentType = aws.String(opts.ContentType)
+	}
+
+	pc := s3.NewPresignClient(r.client)
+	req, err := pc.PresignGetObject(ctx, input, s3.WithPresignExpires(opts.Expiration))
 	if err != nil {
 		return "", err
 	}
 	return req.URL, nil
 }
 
+func (r *S3Repository) PresignUpload(ctx context.Context, bucket, key string, opts UploadPresignOptions) (*PresignedUpload, error) {
+	pc := s3.NewPresignClient(r.client)
+	res := &PresignedUpload{
+		Method:    opts.Method,
+		Bucket:    bucket,
+		Key:       key,
+		ExpiresAt: time.Now().Add(opts.Expiration).UTC(),
+	}
+
+	switch opts.Method {
+	case http.MethodPut:
+		if opts.ContentLength <= 0 {
+			return nil, ErrUploadSizeRequired
+		}
+		input := &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			ContentType:   aws.String(opts.ContentType),
+			ContentLength: aws.Int64(opts.ContentLength),
+		}
+
+		req, err := pc.PresignPutObject(ctx, input, s3.WithPresignExpires(opts.Expiration))
+		if err != nil {
+			return nil, err
+		}
+
+		res.URL = req.URL
+		res.Headers = make(map[string]string)
+		for name, values := range req.SignedHeader {
+			if !strings.EqualFold(name, "Host") && len(values) > 0 {
+				res.Headers[name] = values[0]
+			}
+		}
+
+	case http.MethodPost:
+		req, err := pc.PresignPostObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		}, func(o *s3.PresignPostOptions) {
+			o.Expires = opts.Expiration
+			o.Conditions = []interface{}{
+				map[string]string{"Content-Type": opts.ContentType},
+				[]interface{}{"content-length-range", 1, opts.MaxSize},
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		res.URL = req.URL
+		res.Fields = req.Values
+		res.Fields["Content-Type"] = opts.ContentType
+
+	default:
+		return nil, ErrInvalidPresignMethod
+	}
+
+	return res, nil
+}
+
 func (r *S3Repository) CheckBucketExists(ctx context.Context, bucket string) (bool, error) {
 	_, err := r.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
-	if err != nil {
+	if err == nil {
+		return true, nil
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
 		return false, nil
 	}
-	return true, nil
+	return false, mapS3Error(err)
 }
 
 func (r *S3Repository) CreateBucket(ctx context.Context, bucket string) error {
 	_, err := r.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
-	return err
+	return mapS3Error(err)
 }
 
 func (r *S3Repository) ListBuckets(ctx context.Context) ([]BucketSummary, error) {
 	out, err := r.client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
-		return nil, err
+		return nil, mapS3Error(err)
 	}
 	var res []BucketSummary
 	for _, b := range out.Buckets {
@@ -138,40 +552,31 @@ func (r *S3Repository) ListBuckets(ctx context.Context) ([]BucketSummary, error)
 
 func (r *S3Repository) DeleteBucket(ctx context.Context, bucket string) error {
 	_, err := r.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)})
-	return err
+	return mapS3Error(err)
 }
 
-func (r *S3Repository) DeleteAll(ctx context.Context, bucket string) error {
-	out, err := r.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
-	if err != nil || len(out.Contents) == 0 {
-		return err
+func (r *S3Repository) GetStats(ctx context.Context, bucket, prefix string) (*BucketStats, error) {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
 	}
-	var objects []types.ObjectIdentifier
-	for _, obj := range out.Contents {
-		objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+
+	stats := newBucketStats(bucket, prefix)
+	paginator := s3.NewListObjectsV2Paginator(r.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute stats: %w", mapS3Error(err))
+		}
+		for _, obj := range page.Contents {
+			stats.add(StatsObject{Key: aws.ToString(obj.Key), SizeBytes: aws.ToInt64(obj.Size), LastModified: aws.ToTime(obj.LastModified)}, string(obj.StorageClass))
+		}
 	}
-	_, err = r.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
-		Bucket: aws.String(bucket),
-		Delete: &types.Delete{Objects: objects},
-	})
-	return err
+	return stats.summarize(), nil
 }
 
-func (r *S3Repository) GetStats(ctx context.Context, bucket string) (*BucketStats, error) {
-	out, err := r.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
-	if err != nil {
-		return nil, err
-	}
-	var totalSize int64
-	for _, obj := range out.Contents {
-		totalSize += aws.ToInt64(obj.Size)
-	}
-	return &BucketStats{
-		BucketName:         bucket,
-		TotalFiles:         int(len(out.Contents)),
-		TotalSizeBytes:     totalSize,
-		TotalSizeFormatted: formatBytes(totalSize),
-	}, nil
+func (r *S3Repository) objectURL(bucket, key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, r.region, key)
 }
 
 func formatBytes(b int64) string {
@@ -186,3 +591,55 @@ func formatBytes(b int64) string {
 	}
 	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
 }
+
+func mapS3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return ErrFileNotFound
+	}
+
+	var noSuchBucket *types.NoSuchBucket
+	if errors.As(err, &noSuchBucket) {
+		return ErrBucketNotFound
+	}
+
+	var alreadyExists *types.BucketAlreadyExists
+	var alreadyOwned *types.BucketAlreadyOwnedByYou
+	if errors.As(err, &alreadyExists) || errors.As(err, &alreadyOwned) {
+		return ErrBucketAlreadyExists
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchBucket":
+			return ErrBucketNotFound
+		case "NoSuchKey", "NotFound":
+			return ErrFileNotFound
+		case "NoSuchVersion":
+			return ErrVersionNotFound
+		case "InvalidRange":
+			return ErrInvalidRange
+		case "PreconditionFailed":
+			return ErrSourceChanged
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotModified:
+			return ErrNotModified
+		case http.StatusRequestedRangeNotSatisfiable:
+			return ErrInvalidRange
+		case http.StatusNotFound:
+			return ErrFileNotFound
+		}
+	}
+	return err
+}
@@ -0,0 +1,376 @@
+package upload
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestS3Repository points an S3Repository at handler, with path-style
+// addressing and SDK retries off so tests see every request.
+func newTestS3Repository(t *testing.T, handler http.Handler, multipart MultipartConfig) *S3Repository {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		Retryer:      aws.NopRetryer{},
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	})
+	return NewS3Repository(client, "us-east-1", multipart).(*S3Repository)
+}
+
+type fakeObject struct {
+	size        int64
+	etag        string
+	contentType string
+	metadata    map[string]string
+	tags        map[string]string
+}
+
+// fakeS3 answers the S3 calls the repository makes for multipart transfers,
+// copies and bucket cleanup, and records each call in order.
+type fakeS3 struct {
+	mu    sync.Mutex
+	calls []string
+
+	objects map[string]fakeObject
+	// versionIDs holds the versionId of every HeadObject and
+	// GetObjectTagging call, empty for the current version.
+	versionIDs []string
+	copySource string
+
+	// created holds the headers of the last CreateMultipartUpload.
+	created    http.Header
+	parts      map[int32][]byte
+	copyRanges map[int32]string
+	// copyIfMatch holds the X-Amz-Copy-Source-If-Match of every part copy.
+	copyIfMatch map[int32]string
+	// sourceETag, when set, is the source's ETag as UploadPartCopy sees it,
+	// as if it was overwritten after HeadObject. A mismatch answers 412.
+	sourceETag string
+	attempts   map[int32]int
+	completed  []int32
+	// failPart answers UploadPart and UploadPartCopy with a 500 when it
+	// returns true for the part and its 1-based attempt.
+	failPart func(part int32, attempt int) bool
+	// partStarted, when set, is sent every UploadPart, which then hangs until
+	// the client gives up.
+	partStarted chan int32
+
+	uploads      []string
+	versionPages [][]string
+	// deleteErrors is how many keys every DeleteObjects call fails.
+	deleteErrors int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects:     map[string]fakeObject{},
+		parts:       map[int32][]byte{},
+		copyRanges:  map[int32]string{},
+		copyIfMatch: map[int32]string{},
+		attempts:    map[int32]int{},
+	}
+}
+
+func (f *fakeS3) record(call string) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+}
+
+func (f *fakeS3) called(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	copySource := r.Header.Get("X-Amz-Copy-Source")
+	if copySource != "" {
+		f.mu.Lock()
+		f.copySource = copySource
+		f.mu.Unlock()
+	}
+	if r.Method == http.MethodHead || q.Has("tagging") {
+		f.mu.Lock()
+		f.versionIDs = append(f.versionIDs, q.Get("versionId"))
+		f.mu.Unlock()
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		f.record("HeadObject")
+		f.mu.Lock()
+		obj, ok := f.objects[key]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.size, 10))
+		w.Header().Set("Content-Type", obj.contentType)
+		if obj.etag != "" {
+			w.Header().Set("ETag", obj.etag)
+		}
+		for k, v := range obj.metadata {
+			w.Header().Set("X-Amz-Meta-"+k, v)
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet && q.Has("tagging"):
+		f.record("GetObjectTagging")
+		f.mu.Lock()
+		tags := f.objects[key].tags
+		f.mu.Unlock()
+		var b strings.Builder
+		for _, k := range sortedKeys(tags) {
+			fmt.Fprintf(&b, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", k, tags[k])
+		}
+		writeXML(w, "<Tagging><TagSet>"+b.String()+"</TagSet></Tagging>")
+
+	case r.Method == http.MethodGet && q.Has("versions"):
+		f.record("ListObjectVersions")
+		page, _ := strconv.Atoi(q.Get("key-marker"))
+		var b strings.Builder
+		if page < len(f.versionPages) {
+			for i, k := range f.versionPages[page] {
+				if i%2 == 0 {
+					fmt.Fprintf(&b, "<Version><Key>%s</Key><VersionId>v%d</VersionId></Version>", k, i)
+				} else {
+					fmt.Fprintf(&b, "<DeleteMarker><Key>%s</Key><VersionId>m%d</VersionId></DeleteMarker>", k, i)
+				}
+			}
+		}
+		if page+1 < len(f.versionPages) {
+			fmt.Fprintf(&b, "<IsTruncated>true</IsTruncated><NextKeyMarker>%d</NextKeyMarker><NextVersionIdMarker>x</NextVersionIdMarker>", page+1)
+		} else {
+			b.WriteString("<IsTruncated>false</IsTruncated>")
+		}
+		writeXML(w, "<ListVersionsResult>"+b.String()+"</ListVersionsResult>")
+
+	case r.Method == http.MethodGet && q.Has("uploads"):
+		f.record("ListMultipartUploads")
+		var b strings.Builder
+		for i, k := range f.uploads {
+			fmt.Fprintf(&b, "<Upload><Key>%s</Key><UploadId>upload-%d</UploadId></Upload>", k, i)
+		}
+		writeXML(w, "<ListMultipartUploadsResult><IsTruncated>false</IsTruncated>"+b.String()+"</ListMultipartUploadsResult>")
+
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.record("CreateMultipartUpload")
+		f.mu.Lock()
+		f.created = r.Header.Clone()
+		f.mu.Unlock()
+		writeXML(w, "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>")
+
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		part := int32(n)
+		data, _ := io.ReadAll(r.Body)
+
+		f.mu.Lock()
+		f.attempts[part]++
+		attempt := f.attempts[part]
+		fail := f.failPart != nil && f.failPart(part, attempt)
+		f.mu.Unlock()
+
+		if copySource != "" {
+			f.record("UploadPartCopy")
+		} else {
+			f.record("UploadPart")
+			if f.partStarted != nil {
+				f.partStarted <- part
+				<-r.Context().Done()
+				return
+			}
+		}
+		if fail {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		etag := fmt.Sprintf(`"etag-%d"`, part)
+		if copySource != "" {
+			ifMatch := r.Header.Get("X-Amz-Copy-Source-If-Match")
+			f.copyIfMatch[part] = ifMatch
+			if ifMatch != "" && f.sourceETag != "" && ifMatch != f.sourceETag {
+				writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+			f.copyRanges[part] = r.Header.Get("X-Amz-Copy-Source-Range")
+			writeXML(w, "<CopyPartResult><ETag>"+etag+"</ETag></CopyPartResult>")
+			return
+		}
+		f.parts[part] = data
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		f.record("CompleteMultipartUpload")
+		var body struct {
+			Parts []struct {
+				PartNumber int32
+			} `xml:"Part"`
+		}
+		_ = xml.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		for _, p := range body.Parts {
+			f.completed = append(f.completed, p.PartNumber)
+		}
+		f.mu.Unlock()
+		w.Header().Set("X-Amz-Version-Id", "completed")
+		writeXML(w, "<CompleteMultipartUploadResult><ETag>\"done\"</ETag></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		f.record("AbortMultipartUpload")
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPost && q.Has("delete"):
+		f.record("DeleteObjects")
+		var body struct {
+			Objects []struct {
+				Key string
+			} `xml:"Object"`
+		}
+		_ = xml.NewDecoder(r.Body).Decode(&body)
+		var b strings.Builder
+		for _, o := range body.Objects[:min(f.deleteErrors, len(body.Objects))] {
+			fmt.Fprintf(&b, "<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>", o.Key)
+		}
+		writeXML(w, "<DeleteResult>"+b.String()+"</DeleteResult>")
+
+	case r.Method == http.MethodPut && copySource != "":
+		f.record("CopyObject")
+		w.Header().Set("X-Amz-Version-Id", "copied")
+		writeXML(w, "<CopyObjectResult><ETag>\"copy\"</ETag></CopyObjectResult>")
+
+	default:
+		f.record(r.Method + " " + r.URL.String())
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func writeXML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>`+body)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestS3Repository_PresignPutSignsContentLength(t *testing.T) {
+	repo := newTestS3Repository(t, http.NotFoundHandler(), MultipartConfig{})
+
+	res, err := repo.PresignUpload(context.Background(), "my-bucket", "a.png", UploadPresignOptions{
+		Method:        http.MethodPut,
+		ContentType:   "image/png",
+		ContentLength: 1234,
+		Expiration:    uploadURLExpiration,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1234", res.Headers["Content-Length"])
+
+	u, err := url.Parse(res.URL)
+	require.NoError(t, err)
+	assert.Contains(t, u.Query().Get("X-Amz-SignedHeaders"), "content-length")
+
+	_, err = repo.PresignUpload(context.Background(), "my-bucket", "a.png", UploadPresignOptions{
+		Method:      http.MethodPut,
+		ContentType: "image/png",
+		Expiration:  uploadURLExpiration,
+	})
+	assert.ErrorIs(t, err, ErrUploadSizeRequired)
+}
+
+func TestS3Repository_StatSkipsTags(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["a.png"] = fakeObject{size: 10, contentType: "image/png", tags: map[string]string{"team": "web"}}
+	repo := newTestS3Repository(t, fake, MultipartConfig{})
+	service := NewService(repo)
+
+	info, err := repo.Stat(context.Background(), "my-bucket", "a.png")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size)
+	assert.Zero(t, fake.called("GetObjectTagging"), "Stat is a single HeadObject")
+
+	info, err = service.GetFileMetadata(context.Background(), "my-bucket", "a.png")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "web"}, info.Tags)
+	assert.Equal(t, 1, fake.called("GetObjectTagging"))
+}
+
+func TestS3Repository_DownloadConditionCarriesObjectState(t *testing.T) {
+	var versionIDs []string
+	repo := newTestS3Repository(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			versionIDs = append(versionIDs, r.URL.Query().Get("versionId"))
+			w.Header().Set("Content-Length", "15")
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+	}), MultipartConfig{})
+
+	_, err := repo.Download(context.Background(), "my-bucket", "a.pdf", DownloadOptions{VersionID: "v1", IfNoneMatch: `"abc"`})
+	var condErr *DownloadConditionError
+	require.ErrorAs(t, err, &condErr)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, "abc", condErr.Info.ETag)
+	assert.False(t, condErr.Info.LastModified.IsZero())
+
+	_, err = repo.Download(context.Background(), "my-bucket", "a.pdf", DownloadOptions{Range: "bytes=100-"})
+	require.ErrorAs(t, err, &condErr)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	assert.Equal(t, int64(15), condErr.Info.Size)
+
+	assert.Equal(t, []string{"v1", ""}, versionIDs, "the requested version is described")
+}
@@ -0,0 +1,83 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/JoaoOliveira889/s3-api/internal/scan"
+)
+
+// WithScanner has every upload scanned for malware before it is stored.
+// Without it uploads are not scanned.
+func WithScanner(sc scan.Scanner) ServiceOption {
+	return func(s *uploadService) {
+		s.scanner = sc
+	}
+}
+
+// WithScanFailOpen decides what happens when the scanner cannot give a
+// verdict. By default uploads are refused with ErrScanUnavailable; fail open
+// stores them unscanned and logs a warning.
+func WithScanFailOpen(open bool) ServiceOption {
+	return func(s *uploadService) {
+		s.scanFailOpen = open
+	}
+}
+
+// WithScanMaxSize is the largest file sent to the scanner and should match
+// clamd's StreamMaxLength. Larger files get no verdict: they are refused with
+// ErrTooLargeToScan, or stored unscanned when failing open. Zero sends every
+// file to the scanner.
+func WithScanMaxSize(n int64) ServiceOption {
+	return func(s *uploadService) {
+		s.scanMaxSize = n
+	}
+}
+
+// scanContent runs the configured scanner over r, which holds size bytes.
+// Infected files are rejected with ErrInfectedFile and recorded in the audit
+// log.
+func (s *uploadService) scanContent(ctx context.Context, bucket, key, filename string, size int64, r io.Reader) error {
+	if s.scanner == nil {
+		return nil
+	}
+
+	var (
+		res scan.Result
+		err error
+	)
+	if s.scanMaxSize > 0 && size > s.scanMaxSize {
+		err = fmt.Errorf("%d bytes is over the %d byte scan limit: %w", size, s.scanMaxSize, scan.ErrTooLarge)
+	} else {
+		res, err = s.scanner.Scan(ctx, r)
+	}
+	if err != nil {
+		if s.scanFailOpen {
+			slog.Warn("malware scan failed, storing file unscanned", "error", err, "scanner", s.scanner.Name(), "bucket", bucket, "key", key, "size", size)
+			return nil
+		}
+		slog.Error("malware scan failed", "error", err, "scanner", s.scanner.Name(), "bucket", bucket, "key", key, "size", size)
+		if errors.Is(err, scan.ErrTooLarge) {
+			return fmt.Errorf("%w: %v", ErrTooLargeToScan, err)
+		}
+		return fmt.Errorf("%w: %v", ErrScanUnavailable, err)
+	}
+	if !res.Infected {
+		return nil
+	}
+
+	slog.Warn("infected upload rejected",
+		"audit", true,
+		"event", "malware_detected",
+		"scanner", s.scanner.Name(),
+		"signature", res.Signature,
+		"bucket", bucket,
+		"key", key,
+		"filename", filename,
+		"user", userFromContext(ctx),
+	)
+	return fmt.Errorf("%w: %s", ErrInfectedFile, res.Signature)
+}
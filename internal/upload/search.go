@@ -0,0 +1,178 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/JoaoOliveira889/s3-api/internal/search"
+)
+
+const reindexPageSize = 100
+
+// WithSearchIndex keeps ix up to date with every write made through the
+// service and enables SearchFiles and Reindex.
+func WithSearchIndex(ix *search.Index) ServiceOption {
+	return func(s *uploadService) {
+		s.index = ix
+	}
+}
+
+func (s *uploadService) SearchFiles(ctx context.Context, q search.Query) (*search.Results, error) {
+	if s.index == nil {
+		return nil, ErrSearchDisabled
+	}
+	if q.Bucket != "" {
+		if err := s.validateBucketName(q.Bucket); err != nil {
+			return nil, err
+		}
+	}
+	return s.index.Search(q), nil
+}
+
+// Reindex rebuilds the search entries of bucket from a full listing, or of
+// every bucket when bucket is empty. Objects written around the service, such
+// as presigned uploads, only become searchable this way.
+func (s *uploadService) Reindex(ctx context.Context, bucket string, progress BatchProgressFunc) (*ReindexReport, error) {
+	if s.index == nil {
+		return nil, ErrSearchDisabled
+	}
+
+	buckets := []string{bucket}
+	if bucket == "" {
+		all, err := s.repo.ListBuckets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		buckets = buckets[:0]
+		for _, b := range all {
+			buckets = append(buckets, b.Name)
+		}
+
+		for _, stale := range s.index.Buckets() {
+			if !slices.Contains(buckets, stale) {
+				s.unindexBucket(stale)
+			}
+		}
+	} else if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+
+	report := &ReindexReport{Buckets: buckets}
+	tracker := newProgressTracker(0, progress)
+	for _, b := range buckets {
+		var docs []search.Document
+		token := ""
+		for {
+			page, err := s.repo.List(ctx, b, ListOptions{Token: token, Limit: reindexPageSize, IncludeMetadata: true})
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range page.Files {
+				docs = append(docs, fileDocument(b, f))
+			}
+			tracker.add(len(page.Files), 0)
+
+			if page.NextToken == "" {
+				break
+			}
+			token = page.NextToken
+		}
+
+		if err := s.index.ReplaceBucket(b, docs); err != nil {
+			return nil, err
+		}
+		report.Indexed += len(docs)
+	}
+
+	slog.Info("search index rebuilt", "buckets", len(buckets), "indexed", report.Indexed)
+	return report, nil
+}
+
+// indexObject refreshes the search entry of key from storage, dropping it when
+// the object no longer exists.
+func (s *uploadService) indexObject(ctx context.Context, bucket, key string) {
+	if s.index == nil {
+		return
+	}
+
+	info, err := s.repo.Stat(ctx, bucket, key)
+	if errors.Is(err, ErrFileNotFound) {
+		s.unindex(bucket, key)
+		return
+	}
+	if err == nil {
+		info.Tags, err = s.repo.GetObjectTags(ctx, bucket, key)
+	}
+	if err != nil {
+		slog.Warn("failed to index object", "error", err, "bucket", bucket, "key", key)
+		return
+	}
+	name, meta := splitOriginalName(info.Metadata)
+	s.indexDocuments(search.Document{
+		Bucket:       bucket,
+		Key:          key,
+		Name:         name,
+		ContentType:  info.ContentType,
+		Size:         info.Size,
+		Tags:         info.Tags,
+		Metadata:     meta,
+		LastModified: info.LastModified,
+	})
+}
+
+func (s *uploadService) indexDocuments(docs ...search.Document) {
+	if s.index == nil || len(docs) == 0 {
+		return
+	}
+	if err := s.index.Put(docs...); err != nil {
+		slog.Warn("failed to update search index", "error", err, "bucket", docs[0].Bucket)
+	}
+}
+
+func (s *uploadService) unindex(bucket string, keys ...string) {
+	if s.index == nil || len(keys) == 0 {
+		return
+	}
+	if err := s.index.Delete(bucket, keys...); err != nil {
+		slog.Warn("failed to update search index", "error", err, "bucket", bucket)
+	}
+}
+
+func (s *uploadService) unindexBucket(bucket string) {
+	if s.index == nil {
+		return
+	}
+	if err := s.index.ReplaceBucket(bucket, nil); err != nil {
+		slog.Warn("failed to update search index", "error", err, "bucket", bucket)
+	}
+}
+
+// copyIndexed indexes dstBucket/key with the entry already known for
+// srcBucket/key, saving a Stat per object on bulk copies.
+func (s *uploadService) copyIndexed(srcBucket, dstBucket, key string) {
+	if s.index == nil {
+		return
+	}
+	if doc, ok := s.index.Get(srcBucket, key); ok {
+		doc.Bucket = dstBucket
+		doc.LastModified = time.Now().UTC()
+		s.indexDocuments(doc)
+	}
+}
+
+func fileDocument(bucket string, f FileSummary) search.Document {
+	name, meta := splitOriginalName(f.Metadata)
+	return search.Document{
+		Bucket:       bucket,
+		Key:          f.Key,
+		Name:         name,
+		ContentType:  f.ContentType,
+		Size:         f.Size,
+		Tags:         f.Tags,
+		Metadata:     meta,
+		LastModified: f.LastModified,
+	}
+}
@@ -2,15 +2,21 @@ package upload
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
-	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+	"github.com/JoaoOliveira889/s3-api/internal/scan"
+	"github.com/JoaoOliveira889/s3-api/internal/search"
 	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
 )
@@ -18,62 +24,186 @@ import (
 type Service interface {
 	UploadFile(ctx context.Context, bucket string, file *File) (string, error)
 	UploadMultipleFiles(ctx context.Context, bucket string, files []*File) ([]string, error)
-	GetDownloadURL(ctx context.Context, bucket, key string) (string, error)
-	DownloadFile(ctx context.Context, bucket, key string) (io.ReadCloser, error)
-	ListFiles(ctx context.Context, bucket, ext, token string, limit int) (*PaginatedFiles, error)
+	GetDownloadURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error)
+	DownloadFile(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error)
+	GetFileMetadata(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+	GetFileTags(ctx context.Context, bucket, key string) (map[string]string, error)
+	ReplaceFileTags(ctx context.Context, bucket, key string, tags map[string]string) error
+	DeleteFileTags(ctx context.Context, bucket, key string) error
+	ListFiles(ctx context.Context, bucket string, q ListQuery) (*PaginatedFiles, error)
 	DeleteFile(ctx context.Context, bucket string, key string) error
-	GetBucketStats(ctx context.Context, bucket string) (*BucketStats, error)
+	DeleteFiles(ctx context.Context, bucket string, req BatchDeleteRequest, progress BatchProgressFunc) (*BatchDeleteReport, error)
+	CopyFile(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error)
+	MoveFile(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error)
+	CopyFiles(ctx context.Context, req BulkCopyRequest, progress BatchProgressFunc) (*BulkCopyReport, error)
+	DeleteFileVersion(ctx context.Context, bucket, key, versionID string) error
+	ListFileVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error)
+	RestoreFileVersion(ctx context.Context, bucket, key, versionID string) (string, error)
+	GetBucketVersioning(ctx context.Context, bucket string) (string, error)
+	SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error
+	GetBucketStats(ctx context.Context, bucket string, opts StatsOptions) (*BucketStats, error)
+	StartStatsRefresher(ctx context.Context, interval time.Duration)
 	CreateBucket(ctx context.Context, bucket string) error
 	ListAllBuckets(ctx context.Context) ([]BucketSummary, error)
 	DeleteBucket(ctx context.Context, bucket string) error
-	EmptyBucket(ctx context.Context, bucket string) error
+	EmptyBucket(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error)
+	InitiateUpload(ctx context.Context, bucket, filename, contentType string) (*UploadSession, error)
+	GetUploadPartURL(ctx context.Context, bucket, key, uploadID string, partNumber int32) (*PresignedPart, error)
+	UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (*UploadedPart, error)
+	ListUploadParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error)
+	CompleteUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error)
+	AbortUpload(ctx context.Context, bucket, key, uploadID string) error
+	PresignUpload(ctx context.Context, bucket, filename, contentType string, size int64, method string) (*PresignedUpload, error)
+	SearchFiles(ctx context.Context, q search.Query) (*search.Results, error)
+	Reindex(ctx context.Context, bucket string, progress BatchProgressFunc) (*ReindexReport, error)
 }
 
 const (
-	uploadTimeout       = 60 * time.Second
-	deleteTimeout       = 5 * time.Second
-	maxBucketNameLength = 63
-	minBucketNameLength = 3
+	defaultUploadTimeout = 60 * time.Second
+	deleteTimeout        = 5 * time.Second
+	maxBucketNameLength  = 63
+	minBucketNameLength  = 3
+	partURLExpiration    = time.Hour
+	uploadURLExpiration  = 15 * time.Minute
+	maxSinglePutSize     = 5 * 1024 * 1024 * 1024
+	defaultPresignTTL    = 15 * time.Minute
+	defaultMaxPresign    = 7 * 24 * time.Hour
+	maxObjectKeyLength   = 1024
+	deleteBatchSize      = 1000
+	deleteConcurrency    = 4
+	copyConcurrency      = 8
+	defaultStatsTTL      = 10 * time.Minute
 )
 
 var (
 	bucketDNSNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
-	allowedTypes       = map[string]bool{
-		"image/jpeg":      true,
-		"image/png":       true,
-		"image/webp":      true,
-		"application/pdf": true,
-	}
 )
 
 type uploadService struct {
-	repo Repository
+	repo             Repository
+	maxUploadSize    int64
+	uploadTimeout    time.Duration
+	maxPresignExpiry time.Duration
+	metadataLimits   MetadataLimits
+	stats            *statsCache
+	index            *search.Index
+	keyTemplate      KeyTemplate
+	policies         Policies
+	inspectors       inspect.Chain
+	scanner          scan.Scanner
+	scanFailOpen     bool
+	scanMaxSize      int64
+}
+
+type ServiceOption func(*uploadService)
+
+// WithMaxUploadSize caps every upload, whatever its policy says. By default
+// only policies limit the size.
+func WithMaxUploadSize(n int64) ServiceOption {
+	return func(s *uploadService) {
+		if n > 0 {
+			s.maxUploadSize = n
+		}
+	}
 }
 
-func NewService(repo Repository) Service {
-	return &uploadService{repo: repo}
+// WithUploadTimeout bounds a single UploadFile call, including every part of
+// a multipart upload to S3.
+func WithUploadTimeout(d time.Duration) ServiceOption {
+	return func(s *uploadService) {
+		if d > 0 {
+			s.uploadTimeout = d
+		}
+	}
+}
+
+func WithMaxPresignExpiry(d time.Duration) ServiceOption {
+	return func(s *uploadService) {
+		if d > 0 {
+			s.maxPresignExpiry = d
+		}
+	}
+}
+
+func WithMetadataLimits(l MetadataLimits) ServiceOption {
+	return func(s *uploadService) {
+		s.metadataLimits = l.normalized()
+	}
+}
+
+// WithStatsCacheTTL sets how long cached bucket stats are served before the
+// next request rescans the bucket.
+func WithStatsCacheTTL(d time.Duration) ServiceOption {
+	return func(s *uploadService) {
+		if d > 0 {
+			s.stats.ttl = d
+		}
+	}
+}
+
+// WithKeyTemplate replaces DefaultKeyTemplate for naming new uploads. The
+// template should come from ParseKeyTemplate.
+func WithKeyTemplate(t KeyTemplate) ServiceOption {
+	return func(s *uploadService) {
+		if t.raw != "" {
+			s.keyTemplate = t
+		}
+	}
+}
+
+func NewService(repo Repository, opts ...ServiceOption) Service {
+	s := &uploadService{
+		repo:             repo,
+		uploadTimeout:    defaultUploadTimeout,
+		maxPresignExpiry: defaultMaxPresign,
+		metadataLimits:   DefaultMetadataLimits(),
+		stats:            newStatsCache(defaultStatsTTL),
+		keyTemplate:      KeyTemplate{raw: DefaultKeyTemplate},
+		policies:         DefaultPolicies(),
+		inspectors:       inspect.DefaultChain(inspect.Options{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *uploadService) UploadFile(ctx context.Context, bucket string, file *File) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	ctx, cancel := context.WithTimeout(ctx, s.uploadTimeout)
 	defer cancel()
 
 	if err := s.validateBucketName(bucket); err != nil {
 		return "", err
 	}
 
-	if err := s.validateFile(file); err != nil {
+	if err := s.metadataLimits.validateMetadata(file.Metadata); err != nil {
+		return "", err
+	}
+	if err := s.metadataLimits.validateTags(file.Tags); err != nil {
+		return "", err
+	}
+
+	key, err := s.newObjectKey(ctx, file.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.validateFile(ctx, file, s.policyFor(bucket, key)); err != nil {
 		slog.Error("security validation failed", "error", err, "filename", file.Name)
 		return "", err
 	}
 
-	id, err := uuid.NewV7()
+	err = s.scanContent(ctx, bucket, key, file.Name, file.Size, file.Content)
+	if _, seekErr := file.Content.Seek(0, io.SeekStart); err == nil && seekErr != nil {
+		err = fmt.Errorf("failed to reset file pointer: %w", seekErr)
+	}
 	if err != nil {
-		slog.Error("uuid generation failed", "error", err)
-		return "", fmt.Errorf("failed to generate unique id: %w", err)
+		return "", err
 	}
 
-	file.Name = id.String() + filepath.Ext(file.Name)
+	name, userMeta := file.Name, file.Metadata
+	file.Name = key
+	file.Metadata = withOriginalName(userMeta, name)
 
 	url, err := s.repo.Upload(ctx, bucket, file)
 	if err != nil {
@@ -82,7 +212,18 @@ func (s *uploadService) UploadFile(ctx context.Context, bucket string, file *Fil
 	}
 
 	file.URL = url
-	slog.Info("file uploaded successfully", "url", url)
+	s.trackUpload(ctx, bucket, key)
+	s.indexDocuments(search.Document{
+		Bucket:       bucket,
+		Key:          key,
+		Name:         name,
+		ContentType:  file.ContentType,
+		Size:         file.Size,
+		Tags:         file.Tags,
+		Metadata:     userMeta,
+		LastModified: time.Now().UTC(),
+	})
+	slog.Info("file uploaded successfully", "bucket", bucket, "key", key)
 	return url, nil
 }
 
@@ -109,53 +250,133 @@ func (s *uploadService) UploadMultipleFiles(ctx context.Context, bucket string,
 	return results, nil
 }
 
-func (s *uploadService) GetDownloadURL(ctx context.Context, bucket, key string) (string, error) {
+func (s *uploadService) GetDownloadURL(ctx context.Context, bucket, key string, opts PresignOptions) (string, error) {
 	if err := s.validateBucketName(bucket); err != nil {
 		return "", err
 	}
 
-	return s.repo.GetPresignURL(ctx, bucket, key, 15*time.Minute)
+	opts, err := s.normalizePresignOptions(bucket, key, opts)
+	if err != nil {
+		return "", err
+	}
+	opts.ContentDisposition = s.presignDisposition(ctx, bucket, key, opts.ContentDisposition)
+
+	return s.repo.GetPresignURL(ctx, bucket, key, opts)
 }
 
-func (s *uploadService) DownloadFile(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+func (s *uploadService) DownloadFile(ctx context.Context, bucket, key string, opts DownloadOptions) (*Object, error) {
 	if err := s.validateBucketName(bucket); err != nil {
 		return nil, err
 	}
-	return s.repo.Download(ctx, bucket, key)
+
+	obj, err := s.repo.Download(ctx, bucket, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	obj.Info.OriginalName, obj.Info.Metadata = splitOriginalName(obj.Info.Metadata)
+	return obj, nil
 }
 
-func (s *uploadService) ListFiles(ctx context.Context, bucket, ext, token string, limit int) (*PaginatedFiles, error) {
-	if err := s.validateBucketName(bucket); err != nil {
+func (s *uploadService) GetFileMetadata(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	if err := s.validateObject(bucket, key); err != nil {
 		return nil, err
 	}
 
-	if limit <= 0 {
-		limit = 10
+	info, err := s.repo.Stat(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Tags, err = s.repo.GetObjectTags(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	info.OriginalName, info.Metadata = splitOriginalName(info.Metadata)
+	return info, nil
+}
+
+func (s *uploadService) GetFileTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	if err := s.validateObject(bucket, key); err != nil {
+		return nil, err
 	}
 
-	res, err := s.repo.List(ctx, bucket, "", token, int32(limit))
+	tags, err := s.repo.GetObjectTags(ctx, bucket, key)
 	if err != nil {
 		return nil, err
 	}
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return tags, nil
+}
 
-	if ext == "" {
-		return res, nil
+func (s *uploadService) ReplaceFileTags(ctx context.Context, bucket, key string, tags map[string]string) error {
+	if err := s.validateObject(bucket, key); err != nil {
+		return err
+	}
+	if err := s.metadataLimits.validateTags(tags); err != nil {
+		return err
 	}
 
-	var filtered []FileSummary
-	target := strings.ToLower(ext)
+	if err := s.repo.PutObjectTags(ctx, bucket, key, tags); err != nil {
+		slog.Error("failed to update object tags", "error", err, "bucket", bucket, "key", key)
+		return err
+	}
+	s.indexObject(ctx, bucket, key)
+	return nil
+}
 
-	if !strings.HasPrefix(target, ".") {
-		target = "." + target
+func (s *uploadService) DeleteFileTags(ctx context.Context, bucket, key string) error {
+	if err := s.validateObject(bucket, key); err != nil {
+		return err
 	}
+	if err := s.repo.DeleteObjectTags(ctx, bucket, key); err != nil {
+		return err
+	}
+	s.indexObject(ctx, bucket, key)
+	return nil
+}
 
-	for _, f := range res.Files {
-		if strings.ToLower(f.Extension) == target {
-			filtered = append(filtered, f)
-		}
+func (s *uploadService) ListFiles(ctx context.Context, bucket string, q ListQuery) (*PaginatedFiles, error) {
+	if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+
+	if len(q.Prefix) > maxObjectKeyLength {
+		return nil, fmt.Errorf("%w: prefix must be at most %d bytes", ErrInvalidObjectKey, maxObjectKeyLength)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	filter, err := newFileFilter(q)
+	if err != nil {
+		return nil, err
 	}
 
-	res.Files = filtered
+	var res *PaginatedFiles
+	if filter.active() {
+		res, err = s.listFiltered(ctx, bucket, q, filter, limit)
+	} else {
+		res, err = s.repo.List(ctx, bucket, ListOptions{
+			Prefix:          q.Prefix,
+			Delimiter:       q.Delimiter,
+			Token:           q.Token,
+			Limit:           int32(limit),
+			IncludeMetadata: q.IncludeMetadata,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res.Prefix = q.Prefix
+	if q.Delimiter != "" {
+		res.Breadcrumbs = breadcrumbs(bucket, q.Prefix, q.Delimiter)
+	}
+	for i := range res.Files {
+		res.Files[i].OriginalName, res.Files[i].Metadata = splitOriginalName(res.Files[i].Metadata)
+	}
 	return res, nil
 }
 
@@ -171,14 +392,333 @@ func (s *uploadService) DeleteFile(ctx context.Context, bucket string, key strin
 		return err
 	}
 
-	return s.repo.Delete(ctx, bucket, key)
+	var removed *ObjectInfo
+	if s.stats.tracks(bucket) {
+		removed, _ = s.repo.Stat(ctx, bucket, key)
+	}
+
+	if err := s.repo.Delete(ctx, bucket, key); err != nil {
+		return err
+	}
+
+	if removed != nil {
+		s.stats.removed(bucket, statsObject(key, removed), removed.StorageClass)
+	}
+	s.unindex(bucket, key)
+	return nil
 }
 
-func (s *uploadService) GetBucketStats(ctx context.Context, bucket string) (*BucketStats, error) {
+func (s *uploadService) DeleteFiles(ctx context.Context, bucket string, req BatchDeleteRequest, progress BatchProgressFunc) (*BatchDeleteReport, error) {
 	if err := s.validateBucketName(bucket); err != nil {
 		return nil, err
 	}
-	return s.repo.GetStats(ctx, bucket)
+	if len(req.Keys) == 0 && req.Prefix == "" {
+		return nil, fmt.Errorf("%w: keys or prefix is required", ErrInvalidObjectKey)
+	}
+
+	report := &BatchDeleteReport{Bucket: bucket, Results: []DeleteResult{}}
+	seen := make(map[string]bool, len(req.Keys))
+	var valid []string
+	for _, key := range req.Keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if key == "" || len(key) > maxObjectKeyLength {
+			report.Results = append(report.Results, DeleteResult{Key: key, Error: ErrInvalidObjectKey.Error()})
+			continue
+		}
+		valid = append(valid, key)
+	}
+	invalid := len(report.Results)
+	defer s.stats.invalidate(bucket)
+
+	// Each chunk fills its own slot, so results keep the order of the keys
+	// however the workers finish.
+	var results []*[]DeleteResult
+	tracker := newProgressTracker(0, progress)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(deleteConcurrency)
+
+	// deleteChunk keeps every result of explicit keys but only the failures of
+	// listed ones, so deleting a large prefix does not pile up in the report.
+	deleteChunk := func(chunk []string, failuresOnly bool) {
+		slot := new([]DeleteResult)
+		results = append(results, slot)
+		tracker.grow(len(chunk))
+
+		g.Go(func() error {
+			res, err := s.repo.DeleteMany(gctx, bucket, chunk)
+			if errors.Is(err, ErrBucketNotFound) {
+				return err
+			}
+			if err != nil {
+				slog.Error("batch delete chunk failed", "error", err, "bucket", bucket, "keys", len(chunk))
+				res = make([]DeleteResult, 0, len(chunk))
+				for _, key := range chunk {
+					res = append(res, DeleteResult{Key: key, Error: err.Error()})
+				}
+			}
+
+			var deleted []string
+			for _, r := range res {
+				if r.Deleted {
+					deleted = append(deleted, r.Key)
+					if failuresOnly {
+						continue
+					}
+				}
+				*slot = append(*slot, r)
+			}
+			s.unindex(bucket, deleted...)
+			tracker.add(len(chunk), len(res)-len(deleted))
+			return nil
+		})
+	}
+
+	for chunk := range slices.Chunk(valid, deleteBatchSize) {
+		deleteChunk(chunk, false)
+	}
+	var listErr error
+	if req.Prefix != "" {
+		listErr = s.listKeyPages(gctx, bucket, req.Prefix, func(keys []string) {
+			keys = slices.DeleteFunc(keys, func(key string) bool { return seen[key] })
+			if len(keys) > 0 {
+				deleteChunk(keys, true)
+			}
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	for _, res := range results {
+		report.Results = append(report.Results, *res...)
+	}
+	progressed := tracker.snapshot()
+	report.Deleted = progressed.Processed - progressed.Failed
+	report.Failed = invalid + progressed.Failed
+
+	slog.Info("batch delete finished", "bucket", bucket, "deleted", report.Deleted, "failed", report.Failed)
+	return report, nil
+}
+
+func (s *uploadService) CopyFile(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	dstKey, err := s.validateCopy(srcBucket, srcKey, dstBucket, dstKey, opts)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := s.repo.Copy(ctx, srcBucket, srcKey, dstBucket, dstKey, opts)
+	if err != nil {
+		slog.Error("failed to copy object", "error", err, "bucket", srcBucket, "key", srcKey, "destination_bucket", dstBucket)
+		return "", err
+	}
+
+	s.stats.invalidate(dstBucket)
+	s.indexObject(ctx, dstBucket, dstKey)
+	slog.Info("object copied", "bucket", srcBucket, "key", srcKey, "destination_bucket", dstBucket, "destination_key", dstKey)
+	return url, nil
+}
+
+func (s *uploadService) MoveFile(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	url, err := s.CopyFile(ctx, srcBucket, srcKey, dstBucket, dstKey, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.repo.Delete(ctx, srcBucket, srcKey); err != nil {
+		slog.Error("object copied but source could not be removed", "error", err, "bucket", srcBucket, "key", srcKey)
+		return "", fmt.Errorf("failed to remove source after copy: %w", err)
+	}
+	s.stats.invalidate(srcBucket)
+	s.unindex(srcBucket, srcKey)
+	return url, nil
+}
+
+func (s *uploadService) CopyFiles(ctx context.Context, req BulkCopyRequest, progress BatchProgressFunc) (*BulkCopyReport, error) {
+	if err := s.validateBucketName(req.SourceBucket); err != nil {
+		return nil, err
+	}
+	if err := s.validateBucketName(req.DestinationBucket); err != nil {
+		return nil, err
+	}
+	if req.SourceBucket == req.DestinationBucket {
+		return nil, ErrSameBucket
+	}
+
+	defer s.stats.invalidate(req.DestinationBucket)
+
+	report := &BulkCopyReport{SourceBucket: req.SourceBucket, DestinationBucket: req.DestinationBucket}
+	tracker := newProgressTracker(0, progress)
+
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(copyConcurrency)
+	copyKey := func(key string) {
+		g.Go(func() error {
+			_, err := s.repo.Copy(gctx, req.SourceBucket, key, req.DestinationBucket, key, CopyOptions{})
+			if errors.Is(err, ErrBucketNotFound) || gctx.Err() != nil {
+				return err
+			}
+
+			failed := 0
+			mu.Lock()
+			if err != nil {
+				failed = 1
+				report.Failed++
+				report.Failures = append(report.Failures, CopyFailure{Key: key, Error: err.Error()})
+			} else {
+				report.Copied++
+			}
+			mu.Unlock()
+
+			if err == nil {
+				s.copyIndexed(req.SourceBucket, req.DestinationBucket, key)
+			}
+
+			tracker.add(1, failed)
+			return nil
+		})
+	}
+
+	listErr := s.listKeyPages(gctx, req.SourceBucket, req.Prefix, func(keys []string) {
+		tracker.grow(len(keys))
+		for _, key := range keys {
+			copyKey(key)
+		}
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	slog.Info("bulk copy finished", "bucket", req.SourceBucket, "destination_bucket", req.DestinationBucket, "copied", report.Copied, "failed", report.Failed)
+	return report, nil
+}
+
+func (s *uploadService) DeleteFileVersion(ctx context.Context, bucket, key, versionID string) error {
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+
+	if err := s.validateObject(bucket, key); err != nil {
+		return err
+	}
+	if versionID == "" {
+		return fmt.Errorf("%w: version id is required", ErrInvalidObjectKey)
+	}
+
+	if err := s.repo.DeleteVersion(ctx, bucket, key, versionID); err != nil {
+		slog.Error("failed to delete object version", "error", err, "bucket", bucket, "key", key, "version_id", versionID)
+		return err
+	}
+	s.stats.invalidate(bucket)
+	s.indexObject(ctx, bucket, key)
+	return nil
+}
+
+func (s *uploadService) ListFileVersions(ctx context.Context, bucket string, opts VersionListOptions) (*PaginatedVersions, error) {
+	if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	return s.repo.ListVersions(ctx, bucket, opts)
+}
+
+func (s *uploadService) RestoreFileVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	if err := s.validateObject(bucket, key); err != nil {
+		return "", err
+	}
+	if versionID == "" {
+		return "", fmt.Errorf("%w: version id is required", ErrInvalidObjectKey)
+	}
+
+	restored, err := s.repo.RestoreVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		slog.Error("failed to restore object version", "error", err, "bucket", bucket, "key", key, "version_id", versionID)
+		return "", err
+	}
+
+	s.stats.invalidate(bucket)
+	s.indexObject(ctx, bucket, key)
+	slog.Info("object version restored", "bucket", bucket, "key", key, "from", versionID, "version_id", restored)
+	return restored, nil
+}
+
+func (s *uploadService) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	if err := s.validateBucketName(bucket); err != nil {
+		return "", err
+	}
+	return s.repo.GetBucketVersioning(ctx, bucket)
+}
+
+func (s *uploadService) SetBucketVersioning(ctx context.Context, bucket string, enabled bool) error {
+	if err := s.validateBucketName(bucket); err != nil {
+		return err
+	}
+	return s.repo.SetBucketVersioning(ctx, bucket, enabled)
+}
+
+func (s *uploadService) GetBucketStats(ctx context.Context, bucket string, opts StatsOptions) (*BucketStats, error) {
+	if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if len(opts.Prefix) > maxObjectKeyLength {
+		return nil, fmt.Errorf("%w: prefix must be at most %d bytes", ErrInvalidObjectKey, maxObjectKeyLength)
+	}
+
+	if !opts.Refresh {
+		if stats, ok := s.stats.get(bucket, opts.Prefix); ok {
+			return stats, nil
+		}
+	}
+	return s.rescanStats(ctx, bucket, opts.Prefix)
+}
+
+// StartStatsRefresher rescans every cached stats scope each interval until ctx
+// is done, so drift from writes the service did not see is bounded.
+func (s *uploadService) StartStatsRefresher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, scope := range s.stats.scopes() {
+					if _, err := s.rescanStats(ctx, scope.bucket, scope.prefix); err != nil {
+						slog.Error("failed to refresh bucket stats", "error", err, "bucket", scope.bucket, "prefix", scope.prefix)
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *uploadService) rescanStats(ctx context.Context, bucket, prefix string) (*BucketStats, error) {
+	stats, err := s.repo.GetStats(ctx, bucket, prefix)
+	if err != nil {
+		if errors.Is(err, ErrBucketNotFound) {
+			s.stats.invalidate(bucket)
+		}
+		return nil, err
+	}
+	s.stats.put(stats)
+	return stats, nil
 }
 
 func (s *uploadService) CreateBucket(ctx context.Context, bucket string) error {
@@ -202,14 +742,212 @@ func (s *uploadService) DeleteBucket(ctx context.Context, bucket string) error {
 	if err := s.validateBucketName(bucket); err != nil {
 		return err
 	}
-	return s.repo.DeleteBucket(ctx, bucket)
+	if err := s.repo.DeleteBucket(ctx, bucket); err != nil {
+		return err
+	}
+	s.stats.invalidate(bucket)
+	s.unindexBucket(bucket)
+	return nil
 }
 
-func (s *uploadService) EmptyBucket(ctx context.Context, bucket string) error {
+func (s *uploadService) EmptyBucket(ctx context.Context, bucket string, progress EmptyProgressFunc) (*EmptyBucketReport, error) {
 	if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+
+	defer s.stats.invalidate(bucket)
+	report, err := s.repo.DeleteAll(ctx, bucket, func(r EmptyBucketReport) {
+		slog.Info("emptying bucket", "bucket", bucket, "deleted", r.ObjectsDeleted, "uploads_aborted", r.UploadsAborted)
+		if progress != nil {
+			progress(r)
+		}
+	})
+	if err != nil {
+		slog.Error("failed to empty bucket", "error", err, "bucket", bucket)
+		return report, err
+	}
+
+	s.unindexBucket(bucket)
+	slog.Info("bucket emptied", "bucket", bucket, "deleted", report.ObjectsDeleted, "uploads_aborted", report.UploadsAborted)
+	return report, nil
+}
+
+func (s *uploadService) InitiateUpload(ctx context.Context, bucket, filename, contentType string) (*UploadSession, error) {
+	if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+
+	key, err := s.newObjectKey(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			slog.Warn("rejected declared file type", "type", contentType)
+			return nil, ErrInvalidFileType
+		}
+		contentType = mediaType
+	}
+	if err := s.policyFor(bucket, key).checkDeclared(filename, contentType, 0); err != nil {
+		slog.Warn("rejected multipart upload", "error", err, "bucket", bucket)
+		return nil, err
+	}
+
+	uploadID, err := s.repo.CreateMultipartUpload(ctx, bucket, key, contentType, withOriginalName(nil, filename))
+	if err != nil {
+		slog.Error("failed to initiate multipart upload", "error", err, "bucket", bucket)
+		return nil, err
+	}
+
+	slog.Info("multipart upload initiated", "bucket", bucket, "key", key, "upload_id", uploadID)
+	return &UploadSession{UploadID: uploadID, Bucket: bucket, Key: key}, nil
+}
+
+func (s *uploadService) GetUploadPartURL(ctx context.Context, bucket, key, uploadID string, partNumber int32) (*PresignedPart, error) {
+	if err := s.validateUploadSession(bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+	if !validPartNumber(partNumber) {
+		return nil, ErrInvalidPartNumber
+	}
+
+	url, err := s.repo.PresignUploadPart(ctx, bucket, key, uploadID, partNumber, partURLExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedPart{
+		PartNumber: partNumber,
+		URL:        url,
+		ExpiresAt:  time.Now().Add(partURLExpiration).UTC(),
+	}, nil
+}
+
+func (s *uploadService) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (*UploadedPart, error) {
+	if err := s.validateUploadSession(bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+	if !validPartNumber(partNumber) {
+		return nil, ErrInvalidPartNumber
+	}
+
+	etag, err := s.repo.UploadPart(ctx, bucket, key, uploadID, partNumber, body, size)
+	if err != nil {
+		slog.Error("failed to upload part", "error", err, "bucket", bucket, "upload_id", uploadID, "part", partNumber)
+		return nil, err
+	}
+
+	return &UploadedPart{PartNumber: partNumber, ETag: etag, Size: size}, nil
+}
+
+func (s *uploadService) ListUploadParts(ctx context.Context, bucket, key, uploadID string) ([]UploadedPart, error) {
+	if err := s.validateUploadSession(bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListParts(ctx, bucket, key, uploadID)
+}
+
+func (s *uploadService) CompleteUpload(ctx context.Context, bucket, key, uploadID string, parts []UploadedPart) (string, error) {
+	if err := s.validateUploadSession(bucket, key, uploadID); err != nil {
+		return "", err
+	}
+
+	if len(parts) == 0 {
+		listed, err := s.repo.ListParts(ctx, bucket, key, uploadID)
+		if err != nil {
+			return "", err
+		}
+		parts = listed
+	}
+
+	url, err := s.repo.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+	if err != nil {
+		slog.Error("failed to complete multipart upload", "error", err, "bucket", bucket, "upload_id", uploadID)
+		return "", err
+	}
+
+	if err := s.validateStoredObject(ctx, bucket, key); err != nil {
+		return "", err
+	}
+
+	s.trackUpload(ctx, bucket, key)
+	s.indexObject(ctx, bucket, key)
+	slog.Info("multipart upload completed", "bucket", bucket, "key", key)
+	return url, nil
+}
+
+func (s *uploadService) AbortUpload(ctx context.Context, bucket, key, uploadID string) error {
+	if err := s.validateUploadSession(bucket, key, uploadID); err != nil {
 		return err
 	}
-	return s.repo.DeleteAll(ctx, bucket)
+	return s.repo.AbortMultipartUpload(ctx, bucket, key, uploadID)
+}
+
+func (s *uploadService) PresignUpload(ctx context.Context, bucket, filename, contentType string, size int64, method string) (*PresignedUpload, error) {
+	if err := s.validateBucketName(bucket); err != nil {
+		return nil, err
+	}
+
+	method = strings.ToUpper(method)
+	switch method {
+	case "":
+		method = http.MethodPut
+	case http.MethodPut, http.MethodPost:
+	default:
+		return nil, ErrInvalidPresignMethod
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		slog.Warn("rejected declared file type", "type", contentType)
+		return nil, ErrInvalidFileType
+	}
+
+	if size < 0 {
+		return nil, ErrFileTooLarge
+	}
+	// A PUT is only bounded by its signed Content-Length; POST policies carry
+	// a content-length-range instead.
+	if method == http.MethodPut && size == 0 {
+		return nil, ErrUploadSizeRequired
+	}
+
+	key, err := s.newObjectKey(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := s.policyFor(bucket, key)
+	if err := policy.checkDeclared(filename, mediaType, size); err != nil {
+		slog.Warn("rejected presigned upload", "error", err, "bucket", bucket)
+		return nil, err
+	}
+
+	// Storage takes at most 5 GiB in a single PUT or POST.
+	limit := int64(maxSinglePutSize)
+	if policy.maxSize > 0 {
+		limit = min(limit, policy.maxSize)
+	}
+	if size > limit {
+		return nil, fmt.Errorf("%w: presigned uploads are limited to %d bytes", ErrFileTooLarge, limit)
+	}
+
+	presigned, err := s.repo.PresignUpload(ctx, bucket, key, UploadPresignOptions{
+		Method:        method,
+		ContentType:   mediaType,
+		ContentLength: size,
+		MaxSize:       limit,
+		Expiration:    uploadURLExpiration,
+	})
+	if err != nil {
+		slog.Error("failed to presign upload", "error", err, "bucket", bucket)
+		return nil, err
+	}
+
+	slog.Info("upload presigned", "bucket", bucket, "key", key, "method", method)
+	return presigned, nil
 }
 
 func (s *uploadService) ListAllBuckets(ctx context.Context) ([]BucketSummary, error) {
@@ -237,27 +975,310 @@ func (s *uploadService) validateBucketName(bucket string) error {
 	return nil
 }
 
-func (s *uploadService) validateFile(f *File) error {
-	seeker, ok := f.Content.(io.Seeker)
-	if !ok {
-		return fmt.Errorf("file content must support seeking")
+func (s *uploadService) normalizePresignOptions(bucket, key string, opts PresignOptions) (PresignOptions, error) {
+	if opts.Expiration == 0 {
+		opts.Expiration = min(defaultPresignTTL, s.maxPresignExpiry)
+	}
+	if opts.Expiration < 0 || opts.Expiration > s.maxPresignExpiry {
+		return opts, fmt.Errorf("%w: must be at most %s", ErrInvalidPresignExpiry, s.maxPresignExpiry)
+	}
+
+	if opts.ContentDisposition != "" {
+		disposition, params, err := mime.ParseMediaType(opts.ContentDisposition)
+		if err != nil || (disposition != "inline" && disposition != "attachment") {
+			return opts, ErrInvalidDisposition
+		}
+		opts.ContentDisposition = mime.FormatMediaType(disposition, params)
+	}
+
+	if opts.ContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(opts.ContentType)
+		if err != nil || (!s.policyFor(bucket, key).allowsType(mediaType) && mediaType != "application/octet-stream") {
+			return opts, ErrInvalidFileType
+		}
+		opts.ContentType = mediaType
+	}
+
+	return opts, nil
+}
+
+func (s *uploadService) validateObject(bucket, key string) error {
+	if err := s.validateBucketName(bucket); err != nil {
+		return err
+	}
+	if key == "" {
+		return ErrInvalidObjectKey
+	}
+	return nil
+}
+
+// breadcrumbs splits prefix into the chain of folders leading to it, starting
+// with the bucket root.
+func breadcrumbs(bucket, prefix, delimiter string) []Breadcrumb {
+	crumbs := []Breadcrumb{{Name: bucket, Prefix: ""}}
+	current := ""
+	for _, segment := range strings.SplitAfter(prefix, delimiter) {
+		if segment == "" || segment == delimiter {
+			current += segment
+			continue
+		}
+		current += segment
+		crumbs = append(crumbs, Breadcrumb{Name: strings.TrimSuffix(segment, delimiter), Prefix: current})
+	}
+	return crumbs
+}
+
+// listKeyPages lists bucket under prefix and hands fn the keys of each page
+// as it arrives, so a large prefix is never held in memory at once.
+func (s *uploadService) listKeyPages(ctx context.Context, bucket, prefix string, fn func(keys []string)) error {
+	token := ""
+	for {
+		page, err := s.repo.List(ctx, bucket, ListOptions{Prefix: prefix, Token: token, Limit: deleteBatchSize})
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(page.Files))
+		for _, f := range page.Files {
+			keys = append(keys, f.Key)
+		}
+		fn(keys)
+		if page.NextToken == "" {
+			return nil
+		}
+		token = page.NextToken
+	}
+}
+
+// progressTracker accumulates BatchProgress from concurrent workers and
+// reports each update while holding its lock, so callbacks never overlap.
+type progressTracker struct {
+	mu       sync.Mutex
+	progress BatchProgress
+	report   BatchProgressFunc
+}
+
+func newProgressTracker(total int, report BatchProgressFunc) *progressTracker {
+	return &progressTracker{progress: BatchProgress{Total: total}, report: report}
+}
+
+// grow raises the total as more work is found, such as a newly listed page.
+// The change is reported with the next add.
+func (t *progressTracker) grow(n int) {
+	t.mu.Lock()
+	t.progress.Total += n
+	t.mu.Unlock()
+}
+
+func (t *progressTracker) snapshot() BatchProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
+
+func (t *progressTracker) add(processed, failed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress.Processed += processed
+	t.progress.Failed += failed
+	if t.report != nil {
+		t.report(t.progress)
+	}
+}
+
+func (s *uploadService) validateCopy(srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) (string, error) {
+	if err := s.validateObject(srcBucket, srcKey); err != nil {
+		return "", err
 	}
+	if err := s.validateBucketName(dstBucket); err != nil {
+		return "", err
+	}
+
+	if dstKey == "" {
+		dstKey = srcKey
+	}
+	if len(dstKey) > maxObjectKeyLength {
+		return "", fmt.Errorf("%w: keys must be at most %d bytes", ErrInvalidObjectKey, maxObjectKeyLength)
+	}
+	if srcBucket == dstBucket && srcKey == dstKey {
+		return "", fmt.Errorf("%w: source and destination are the same object", ErrInvalidObjectKey)
+	}
+
+	if err := s.metadataLimits.validateMetadata(opts.Metadata); err != nil {
+		return "", err
+	}
+	if err := s.metadataLimits.validateTags(opts.Tags); err != nil {
+		return "", err
+	}
+	return dstKey, nil
+}
+
+func (s *uploadService) validateUploadSession(bucket, key, uploadID string) error {
+	if err := s.validateBucketName(bucket); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("%w: file key is required", ErrInvalidObjectKey)
+	}
+	if uploadID == "" {
+		return ErrUploadNotFound
+	}
+	return nil
+}
 
-	buffer := make([]byte, 512)
-	n, err := f.Content.Read(buffer)
-	if err != nil && err != io.EOF {
-		return fmt.Errorf("failed to read file header: %w", err)
+// trackUpload adds a freshly written object to any cached stats for bucket.
+// Upload keys are always new, so nothing has to be subtracted.
+func (s *uploadService) trackUpload(ctx context.Context, bucket, key string) {
+	if !s.stats.tracks(bucket) {
+		return
 	}
 
-	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+	info, err := s.repo.Stat(ctx, bucket, key)
+	if err != nil {
+		s.stats.invalidate(bucket)
+		return
+	}
+	s.stats.added(bucket, statsObject(key, info), info.StorageClass)
+}
+
+func statsObject(key string, info *ObjectInfo) StatsObject {
+	return StatsObject{Key: key, SizeBytes: info.Size, LastModified: info.LastModified}
+}
+
+func (s *uploadService) newObjectKey(ctx context.Context, filename string) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		slog.Error("uuid generation failed", "error", err)
+		return "", fmt.Errorf("failed to generate unique id: %w", err)
+	}
+
+	key := s.keyTemplate.expand(id.String(), filename, userFromContext(ctx), time.Now())
+	if len(key) > maxObjectKeyLength {
+		return "", fmt.Errorf("%w: generated key is longer than %d bytes", ErrInvalidObjectKey, maxObjectKeyLength)
+	}
+	return key, nil
+}
+
+// presignDisposition adds the original filename to a presigned download so
+// browsers save it under that name. Without an explicit disposition the
+// object is served inline, as it would be without one.
+func (s *uploadService) presignDisposition(ctx context.Context, bucket, key, disposition string) string {
+	if disposition != "" {
+		if _, params, _ := mime.ParseMediaType(disposition); params["filename"] != "" {
+			return disposition
+		}
+	}
+
+	info, err := s.repo.Stat(ctx, bucket, key)
+	if err != nil {
+		return disposition
+	}
+	name, _ := splitOriginalName(info.Metadata)
+	if name == "" {
+		return disposition
+	}
+
+	kind := "inline"
+	if disposition != "" {
+		kind, _, _ = mime.ParseMediaType(disposition)
+	}
+	return contentDisposition(kind, name)
+}
+
+// contentDisposition formats a Content-Disposition value, falling back to the
+// RFC 2231 filename* form for names that are not plain ASCII.
+func contentDisposition(kind, filename string) string {
+	if v := mime.FormatMediaType(kind, map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return kind
+}
+
+func (s *uploadService) validateFile(ctx context.Context, f *File, policy uploadPolicy) error {
+	if err := policy.checkDeclared(f.Name, "", f.Size); err != nil {
+		return err
+	}
+
+	size, err := f.Content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to measure file: %w", err)
+	}
+	in := &inspect.Input{Name: f.Name, Size: size, Content: &seekerReaderAt{rs: f.Content}}
+	if ra, ok := f.Content.(io.ReaderAt); ok {
+		in.Content = ra
+	}
+
+	inspectErr := s.inspectContent(ctx, in, policy, f.ContentType)
+	if _, err := f.Content.Seek(0, io.SeekStart); err != nil {
 		return fmt.Errorf("failed to reset file pointer: %w", err)
 	}
+	if inspectErr != nil {
+		return inspectErr
+	}
+
+	if in.Modified() {
+		f.Content = sanitizedContent{SectionReader: in.Reader(), original: f.Content}
+		f.Size = in.Size
+	}
+	return nil
+}
+
+func (s *uploadService) validateStoredObject(ctx context.Context, bucket, key string) error {
+	info, err := s.repo.Stat(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	policy := s.policyFor(bucket, key)
+	name, _ := splitOriginalName(info.Metadata)
+	if name == "" {
+		name = key
+	}
+	in := &inspect.Input{
+		Name:    name,
+		Size:    info.Size,
+		Content: &objectReaderAt{ctx: ctx, repo: s.repo, bucket: bucket, key: key, size: info.Size},
+	}
 
-	detectedType := http.DetectContentType(buffer[:n])
-	if !allowedTypes[detectedType] {
-		slog.Warn("rejected file type", "type", detectedType)
-		return ErrInvalidFileType
+	err = policy.checkDeclared(name, "", info.Size)
+	if err == nil {
+		err = s.inspectContent(ctx, in, policy, info.ContentType)
+	}
+	if err == nil {
+		err = s.scanContent(ctx, bucket, key, name, in.Size, in.Reader())
+	}
+	if err == nil && in.Modified() {
+		_, err = s.repo.Upload(ctx, bucket, &File{
+			Name:        key,
+			Content:     sanitizedContent{SectionReader: in.Reader()},
+			Size:        in.Size,
+			ContentType: info.ContentType,
+			Metadata:    info.Metadata,
+		})
+	}
+	if err != nil {
+		slog.Warn("rejected stored object", "error", err, "bucket", bucket, "key", key)
+		// A plain delete on a versioned bucket only adds a delete marker and
+		// keeps the rejected bytes as a noncurrent version.
+		var delErr error
+		if info.VersionID != "" {
+			delErr = s.repo.DeleteVersion(ctx, bucket, key, info.VersionID)
+		} else {
+			delErr = s.repo.Delete(ctx, bucket, key)
+		}
+		if delErr != nil {
+			slog.Error("failed to remove rejected object", "error", delErr, "bucket", bucket, "key", key, "version_id", info.VersionID)
+		}
+		return err
 	}
 
+	// On a versioned bucket the unsanitized upload stays behind as the
+	// previous version. The sanitized object is already current, so failing
+	// to remove it does not fail the upload.
+	if in.Modified() && info.VersionID != "" {
+		if err := s.repo.DeleteVersion(ctx, bucket, key, info.VersionID); err != nil {
+			slog.Error("failed to remove unsanitized version", "error", err, "bucket", bucket, "key", key, "version_id", info.VersionID)
+		}
+	}
 	return nil
 }
@@ -2,12 +2,17 @@ package upload
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type readSeekCloser struct {
@@ -34,7 +39,7 @@ func TestUploadFile_Success(t *testing.T) {
 	service := NewService(mockRepo)
 	ctx := context.Background()
 
-	content := strings.NewReader("\x89PNG\r\n\x1a\n" + strings.Repeat("0", 512))
+	content := strings.NewReader(testPNG + strings.Repeat("0", 512))
 	file := &File{
 		Name:    "test-image.png",
 		Content: readSeekCloser{content},
@@ -54,6 +59,35 @@ func TestUploadFile_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestUploadFile_KeyTemplate(t *testing.T) {
+	_, err := ParseKeyTemplate("{user}/{name}{ext}")
+	assert.Error(t, err, "keys without {uuid} could collide")
+	_, err = ParseKeyTemplate("{uuid}{extension}")
+	assert.Error(t, err)
+	_, err = ParseKeyTemplate("/{uuid}")
+	assert.Error(t, err)
+
+	tmpl, err := ParseKeyTemplate("{user}/{yyyy}/{mm}/{name}-{uuid}{ext}")
+	require.NoError(t, err)
+
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	ctx := WithUser(context.Background(), "jane doe/../admin")
+	require.NoError(t, repo.CreateBucket(ctx, "my-bucket"))
+	service := NewService(repo, WithKeyTemplate(tmpl))
+
+	_, err = service.UploadFile(ctx, "my-bucket", &File{Name: "Q1 report.pdf", Content: readSeekCloser{strings.NewReader(testPDF)}})
+	require.NoError(t, err)
+
+	page, err := service.ListFiles(ctx, "my-bucket", ListQuery{IncludeMetadata: true})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 1)
+
+	now := time.Now().UTC()
+	pattern := fmt.Sprintf(`^jane-doe-\.\.-admin/%s/Q1-report-[0-9a-f-]{36}\.pdf$`, now.Format("2006/01"))
+	assert.Regexp(t, pattern, page.Files[0].Key)
+	assert.Equal(t, "Q1 report.pdf", page.Files[0].OriginalName)
+}
+
 func TestGetDownloadURL_Success(t *testing.T) {
 	mockRepo := new(RepositoryMock)
 	service := NewService(mockRepo)
@@ -62,12 +96,173 @@ func TestGetDownloadURL_Success(t *testing.T) {
 	key := "image.png"
 	expectedPresignedURL := "https://s3.amazonaws.com/my-bucket/image.png?signed=true"
 
-	mockRepo.On("GetPresignURL", mock.Anything, bucket, key, 15*time.Minute).
-		Return(expectedPresignedURL, nil)
+	mockRepo.On("GetPresignURL", mock.Anything, bucket, key, PresignOptions{
+		Expiration:         time.Hour,
+		ContentDisposition: `attachment; filename="report 2024.png"`,
+		ContentType:        "image/png",
+	}).Return(expectedPresignedURL, nil)
 
-	url, err := service.GetDownloadURL(context.Background(), bucket, key)
+	url, err := service.GetDownloadURL(context.Background(), bucket, key, PresignOptions{
+		Expiration:         time.Hour,
+		ContentDisposition: `attachment; filename="report 2024.png"`,
+		ContentType:        "image/png",
+	})
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedPresignedURL, url)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestGetDownloadURL_DefaultsAndBounds(t *testing.T) {
+	mockRepo := new(RepositoryMock)
+	service := NewService(mockRepo, WithMaxPresignExpiry(time.Hour))
+
+	mockRepo.On("Stat", mock.Anything, "my-bucket", "image.png").Return(nil, ErrFileNotFound)
+	mockRepo.On("GetPresignURL", mock.Anything, "my-bucket", "image.png", PresignOptions{Expiration: 15 * time.Minute}).
+		Return("https://example.com/signed", nil)
+
+	_, err := service.GetDownloadURL(context.Background(), "my-bucket", "image.png", PresignOptions{})
+	assert.NoError(t, err)
+
+	_, err = service.GetDownloadURL(context.Background(), "my-bucket", "image.png", PresignOptions{Expiration: 2 * time.Hour})
+	assert.ErrorIs(t, err, ErrInvalidPresignExpiry)
+
+	_, err = service.GetDownloadURL(context.Background(), "my-bucket", "image.png", PresignOptions{ContentDisposition: "evil"})
+	assert.ErrorIs(t, err, ErrInvalidDisposition)
+
+	_, err = service.GetDownloadURL(context.Background(), "my-bucket", "image.png", PresignOptions{ContentType: "text/html"})
+	assert.ErrorIs(t, err, ErrInvalidFileType)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetDownloadURL_UsesOriginalName(t *testing.T) {
+	mockRepo := new(RepositoryMock)
+	service := NewService(mockRepo)
+
+	info := &ObjectInfo{Key: "0192.png", Metadata: withOriginalName(nil, "Café menu.png")}
+	mockRepo.On("Stat", mock.Anything, "my-bucket", "0192.png").Return(info, nil)
+	mockRepo.On("GetPresignURL", mock.Anything, "my-bucket", "0192.png", PresignOptions{
+		Expiration:         15 * time.Minute,
+		ContentDisposition: "inline; filename*=utf-8''Caf%C3%A9%20menu.png",
+	}).Return("https://example.com/inline", nil).Once()
+	mockRepo.On("GetPresignURL", mock.Anything, "my-bucket", "0192.png", PresignOptions{
+		Expiration:         15 * time.Minute,
+		ContentDisposition: "attachment; filename*=utf-8''Caf%C3%A9%20menu.png",
+	}).Return("https://example.com/attachment", nil).Once()
+
+	url, err := service.GetDownloadURL(context.Background(), "my-bucket", "0192.png", PresignOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/inline", url)
+
+	url, err = service.GetDownloadURL(context.Background(), "my-bucket", "0192.png", PresignOptions{ContentDisposition: "attachment"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/attachment", url)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteFiles_ChunksAndReportsFailures(t *testing.T) {
+	mockRepo := new(RepositoryMock)
+	service := NewService(mockRepo)
+	ctx := context.Background()
+
+	keys := make([]string, 2500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("file-%04d.png", i)
+	}
+
+	chunkStarting := func(key string) any {
+		return mock.MatchedBy(func(chunk []string) bool { return chunk[0] == key })
+	}
+	deleted := func(chunk []string) []DeleteResult {
+		res := make([]DeleteResult, len(chunk))
+		for i, k := range chunk {
+			res[i] = DeleteResult{Key: k, Deleted: true}
+		}
+		return res
+	}
+
+	mockRepo.On("DeleteMany", mock.Anything, "my-bucket", chunkStarting(keys[0])).Return(deleted(keys[:1000]), nil).Once()
+	mockRepo.On("DeleteMany", mock.Anything, "my-bucket", chunkStarting(keys[1000])).Return(deleted(keys[1000:2000]), nil).Once()
+	mockRepo.On("DeleteMany", mock.Anything, "my-bucket", chunkStarting(keys[2000])).Return(nil, errors.New("connection reset")).Once()
+
+	var last BatchProgress
+	report, err := service.DeleteFiles(ctx, "my-bucket", BatchDeleteRequest{Keys: keys}, func(p BatchProgress) { last = p })
+
+	assert.NoError(t, err)
+	assert.Len(t, report.Results, 2500)
+	assert.Equal(t, 2000, report.Deleted)
+	assert.Equal(t, 500, report.Failed)
+	assert.Equal(t, keys[2499], report.Results[2499].Key)
+	assert.Equal(t, "connection reset", report.Results[2499].Error)
+	assert.Equal(t, BatchProgress{Total: 2500, Processed: 2500, Failed: 500}, last)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteAndCopyFiles_ProcessPrefixPageByPage(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(ctx, "my-bucket"))
+	require.NoError(t, repo.CreateBucket(ctx, "backup-bucket"))
+	for i := range 2*deleteBatchSize + 500 {
+		_, err := repo.putObject(ctx, "my-bucket", fmt.Sprintf("logs/%05d.txt", i), "text/plain", strings.NewReader("x"))
+		require.NoError(t, err)
+	}
+	service := NewService(repo)
+
+	var copied []BatchProgress
+	copyReport, err := service.CopyFiles(ctx, BulkCopyRequest{SourceBucket: "my-bucket", DestinationBucket: "backup-bucket", Prefix: "logs/"},
+		func(p BatchProgress) { copied = append(copied, p) })
+	require.NoError(t, err)
+	assert.Equal(t, 2500, copyReport.Copied)
+	require.Len(t, copied, 2500)
+	assert.Equal(t, BatchProgress{Total: 2500, Processed: 2500}, copied[len(copied)-1])
+	assert.Less(t, copied[0].Total, 2500, "progress starts before the whole prefix is listed")
+
+	var deleted []BatchProgress
+	report, err := service.DeleteFiles(ctx, "my-bucket", BatchDeleteRequest{Prefix: "logs/"},
+		func(p BatchProgress) { deleted = append(deleted, p) })
+	require.NoError(t, err)
+	assert.Equal(t, 2500, report.Deleted)
+	assert.Empty(t, report.Results, "listed keys are only reported when they fail")
+
+	require.Len(t, deleted, 3, "one report per listed page")
+	assert.Equal(t, BatchProgress{Total: 2500, Processed: 2500}, deleted[2])
+
+	page, err := repo.List(ctx, "my-bucket", ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Files)
+}
+
+// failingVersionDeletes is a MemoryRepository whose DeleteVersion always fails.
+type failingVersionDeletes struct {
+	*MemoryRepository
+}
+
+func (r failingVersionDeletes) DeleteVersion(ctx context.Context, bucket, key, versionID string) error {
+	return errors.New("access denied")
+}
+
+func TestCompleteUpload_KeepsSanitizedObjectWhenCleanupFails(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(ctx, "my-bucket"))
+	require.NoError(t, repo.SetBucketVersioning(ctx, "my-bucket", true))
+	service := NewService(failingVersionDeletes{repo},
+		WithPolicies(Policies{Default: Policy{AllowedTypes: []string{"image/svg+xml"}}}),
+		WithInspectors(inspect.DefaultChain(inspect.Options{StripSVGScripts: true})))
+
+	session, err := service.InitiateUpload(ctx, "my-bucket", "logo.svg", "image/svg+xml")
+	require.NoError(t, err)
+	_, err = repo.writePart(ctx, "my-bucket", session.Key, session.UploadID, 1,
+		strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+	require.NoError(t, err)
+
+	_, err = service.CompleteUpload(ctx, "my-bucket", session.Key, session.UploadID, nil)
+	require.NoError(t, err, "the sanitized object is stored even if the original version stays")
+
+	obj, err := repo.Download(ctx, "my-bucket", session.Key, DownloadOptions{})
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	data, _ := io.ReadAll(obj.Body)
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg"></svg>`, string(data))
+}
@@ -0,0 +1,119 @@
+package upload
+
+import (
+	"maps"
+	"path"
+	"strings"
+	"time"
+)
+
+// rootPrefix groups objects that sit directly under the stats scope.
+const rootPrefix = "/"
+
+// newBucketStats starts an empty scan of bucket. Every backend feeds it one
+// object at a time while paging through its listing, so the breakdowns are
+// computed the same way everywhere.
+func newBucketStats(bucket, prefix string) *BucketStats {
+	return &BucketStats{
+		BucketName:     bucket,
+		Prefix:         prefix,
+		ComputedAt:     time.Now().UTC(),
+		ByStorageClass: make(map[string]StatsBreakdown),
+		ByExtension:    make(map[string]StatsBreakdown),
+		ByPrefix:       make(map[string]StatsBreakdown),
+	}
+}
+
+func (s *BucketStats) add(obj StatsObject, storageClass string) {
+	s.TotalFiles++
+	s.TotalSizeBytes += obj.SizeBytes
+
+	addBreakdown(s.ByStorageClass, statsStorageClass(storageClass), obj.SizeBytes)
+	addBreakdown(s.ByExtension, strings.ToLower(path.Ext(obj.Key)), obj.SizeBytes)
+	addBreakdown(s.ByPrefix, topLevelPrefix(obj.Key, s.Prefix), obj.SizeBytes)
+
+	if s.OldestObject == nil || obj.LastModified.Before(s.OldestObject.LastModified) {
+		s.OldestObject = &obj
+	}
+	if s.NewestObject == nil || obj.LastModified.After(s.NewestObject.LastModified) {
+		s.NewestObject = &obj
+	}
+}
+
+// remove takes obj back out of the totals. It reports false when obj was the
+// oldest or newest object, since the replacement is only known after a rescan.
+func (s *BucketStats) remove(obj StatsObject, storageClass string) bool {
+	if (s.OldestObject != nil && s.OldestObject.Key == obj.Key) ||
+		(s.NewestObject != nil && s.NewestObject.Key == obj.Key) {
+		return false
+	}
+
+	s.TotalFiles--
+	s.TotalSizeBytes -= obj.SizeBytes
+
+	removeBreakdown(s.ByStorageClass, statsStorageClass(storageClass), obj.SizeBytes)
+	removeBreakdown(s.ByExtension, strings.ToLower(path.Ext(obj.Key)), obj.SizeBytes)
+	removeBreakdown(s.ByPrefix, topLevelPrefix(obj.Key, s.Prefix), obj.SizeBytes)
+	return true
+}
+
+// summarize fills in the derived totals once objects have been added or removed.
+func (s *BucketStats) summarize() *BucketStats {
+	s.TotalSizeFormatted = formatBytes(s.TotalSizeBytes)
+	s.AverageSizeBytes = 0
+	if s.TotalFiles > 0 {
+		s.AverageSizeBytes = s.TotalSizeBytes / int64(s.TotalFiles)
+	}
+	return s
+}
+
+func (s *BucketStats) clone() *BucketStats {
+	c := *s
+	c.ByStorageClass = maps.Clone(s.ByStorageClass)
+	c.ByExtension = maps.Clone(s.ByExtension)
+	c.ByPrefix = maps.Clone(s.ByPrefix)
+	if s.OldestObject != nil {
+		oldest := *s.OldestObject
+		c.OldestObject = &oldest
+	}
+	if s.NewestObject != nil {
+		newest := *s.NewestObject
+		c.NewestObject = &newest
+	}
+	return &c
+}
+
+func addBreakdown(m map[string]StatsBreakdown, name string, size int64) {
+	b := m[name]
+	b.Files++
+	b.SizeBytes += size
+	m[name] = b
+}
+
+func removeBreakdown(m map[string]StatsBreakdown, name string, size int64) {
+	b := m[name]
+	b.Files--
+	b.SizeBytes -= size
+	if b.Files <= 0 {
+		delete(m, name)
+		return
+	}
+	m[name] = b
+}
+
+func statsStorageClass(class string) string {
+	if class == "" {
+		return localStorageClass
+	}
+	return class
+}
+
+// topLevelPrefix returns the first "folder" of key below scope, e.g.
+// "logs/" for "logs/2024/app.log", or rootPrefix when there is none.
+func topLevelPrefix(key, scope string) string {
+	rest := strings.TrimPrefix(key, scope)
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return scope + rest[:i+1]
+	}
+	return rootPrefix
+}
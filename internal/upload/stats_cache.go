@@ -0,0 +1,109 @@
+package upload
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type statsScope struct {
+	bucket string
+	prefix string
+}
+
+// statsCache keeps the last BucketStats computed for each bucket and prefix so
+// the stats endpoint does not rescan on every request. Writes made through the
+// service adjust the cached totals in place; anything the service cannot see,
+// such as presigned uploads or other S3 clients, is picked up by the periodic
+// rescan or once the entry is older than ttl.
+type statsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[statsScope]*BucketStats
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, entries: make(map[statsScope]*BucketStats)}
+}
+
+func (c *statsCache) get(bucket, prefix string) (*BucketStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats, ok := c.entries[statsScope{bucket, prefix}]
+	if !ok || (c.ttl > 0 && time.Since(stats.ComputedAt) > c.ttl) {
+		return nil, false
+	}
+	return stats.clone(), true
+}
+
+func (c *statsCache) put(stats *BucketStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[statsScope{stats.BucketName, stats.Prefix}] = stats.clone()
+}
+
+func (c *statsCache) tracks(bucket string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for scope := range c.entries {
+		if scope.bucket == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *statsCache) scopes() []statsScope {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	scopes := make([]statsScope, 0, len(c.entries))
+	for scope := range c.entries {
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+func (c *statsCache) added(bucket string, obj StatsObject, storageClass string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for scope, stats := range c.entries {
+		if scope.bucket == bucket && strings.HasPrefix(obj.Key, scope.prefix) {
+			stats.add(obj, storageClass)
+			stats.summarize()
+		}
+	}
+}
+
+func (c *statsCache) removed(bucket string, obj StatsObject, storageClass string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for scope, stats := range c.entries {
+		if scope.bucket != bucket || !strings.HasPrefix(obj.Key, scope.prefix) {
+			continue
+		}
+		if !stats.remove(obj, storageClass) {
+			delete(c.entries, scope)
+			continue
+		}
+		stats.summarize()
+	}
+}
+
+func (c *statsCache) invalidate(buckets ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for scope := range c.entries {
+		for _, bucket := range buckets {
+			if scope.bucket == bucket {
+				delete(c.entries, scope)
+			}
+		}
+	}
+}
@@ -0,0 +1,735 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoaoOliveira889/s3-api/internal/inspect"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	tusVersion             = "1.0.0"
+	tusExtensions          = "creation,termination,checksum,expiration"
+	tusChecksumAlgorithms  = "md5,sha1,sha256"
+	tusContentType         = "application/offset+octet-stream"
+	statusChecksumMismatch = 460
+	defaultTusExpiration   = 24 * time.Hour
+)
+
+var (
+	errChecksumMismatch = errors.New("checksum mismatch")
+	errTusBuffer        = errors.New("failed to buffer chunk")
+)
+
+type TusConfig struct {
+	MaxSize  int64
+	PartSize int64
+	TempDir  string
+	// Expiration is how long an upload may sit idle before the sweeper aborts
+	// it. Every PATCH pushes the deadline back.
+	Expiration time.Duration
+	// Store persists sessions across restarts; nil keeps them in memory only.
+	Store TusStore
+}
+
+type tusUpload struct {
+	mu         sync.Mutex
+	id         string
+	session    UploadSession
+	length     int64
+	offset     int64
+	metadata   string
+	parts      []UploadedPart
+	bufferPath string
+	buffered   int64
+	done       bool
+	expiresAt  time.Time
+	// removed is set once the upload is forgotten, so a late save does not
+	// bring it back into the store.
+	removed bool
+}
+
+type TusHandler struct {
+	service Service
+	cfg     TusConfig
+
+	mu      sync.RWMutex
+	uploads map[string]*tusUpload
+}
+
+func NewTusHandler(s Service, cfg TusConfig) *TusHandler {
+	if cfg.PartSize < minPartSize {
+		cfg.PartSize = minPartSize
+	}
+	if cfg.Expiration <= 0 {
+		cfg.Expiration = defaultTusExpiration
+	}
+	return &TusHandler{
+		service: s,
+		cfg:     cfg,
+		uploads: make(map[string]*tusUpload),
+	}
+}
+
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	if h.cfg.MaxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) Create(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a valid Upload-Length header is required"})
+		return
+	}
+	if h.cfg.MaxSize > 0 && length > h.cfg.MaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "upload exceeds the maximum allowed size"})
+		return
+	}
+
+	rawMetadata := c.GetHeader("Upload-Metadata")
+	metadata, err := parseTusMetadata(rawMetadata)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bucket := firstNonEmpty(metadata["bucket"], c.Query("bucket"))
+	filename := firstNonEmpty(metadata["filename"], metadata["name"])
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename metadata is required"})
+		return
+	}
+
+	session, err := h.service.InitiateUpload(requestContext(c), bucket, filename, firstNonEmpty(metadata["filetype"], metadata["type"]))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	buffer, err := os.CreateTemp(h.cfg.TempDir, "tus-*")
+	if err != nil {
+		slog.Error("failed to create tus buffer", "error", err)
+		_ = h.service.AbortUpload(c.Request.Context(), session.Bucket, session.Key, session.UploadID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an unexpected error occurred"})
+		return
+	}
+	buffer.Close()
+
+	id := uuid.NewString()
+	u := &tusUpload{
+		id:         id,
+		session:    *session,
+		length:     length,
+		metadata:   rawMetadata,
+		bufferPath: buffer.Name(),
+		expiresAt:  time.Now().Add(h.cfg.Expiration),
+	}
+
+	h.mu.Lock()
+	h.uploads[id] = u
+	h.mu.Unlock()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer h.save(u)
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+id)
+	c.Header("X-Object-Key", session.Key)
+
+	if length == 0 {
+		if err := h.finish(c, id, u); err != nil {
+			handleError(c, err)
+			return
+		}
+	}
+
+	setUploadExpires(c, u)
+	c.Status(http.StatusCreated)
+}
+
+func (h *TusHandler) Head(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	u := h.lookup(c.Param("id"))
+	if u == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.removed {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(u.offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(u.length, 10))
+	c.Header("X-Object-Key", u.session.Key)
+	if u.metadata != "" {
+		c.Header("Upload-Metadata", u.metadata)
+	}
+	setUploadExpires(c, u)
+	c.Status(http.StatusOK)
+}
+
+func (h *TusHandler) Patch(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	if c.GetHeader("Content-Type") != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + tusContentType})
+		return
+	}
+
+	id := c.Param("id")
+	u := h.lookup(id)
+	if u == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a valid Upload-Offset header is required"})
+		return
+	}
+
+	var checksum *tusChecksum
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		checksum, err = parseTusChecksum(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.removed {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if offset != u.offset {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+		return
+	}
+
+	if !u.done {
+		// Saved on every way out, since an interrupted chunk still moves the
+		// offset and a failed flush still leaves the buffer on disk.
+		defer h.save(u)
+		u.expiresAt = time.Now().Add(h.cfg.Expiration)
+
+		if err := h.receive(c, u, c.Request.Body, checksum); err != nil {
+			switch {
+			case errors.Is(err, errChecksumMismatch):
+				c.JSON(statusChecksumMismatch, gin.H{"error": err.Error()})
+			case errors.Is(err, errTusBuffer):
+				slog.Error("failed to store tus chunk", "error", err, "upload_id", id)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store chunk"})
+			default:
+				handleError(c, err)
+			}
+			return
+		}
+
+		if u.offset == u.length {
+			if err := h.finish(c, id, u); err != nil {
+				handleError(c, err)
+				return
+			}
+		}
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(u.offset, 10))
+	c.Header("X-Object-Key", u.session.Key)
+	setUploadExpires(c, u)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) Terminate(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	id := c.Param("id")
+	u := h.lookup(id)
+	if u == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.removed {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if !u.done {
+		err := h.service.AbortUpload(c.Request.Context(), u.session.Bucket, u.session.Key, u.session.UploadID)
+		if err != nil && !errors.Is(err, ErrUploadNotFound) {
+			handleError(c, err)
+			return
+		}
+	}
+
+	h.forget(id, u)
+	c.Status(http.StatusNoContent)
+}
+
+// receive stores a PATCH body. Without a checksum each part is sent as soon as
+// it is full, so a body of any size never needs more than a part of disk and
+// never produces a part above PartSize. A checksummed body has to be verified
+// before any of it is used, so it is buffered whole and then sent in parts.
+func (h *TusHandler) receive(c *gin.Context, u *tusUpload, body io.Reader, checksum *tusChecksum) error {
+	if checksum != nil {
+		if err := h.appendChunk(u, body, u.length-u.offset, checksum); err != nil {
+			return err
+		}
+		return h.flush(c, u, false)
+	}
+
+	for u.offset < u.length {
+		limit := min(h.cfg.PartSize-u.buffered, u.length-u.offset)
+		before := u.offset
+		err := h.appendChunk(u, body, limit, nil)
+		if err := h.flush(c, u, false); err != nil {
+			return err
+		}
+		if err != nil || u.offset-before < limit {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *TusHandler) appendChunk(u *tusUpload, body io.Reader, limit int64, checksum *tusChecksum) error {
+	f, err := os.OpenFile(u.bufferPath, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errTusBuffer, err)
+	}
+	defer f.Close()
+
+	w := io.Writer(io.NewOffsetWriter(f, u.buffered))
+	if checksum != nil {
+		w = io.MultiWriter(w, checksum.hash)
+	}
+
+	n, copyErr := io.Copy(w, io.LimitReader(body, limit))
+
+	if checksum != nil {
+		if copyErr != nil || !checksum.matches() {
+			if err := f.Truncate(u.buffered); err != nil {
+				return fmt.Errorf("%w: %w", errTusBuffer, err)
+			}
+			if copyErr != nil {
+				return fmt.Errorf("%w: %w", errTusBuffer, copyErr)
+			}
+			return errChecksumMismatch
+		}
+	}
+
+	// Without a checksum the bytes received before an interruption are kept,
+	// so the client can resume from the new offset.
+	u.buffered += n
+	u.offset += n
+	if copyErr != nil {
+		return fmt.Errorf("%w: %w", errTusBuffer, copyErr)
+	}
+	return nil
+}
+
+// flush sends every full part in the buffer, and with final the rest too, then
+// moves what is left to the start of the buffer. A checksummed chunk can leave
+// more than one part buffered.
+func (h *TusHandler) flush(c *gin.Context, u *tusUpload, final bool) (err error) {
+	if u.buffered < h.cfg.PartSize && !final {
+		return nil
+	}
+	if final && u.buffered == 0 && len(u.parts) > 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(u.bufferPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sent int64
+	defer func() {
+		if shiftErr := shiftBuffer(f, sent, u.buffered); shiftErr != nil && err == nil {
+			err = shiftErr
+		}
+		u.buffered -= sent
+	}()
+
+	for {
+		size := min(h.cfg.PartSize, u.buffered-sent)
+		if size < h.cfg.PartSize && !final {
+			return nil
+		}
+
+		part, err := h.service.UploadPart(
+			c.Request.Context(),
+			u.session.Bucket,
+			u.session.Key,
+			u.session.UploadID,
+			int32(len(u.parts)+1),
+			io.NewSectionReader(f, sent, size),
+			size,
+		)
+		if err != nil {
+			return err
+		}
+		u.parts = append(u.parts, *part)
+		sent += size
+
+		if sent >= u.buffered {
+			return nil
+		}
+	}
+}
+
+// shiftBuffer moves the bytes in [from, to) to the start of f and drops the
+// rest. Reads stay ahead of writes, so the overlapping copy is safe.
+func shiftBuffer(f *os.File, from, to int64) error {
+	if from == 0 {
+		return nil
+	}
+	if _, err := io.Copy(io.NewOffsetWriter(f, 0), io.NewSectionReader(f, from, to-from)); err != nil {
+		return err
+	}
+	return f.Truncate(to - from)
+}
+
+func (h *TusHandler) finish(c *gin.Context, id string, u *tusUpload) error {
+	if err := h.flush(c, u, true); err != nil {
+		return err
+	}
+
+	if _, err := h.service.CompleteUpload(c.Request.Context(), u.session.Bucket, u.session.Key, u.session.UploadID, u.parts); err != nil {
+		var rejected *inspect.Error
+		if errors.Is(err, ErrInvalidFileType) || errors.Is(err, ErrFileTooLarge) ||
+			errors.Is(err, ErrInfectedFile) || errors.Is(err, ErrScanUnavailable) || errors.Is(err, ErrTooLargeToScan) ||
+			errors.As(err, &rejected) {
+			h.forget(id, u)
+		}
+		return err
+	}
+
+	u.done = true
+	u.expiresAt = time.Now().Add(h.cfg.Expiration)
+	_ = os.Remove(u.bufferPath)
+
+	slog.Info("tus upload completed", "upload_id", id, "key", u.session.Key, "size", u.length)
+	return nil
+}
+
+// lookup treats an expired upload as gone even before the sweeper removes it.
+func (h *TusHandler) lookup(id string) *tusUpload {
+	h.mu.RLock()
+	u := h.uploads[id]
+	h.mu.RUnlock()
+	if u == nil {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.removed || u.expired(time.Now()) {
+		return nil
+	}
+	return u
+}
+
+func (h *TusHandler) forget(id string, u *tusUpload) {
+	h.mu.Lock()
+	delete(h.uploads, id)
+	h.mu.Unlock()
+
+	u.removed = true
+	_ = os.Remove(u.bufferPath)
+	if h.cfg.Store != nil {
+		if err := h.cfg.Store.Delete(context.Background(), id); err != nil {
+			slog.Error("failed to delete tus session", "error", err, "upload_id", id)
+		}
+	}
+}
+
+// save persists u; the caller holds u.mu. It uses a fresh context so a client
+// that disconnects mid-chunk still gets the bytes it sent recorded.
+func (h *TusHandler) save(u *tusUpload) {
+	if h.cfg.Store == nil || u.removed {
+		return
+	}
+	if err := h.cfg.Store.Save(context.Background(), u.snapshot()); err != nil {
+		slog.Error("failed to save tus session", "error", err, "upload_id", u.id)
+	}
+}
+
+// Restore reloads the sessions saved by a previous process. A buffer that
+// holds more than was recorded is cut back; one that holds less, or is gone,
+// moves the offset back so the client re-sends the missing bytes.
+func (h *TusHandler) Restore(ctx context.Context) (int, error) {
+	if h.cfg.Store == nil {
+		return 0, nil
+	}
+
+	sessions, err := h.cfg.Store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, session := range sessions {
+		u := newTusUpload(session)
+		if !u.done {
+			if err := reconcileBuffer(u); err != nil {
+				slog.Error("failed to restore tus buffer", "error", err, "upload_id", u.id)
+				continue
+			}
+			h.save(u)
+		}
+
+		h.mu.Lock()
+		h.uploads[u.id] = u
+		h.mu.Unlock()
+		restored++
+	}
+	return restored, nil
+}
+
+func reconcileBuffer(u *tusUpload) error {
+	var size int64
+	info, err := os.Stat(u.bufferPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.WriteFile(u.bufferPath, nil, 0o600); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		size = info.Size()
+	}
+
+	if size > u.buffered {
+		return os.Truncate(u.bufferPath, u.buffered)
+	}
+	u.offset -= u.buffered - size
+	u.buffered = size
+	return nil
+}
+
+// StartSweeper aborts uploads that have been idle past their expiration and
+// removes their buffers, until ctx is done.
+func (h *TusHandler) StartSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n := h.sweep(ctx, time.Now()); n > 0 {
+					slog.Info("expired tus uploads removed", "count", n)
+				}
+			}
+		}
+	}()
+}
+
+func (h *TusHandler) sweep(ctx context.Context, now time.Time) int {
+	h.mu.RLock()
+	candidates := make([]*tusUpload, 0, len(h.uploads))
+	for _, u := range h.uploads {
+		candidates = append(candidates, u)
+	}
+	h.mu.RUnlock()
+
+	swept := 0
+	for _, u := range candidates {
+		// An upload busy with a chunk is not idle; skip it this round.
+		if !u.mu.TryLock() {
+			continue
+		}
+		if !u.removed && u.expired(now) {
+			if !u.done {
+				err := h.service.AbortUpload(ctx, u.session.Bucket, u.session.Key, u.session.UploadID)
+				if err != nil && !errors.Is(err, ErrUploadNotFound) {
+					slog.Error("failed to abort expired tus upload", "error", err, "upload_id", u.id)
+					u.mu.Unlock()
+					continue
+				}
+			}
+			h.forget(u.id, u)
+			swept++
+		}
+		u.mu.Unlock()
+	}
+	return swept
+}
+
+func (u *tusUpload) expired(now time.Time) bool {
+	return !u.expiresAt.IsZero() && now.After(u.expiresAt)
+}
+
+func (u *tusUpload) snapshot() TusSession {
+	return TusSession{
+		ID:         u.id,
+		Upload:     u.session,
+		Length:     u.length,
+		Offset:     u.offset,
+		Metadata:   u.metadata,
+		Parts:      u.parts,
+		BufferPath: u.bufferPath,
+		Buffered:   u.buffered,
+		Done:       u.done,
+		ExpiresAt:  u.expiresAt,
+	}
+}
+
+func newTusUpload(s TusSession) *tusUpload {
+	return &tusUpload{
+		id:         s.ID,
+		session:    s.Upload,
+		length:     s.Length,
+		offset:     s.Offset,
+		metadata:   s.Metadata,
+		parts:      s.Parts,
+		bufferPath: s.BufferPath,
+		buffered:   s.Buffered,
+		done:       s.Done,
+		expiresAt:  s.ExpiresAt,
+	}
+}
+
+func setUploadExpires(c *gin.Context, u *tusUpload) {
+	if !u.done {
+		c.Header("Upload-Expires", u.expiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "unsupported tus version"})
+		return false
+	}
+	return true
+}
+
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("invalid Upload-Metadata header")
+		}
+		if _, dup := metadata[key]; dup {
+			return nil, fmt.Errorf("duplicate Upload-Metadata key %q", key)
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+type tusChecksum struct {
+	hash     hash.Hash
+	expected []byte
+}
+
+func parseTusChecksum(header string) (*tusChecksum, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid Upload-Checksum header")
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Upload-Checksum header")
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+
+	return &tusChecksum{hash: h, expected: expected}, nil
+}
+
+func (c *tusChecksum) matches() bool {
+	return bytes.Equal(c.hash.Sum(nil), c.expected)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
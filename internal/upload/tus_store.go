@@ -0,0 +1,108 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TusSession is the state of a tus upload that survives a restart. The bytes
+// not yet sent to storage as a part stay in BufferPath.
+type TusSession struct {
+	ID         string         `json:"id"`
+	Upload     UploadSession  `json:"upload"`
+	Length     int64          `json:"length"`
+	Offset     int64          `json:"offset"`
+	Metadata   string         `json:"metadata,omitempty"`
+	Parts      []UploadedPart `json:"parts,omitempty"`
+	BufferPath string         `json:"buffer_path"`
+	Buffered   int64          `json:"buffered"`
+	Done       bool           `json:"done,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+// TusStore persists tus sessions. Save is called after every chunk, so
+// implementations should be cheap to write to.
+type TusStore interface {
+	Save(ctx context.Context, session TusSession) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]TusSession, error)
+}
+
+// FileTusStore keeps one JSON document per session in dir. Writes go through
+// a temporary file and a rename so a crash never leaves a half-written session.
+type FileTusStore struct {
+	dir string
+}
+
+func NewFileTusStore(dir string) (*FileTusStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to prepare tus store: %w", err)
+	}
+	return &FileTusStore{dir: dir}, nil
+}
+
+func (s *FileTusStore) Save(ctx context.Context, session TusSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "session-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save tus session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save tus session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save tus session: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, session.ID+".json"))
+}
+
+func (s *FileTusStore) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil
+	}
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileTusStore) List(ctx context.Context) ([]TusSession, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tus sessions: %w", err)
+	}
+
+	var sessions []TusSession
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var session TusSession
+		if err := json.Unmarshal(data, &session); err != nil {
+			return nil, fmt.Errorf("corrupted tus session %s: %w", e.Name(), err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,309 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTusTestRouter(t *testing.T) (*gin.Engine, *MemoryRepository) {
+	t.Helper()
+
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(context.Background(), "my-bucket"))
+
+	tus := NewTusHandler(NewService(repo), TusConfig{MaxSize: 1 << 20, TempDir: t.TempDir()})
+	return tusRouter(tus), repo
+}
+
+func tusRouter(tus *TusHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	g := r.Group("/api/v1/tus")
+	g.OPTIONS("", tus.Options)
+	g.POST("", tus.Create)
+	g.HEAD("/:id", tus.Head)
+	g.PATCH("/:id", tus.Patch)
+	g.DELETE("/:id", tus.Terminate)
+	return r
+}
+
+func tusRequest(r http.Handler, method, target string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func tusMetadata(pairs ...string) string {
+	var out []byte
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, pairs[i]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[i+1]))...)
+	}
+	return string(out)
+}
+
+func createTusUpload(t *testing.T, r http.Handler, content []byte, filename string) string {
+	t.Helper()
+
+	rec := tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(len(content)),
+		"Upload-Metadata": tusMetadata("bucket", "my-bucket", "filename", filename),
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	location := rec.Header().Get("Location")
+	require.NotEmpty(t, location)
+	return location
+}
+
+func TestTus_UploadInChunks(t *testing.T) {
+	r, repo := newTusTestRouter(t)
+	content := []byte(testPNG + "some image payload")
+	location := createTusUpload(t, r, content, "photo.png")
+
+	first, second := content[:10], content[10:]
+	rec := tusRequest(r, http.MethodPatch, location, first, map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "0",
+	})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, "10", rec.Header().Get("Upload-Offset"))
+
+	rec = tusRequest(r, http.MethodHead, location, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Upload-Offset"))
+	assert.Equal(t, strconv.Itoa(len(content)), rec.Header().Get("Upload-Length"))
+
+	rec = tusRequest(r, http.MethodPatch, location, second, map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "5",
+	})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	sum := sha1.Sum(second)
+	rec = tusRequest(r, http.MethodPatch, location, second, map[string]string{
+		"Content-Type":    tusContentType,
+		"Upload-Offset":   "10",
+		"Upload-Checksum": "sha1 " + base64.StdEncoding.EncodeToString(sum[:]),
+	})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	key := rec.Header().Get("X-Object-Key")
+	require.NotEmpty(t, key)
+	assert.NotEqual(t, "photo.png", key)
+
+	obj, err := repo.Download(context.Background(), "my-bucket", key, DownloadOptions{})
+	require.NoError(t, err)
+	data, _ := io.ReadAll(obj.Body)
+	assert.Equal(t, content, data)
+}
+
+func TestTus_ChecksumMismatchDiscardsChunk(t *testing.T) {
+	r, _ := newTusTestRouter(t)
+	content := []byte(testPNG + "payload")
+	location := createTusUpload(t, r, content, "photo.png")
+
+	rec := tusRequest(r, http.MethodPatch, location, content, map[string]string{
+		"Content-Type":    tusContentType,
+		"Upload-Offset":   "0",
+		"Upload-Checksum": "sha1 " + base64.StdEncoding.EncodeToString([]byte("not-the-right-digest")),
+	})
+	assert.Equal(t, statusChecksumMismatch, rec.Code)
+
+	rec = tusRequest(r, http.MethodHead, location, nil, nil)
+	assert.Equal(t, "0", rec.Header().Get("Upload-Offset"))
+}
+
+func TestTus_RejectsInvalidContentAndTerminates(t *testing.T) {
+	r, _ := newTusTestRouter(t)
+
+	content := []byte("#!/bin/sh\nrm -rf /\n")
+	location := createTusUpload(t, r, content, "evil.png")
+
+	rec := tusRequest(r, http.MethodPatch, location, content, map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "0",
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tusRequest(r, http.MethodHead, location, nil, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	location = createTusUpload(t, r, []byte(testPNG), "photo.png")
+	rec = tusRequest(r, http.MethodDelete, location, nil, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = tusRequest(r, http.MethodHead, location, nil, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTus_RequiresProtocolVersion(t *testing.T) {
+	r, _ := newTusTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tus", nil)
+	req.Header.Set("Upload-Length", "10")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = tusRequest(r, http.MethodOptions, "/api/v1/tus", nil, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Contains(t, rec.Header().Get("Tus-Extension"), "checksum")
+	assert.Contains(t, rec.Header().Get("Tus-Extension"), "expiration")
+}
+
+// partSizeRepo records the size of every part uploaded through it.
+type partSizeRepo struct {
+	*MemoryRepository
+
+	mu    sync.Mutex
+	sizes []int64
+}
+
+func (r *partSizeRepo) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.ReadSeeker, size int64) (string, error) {
+	r.mu.Lock()
+	r.sizes = append(r.sizes, size)
+	r.mu.Unlock()
+	return r.MemoryRepository.UploadPart(ctx, bucket, key, uploadID, partNumber, body, size)
+}
+
+func TestTus_SplitsLargeChunkIntoParts(t *testing.T) {
+	content := append([]byte(testPNG), bytes.Repeat([]byte("0123456789abcdef"), (2*minPartSize+minPartSize/2)/16)...)
+	sum := sha1.Sum(content)
+
+	for name, checksum := range map[string]string{
+		"plain":    "",
+		"checksum": "sha1 " + base64.StdEncoding.EncodeToString(sum[:]),
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := &partSizeRepo{MemoryRepository: NewMemoryRepository("http://localhost:8080", []byte("secret"))}
+			require.NoError(t, repo.CreateBucket(context.Background(), "my-bucket"))
+			r := tusRouter(NewTusHandler(NewService(repo), TusConfig{MaxSize: 1 << 30, TempDir: t.TempDir()}))
+			location := createTusUpload(t, r, content, "large.png")
+
+			headers := map[string]string{"Content-Type": tusContentType, "Upload-Offset": "0"}
+			if checksum != "" {
+				headers["Upload-Checksum"] = checksum
+			}
+			rec := tusRequest(r, http.MethodPatch, location, content, headers)
+			require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+			assert.Equal(t, []int64{minPartSize, minPartSize, int64(len(content)) - 2*minPartSize}, repo.sizes)
+
+			obj, err := repo.Download(context.Background(), "my-bucket", rec.Header().Get("X-Object-Key"), DownloadOptions{})
+			require.NoError(t, err)
+			data, _ := io.ReadAll(obj.Body)
+			assert.Equal(t, content, data)
+		})
+	}
+}
+
+func TestTus_ResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileTusStore(dir)
+	require.NoError(t, err)
+
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(context.Background(), "my-bucket"))
+	cfg := TusConfig{MaxSize: 1 << 20, TempDir: dir, Store: store}
+
+	r := tusRouter(NewTusHandler(NewService(repo), cfg))
+	content := []byte(testPNG + "some image payload")
+	location := createTusUpload(t, r, content, "photo.png")
+
+	rec := tusRequest(r, http.MethodPatch, location, content[:10], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "0",
+	})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	// Bytes written after the last save, as by a crash mid-chunk, are dropped.
+	buffers, err := filepath.Glob(filepath.Join(dir, "tus-*"))
+	require.NoError(t, err)
+	require.Len(t, buffers, 1)
+	f, err := os.OpenFile(buffers[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("torn")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restarted := NewTusHandler(NewService(repo), cfg)
+	restored, err := restarted.Restore(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+	r = tusRouter(restarted)
+
+	rec = tusRequest(r, http.MethodHead, location, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Upload-Offset"))
+
+	rec = tusRequest(r, http.MethodPatch, location, content[10:], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "10",
+	})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	obj, err := repo.Download(context.Background(), "my-bucket", rec.Header().Get("X-Object-Key"), DownloadOptions{})
+	require.NoError(t, err)
+	data, _ := io.ReadAll(obj.Body)
+	assert.Equal(t, content, data)
+}
+
+func TestTus_SweeperAbortsExpiredUploads(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileTusStore(dir)
+	require.NoError(t, err)
+
+	repo := NewMemoryRepository("http://localhost:8080", []byte("secret"))
+	require.NoError(t, repo.CreateBucket(context.Background(), "my-bucket"))
+	tus := NewTusHandler(NewService(repo), TusConfig{MaxSize: 1 << 20, TempDir: dir, Expiration: time.Hour, Store: store})
+	r := tusRouter(tus)
+
+	content := []byte(testPNG + "payload")
+	location := createTusUpload(t, r, content, "photo.png")
+	rec := tusRequest(r, http.MethodPatch, location, content[:10], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "0",
+	})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	expires, err := http.ParseTime(rec.Header().Get("Upload-Expires"))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+
+	session := tus.lookup(path.Base(location)).session
+	assert.Zero(t, tus.sweep(context.Background(), time.Now()), "live uploads are kept")
+	assert.Equal(t, 1, tus.sweep(context.Background(), time.Now().Add(2*time.Hour)))
+
+	rec = tusRequest(r, http.MethodHead, location, nil, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err = repo.ListParts(context.Background(), session.Bucket, session.Key, session.UploadID)
+	assert.ErrorIs(t, err, ErrUploadNotFound, "the multipart upload is aborted")
+
+	leftovers, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, leftovers, "the buffer and the saved session are removed")
+}